@@ -15,10 +15,14 @@ func init() {
 	rootCmd.AddGroup(&cobra.Group{ID: "make", Title: "Make:"})
 	rootCmd.AddCommand(
 		makeMigrationCommand,
+		makeJobCommand,
 	)
 
 	makeMigrationCommand.Flags().StringP("name", "n", "", "(required) Migration name. for example: create_users_table")
 	makeMigrationCommand.MarkFlagRequired("name")
+
+	makeJobCommand.Flags().StringP("name", "n", "", "(required) Job handler name. for example: SendWelcomeEmail")
+	makeJobCommand.MarkFlagRequired("name")
 }
 
 var makeMigrationCommand = &cobra.Command{
@@ -64,3 +68,41 @@ var makeMigrationCommand = &cobra.Command{
 
 	},
 }
+
+var makeJobCommand = &cobra.Command{
+	Use:     "make:job",
+	Short:   "Create a new job handler file",
+	GroupID: "make",
+	Run: func(cmd *cobra.Command, _ []string) {
+		// Setup all the required dependencies
+		setUpConfig()
+		setUpLogger()
+
+		name, _ := cmd.Flags().GetString("name")
+		jobName := strcase.ToCamel(name)
+
+		template := "pkg/template/job_file.txt"
+		outputPath := "internal/job/" + strcase.ToSnake(jobName) + ".go"
+
+		if _, err := os.Stat(outputPath); err == nil {
+			logger.Log.Error("Job file already exists", zap.String("path", outputPath))
+			return
+		}
+
+		read, err := os.ReadFile(template)
+		if err != nil {
+			logger.Log.Error("Error reading template file", zap.Error(err))
+			return
+		}
+
+		newContents := strings.ReplaceAll(string(read), "<job_name>", jobName)
+
+		err = os.WriteFile(outputPath, []byte(newContents), 0644)
+		if err != nil {
+			logger.Log.Error("Error writing to file", zap.Error(err))
+			return
+		}
+
+		logger.Log.Info("Job created", zap.String("path", outputPath))
+	},
+}
@@ -2,19 +2,24 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"webapi/config"
 	"webapi/internal/helper/queue"
 	"webapi/internal/job"
 	"webapi/internal/logger"
-	"webapi/internal/repository"
+	"webapi/internal/metrics"
 )
 
 func init() {
@@ -24,15 +29,26 @@ func init() {
 		queueClearCommand,
 		queueFlushCommand,
 		queueForgetCommand,
+		queueCancelCommand,
 		queueRetryCommand,
 		queueRestoreCommand,
+		queuePauseCommand,
+		queueResumeCommand,
+		queueMonitorCommand,
+		queuePruneCommand,
+		queueRelayCommand,
 	)
 
-	queueWorkCommand.Flags().StringP("queue", "q", "default", "(optional) queue name. for example: -q emails")
+	queueWorkCommand.Flags().StringP("queue", "q", "default", "(optional) queue names with optional weights. for example: -q critical:5,default:2,low:1")
 	queueWorkCommand.Flags().IntP("worker", "w", 1, "(optional) The number of worker goroutines to run. for example: -w 2")
+	queueWorkCommand.Flags().StringP("strategy", "s", "", "(optional) how to pick the next queue: strict or weighted. default is queue.strategy in config")
+	queueWorkCommand.Flags().Int("metrics-port", 0, "(optional) port serving prometheus metrics on /metrics. default is queue.metricsPort in config, 0 disables it")
 	queueWorkCommand.Example = "  queue:work"
 	queueWorkCommand.Example += "\n  queue:work -w 2"
 	queueWorkCommand.Example += "\n  queue:work -q emails -w 2"
+	queueWorkCommand.Example += "\n  queue:work -q critical:5,default:2,low:1 -w 8"
+	queueWorkCommand.Example += "\n  queue:work -q critical,default,low -s strict"
+	queueWorkCommand.Example += "\n  queue:work --metrics-port 9100"
 
 	queueRetryCommand.Flags().StringP("queue", "q", "default", "(optional) queue name. for example: -q emails")
 	queueRetryCommand.Flags().StringP("id", "i", "", "(optional) job id. for example: --id df6df3af-d53d-49c2-bd50-80ba1d32b17b")
@@ -54,9 +70,48 @@ func init() {
 	queueForgetCommand.MarkFlagRequired("id")
 	queueForgetCommand.Example = `  queue:forget -i df6df3af-d53d-49c2-bd50-80ba1d32b17b`
 
+	queueCancelCommand.Flags().StringP("id", "i", "", "job id. for example: --id df6df3af-d53d-49c2-bd50-80ba1d32b17b")
+	queueCancelCommand.MarkFlagRequired("id")
+	queueCancelCommand.Example = `  queue:cancel -i df6df3af-d53d-49c2-bd50-80ba1d32b17b`
+
+	queuePruneCommand.Flags().String("completed-older-than", "", "(optional) delete completed jobs older than this age, for example 7d or 12h. default is queue.prune.completedOlderThan in config")
+	queuePruneCommand.Flags().String("failed-older-than", "", "(optional) delete failed, cancelled and expired jobs older than this age, for example 30d. default is queue.prune.failedOlderThan in config")
+	queuePruneCommand.Flags().Int("batch-size", 0, "(optional) jobs deleted per transaction. default is queue.prune.batchSize in config")
+	queuePruneCommand.Flags().Bool("archive", false, "(optional) upload the pruned jobs to minio as gzip compressed JSONL before deleting them. default is queue.prune.archive in config")
+	queuePruneCommand.Flags().String("bucket", "", "(optional) minio bucket of the archive. default is queue.prune.archiveBucket in config")
+	queuePruneCommand.Example = "  queue:prune"
+	queuePruneCommand.Example += "\n  queue:prune --completed-older-than 7d --failed-older-than 30d"
+	queuePruneCommand.Example += "\n  queue:prune --completed-older-than 7d --archive --bucket job-archive"
+
+	queueRelayCommand.Flags().IntP("batch-size", "b", 100, "(optional) jobs relayed per transaction")
+	queueRelayCommand.Flags().DurationP("poll-interval", "i", time.Second, "(optional) longest wait for new outbox jobs. for example: -i 5s")
+	queueRelayCommand.Flags().Bool("once", false, "(optional) relay the jobs waiting in the outbox and exit")
+	queueRelayCommand.Example = "  queue:relay"
+	queueRelayCommand.Example += "\n  queue:relay -b 500 -i 5s"
+	queueRelayCommand.Example += "\n  queue:relay --once"
+
 	queueRestoreCommand.Flags().StringP("queue", "q", "default", "(optional) queue name. for example: -q emails")
 	queueRestoreCommand.Example = "  queue:restore"
 	queueRestoreCommand.Example += "\n  queue:restore -q emails"
+
+	queuePauseCommand.Flags().StringP("queue", "q", "default", "(optional) queue name. for example: -q emails")
+	queuePauseCommand.Example = "  queue:pause"
+	queuePauseCommand.Example += "\n  queue:pause -q emails"
+
+	queueResumeCommand.Flags().StringP("queue", "q", "default", "(optional) queue name. for example: -q emails")
+	queueResumeCommand.Example = "  queue:resume"
+	queueResumeCommand.Example += "\n  queue:resume -q emails"
+
+	queueMonitorCommand.Flags().StringP("queue", "q", "", "(optional) comma separated queue names. default is every queue")
+	queueMonitorCommand.Flags().IntP("interval", "i", 5, "(optional) seconds between refreshes")
+	queueMonitorCommand.Flags().IntP("failures", "f", 5, "(optional) number of latest failed jobs to show")
+	queueMonitorCommand.Flags().Bool("once", false, "(optional) print the queues once and exit")
+	queueMonitorCommand.Flags().Int64("max-pending", 0, "(optional) exit with status 1 when a queue has more pending jobs")
+	queueMonitorCommand.Flags().Int64("max-failed", 0, "(optional) exit with status 1 when a queue has more failed jobs")
+	queueMonitorCommand.Flags().Int64("max-failures-per-minute", 0, "(optional) exit with status 1 when a queue fails more jobs in the last minute")
+	queueMonitorCommand.Example = "  queue:monitor"
+	queueMonitorCommand.Example += "\n  queue:monitor -q critical,default -i 2"
+	queueMonitorCommand.Example += "\n  queue:monitor --once --max-pending 1000 --max-failures-per-minute 10"
 }
 
 var queueWorkCommand = &cobra.Command{
@@ -67,10 +122,29 @@ var queueWorkCommand = &cobra.Command{
 		// Setup all the required dependencies
 		setupAll()
 
-		queueName, _ := cmd.Flags().GetString("queue")
+		queueSpec, _ := cmd.Flags().GetString("queue")
 		numberOfWorkers, _ := cmd.Flags().GetInt("worker")
+		strategy, _ := cmd.Flags().GetString("strategy")
+		metricsPort, _ := cmd.Flags().GetInt("metrics-port")
+		if metricsPort == 0 {
+			metricsPort = config.GetConfig().Queue.MetricsPort
+		}
+
+		queueWeights, err := queue.ParseQueueWeights(queueSpec)
+		if err != nil {
+			logger.Log.Fatal("Cannot parse queue names", zap.Error(err))
+		}
+
+		pool, err := queue.NewWorkerPool(queueWeights, strategy)
+		if err != nil {
+			logger.Log.Fatal("Cannot create queue workers", zap.Error(err))
+		}
+
+		if err := pool.CheckHandlers(cmd.Context()); err != nil {
+			logger.Log.Fatal("Unfinished jobs reference unregistered handlers", zap.Error(err))
+		}
 
-		logger.Log.Info(fmt.Sprintf("Starting %d queue workers for queue %s", numberOfWorkers, queueName))
+		logger.Log.Info(fmt.Sprintf("Starting %d queue workers for queue %s (%s)", numberOfWorkers, queueSpec, pool.Strategy()))
 
 		// Create a context that gets canceled when the program receives a termination signal.
 		ctx, cancel := context.WithCancel(context.Background())
@@ -85,7 +159,15 @@ var queueWorkCommand = &cobra.Command{
 			cancel()
 		}()
 
-		q := queue.NewQueue(queueName)
+		if metricsPort > 0 {
+			queue.RegisterMetrics()
+			go func() {
+				logger.Log.Info(fmt.Sprintf("Serving metrics on http://localhost:%d/metrics", metricsPort))
+				if err := metrics.Serve(ctx, metricsPort); err != nil {
+					logger.Log.Error("Metrics server stopped with error", zap.Error(err))
+				}
+			}()
+		}
 
 		var wg sync.WaitGroup
 		wg.Add(numberOfWorkers)
@@ -93,7 +175,7 @@ var queueWorkCommand = &cobra.Command{
 		for i := 0; i < numberOfWorkers; i++ {
 			go func() {
 				defer wg.Done()
-				err := q.Run(ctx)
+				err := pool.Run(ctx)
 				if err != nil && err != context.Canceled {
 					logger.Log.Error("Queue worker stopped with error", zap.Error(err))
 				} else {
@@ -248,6 +330,136 @@ var queueForgetCommand = &cobra.Command{
 	},
 }
 
+var queueCancelCommand = &cobra.Command{
+	Use:     "queue:cancel",
+	Short:   "Cancel a pending, delayed or running job",
+	GroupID: "queue",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Setup all the required dependencies
+		setupAll()
+
+		jobID, _ := cmd.Flags().GetString("id")
+		id, err := uuid.Parse(jobID)
+		if err != nil {
+			logger.Log.Error("Cannot parse job id", zap.Error(err))
+			return
+		}
+
+		status, err := queue.Cancel(ctx, id)
+		if err != nil {
+			logger.Log.Error("Queue cancel failed", zap.Error(err))
+			return
+		}
+
+		if status == job.StatusCancelled {
+			logger.Log.Info(fmt.Sprintf("Queue cancel completed. Job %s cancelled", jobID))
+		} else {
+			logger.Log.Info(fmt.Sprintf("Queue cancel requested. Job %s is running and stops once its handler returns", jobID))
+		}
+	},
+}
+
+var queuePruneCommand = &cobra.Command{
+	Use:     "queue:prune",
+	Short:   "Delete old completed, failed, cancelled and expired jobs from the database",
+	GroupID: "queue",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Setup all the required dependencies
+		setupAll()
+
+		opts, err := queue.PruneOptionsFromConfig()
+		if err != nil {
+			logger.Log.Fatal("Invalid prune config", zap.Error(err))
+		}
+
+		if cmd.Flags().Changed("completed-older-than") {
+			value, _ := cmd.Flags().GetString("completed-older-than")
+			if opts.CompletedOlderThan, err = queue.ParseAge(value); err != nil {
+				logger.Log.Fatal("Invalid --completed-older-than", zap.Error(err))
+			}
+		}
+		if cmd.Flags().Changed("failed-older-than") {
+			value, _ := cmd.Flags().GetString("failed-older-than")
+			if opts.FailedOlderThan, err = queue.ParseAge(value); err != nil {
+				logger.Log.Fatal("Invalid --failed-older-than", zap.Error(err))
+			}
+		}
+		if cmd.Flags().Changed("batch-size") {
+			opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+		}
+		if cmd.Flags().Changed("archive") {
+			opts.Archive, _ = cmd.Flags().GetBool("archive")
+		}
+		if cmd.Flags().Changed("bucket") {
+			opts.ArchiveBucket, _ = cmd.Flags().GetString("bucket")
+		}
+
+		if opts.CompletedOlderThan == 0 && opts.FailedOlderThan == 0 {
+			logger.Log.Info("Nothing to prune, give --completed-older-than or --failed-older-than")
+			return
+		}
+
+		result, err := queue.Prune(ctx, opts)
+		if err != nil {
+			logger.Log.Error("Queue prune failed", zap.Error(err), zap.Int64("completed", result.Completed), zap.Int64("failed", result.Failed))
+			return
+		}
+
+		logger.Log.Info(fmt.Sprintf("Queue prune completed. %d completed and %d failed, cancelled or expired jobs deleted", result.Completed, result.Failed))
+		for _, object := range result.Archives {
+			logger.Log.Info(fmt.Sprintf("Archived to %s/%s", opts.ArchiveBucket, object))
+		}
+	},
+}
+
+var queueRelayCommand = &cobra.Command{
+	Use:     "queue:relay",
+	Short:   "Enqueue the jobs added to the outbox within committed database transactions",
+	GroupID: "queue",
+	Run: func(cmd *cobra.Command, _ []string) {
+		// Setup all the required dependencies
+		setupAll()
+
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		pollInterval, _ := cmd.Flags().GetDuration("poll-interval")
+		once, _ := cmd.Flags().GetBool("once")
+
+		if batchSize < 1 {
+			batchSize = 100
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		if once {
+			total := 0
+			for {
+				relayed, err := queue.RelayOnce(ctx, batchSize)
+				total += relayed
+				if err != nil {
+					logger.Log.Error("Queue relay failed", zap.Error(err))
+					os.Exit(1)
+				}
+				if relayed < batchSize {
+					break
+				}
+			}
+
+			logger.Log.Info(fmt.Sprintf("Queue relay completed. %d jobs relayed", total))
+			return
+		}
+
+		logger.Log.Info("Relaying outbox jobs... (press Ctrl+C to quit)")
+		if err := queue.Relay(ctx, queue.RelayOptions{BatchSize: batchSize, PollInterval: pollInterval}); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Log.Error("Queue relay stopped", zap.Error(err))
+		}
+	},
+}
+
 var queueRestoreCommand = &cobra.Command{
 	Use:     "queue:restore",
 	Short:   "Restore a failed and unfinished job to the redis queue",
@@ -261,51 +473,165 @@ var queueRestoreCommand = &cobra.Command{
 		queueName, _ := cmd.Flags().GetString("queue")
 		q := queue.NewQueue(queueName)
 
-		repo := repository.NewRepository()
-
-		// Get all pending jobs and restore them to the redis queue
-		unfinishedJobs, err := repo.Job.GetUnfinishedJobs(ctx)
+		restored, err := q.Restore(ctx)
 		if err != nil {
-			logger.Log.Error("Get pending jobs error", zap.Error(err))
+			logger.Log.Error("Restore queue error", zap.Error(err))
 			return
 		}
 
-		if len(unfinishedJobs) == 0 {
-			logger.Log.Info("No unfinished jobs found")
-			return
+		logger.Log.Info(fmt.Sprintf("Queue restore completed. %d jobs restored to queue %s", restored, queueName))
+	},
+}
+
+var queuePauseCommand = &cobra.Command{
+	Use:     "queue:pause",
+	Short:   "Stop workers from processing jobs of the specified queue",
+	GroupID: "queue",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+
+		// Setup all the required dependencies
+		setupAll()
+
+		queueName, _ := cmd.Flags().GetString("queue")
+
+		if err := queue.NewQueue(queueName).Pause(ctx); err != nil {
+			logger.Log.Error("Queue pause failed", zap.Error(err))
+		} else {
+			logger.Log.Info(fmt.Sprintf("Queue %s paused", queueName))
 		}
+	},
+}
 
-		if len(unfinishedJobs) > 0 {
-			logger.Log.Info(fmt.Sprintf("Found %d unfinished jobs", len(unfinishedJobs)))
+var queueResumeCommand = &cobra.Command{
+	Use:     "queue:resume",
+	Short:   "Resume processing jobs of a paused queue",
+	GroupID: "queue",
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
 
-			// reset all processing jobs to pending
-			err = repo.Job.ResetProcessingJobsToPending(ctx)
+		// Setup all the required dependencies
+		setupAll()
+
+		queueName, _ := cmd.Flags().GetString("queue")
+
+		if err := queue.NewQueue(queueName).Resume(ctx); err != nil {
+			logger.Log.Error("Queue resume failed", zap.Error(err))
+		} else {
+			logger.Log.Info(fmt.Sprintf("Queue %s resumed", queueName))
+		}
+	},
+}
+
+var queueMonitorCommand = &cobra.Command{
+	Use:     "queue:monitor",
+	Short:   "Show the jobs of the queues, refreshed every few seconds",
+	GroupID: "queue",
+	Run: func(cmd *cobra.Command, _ []string) {
+		// Setup all the required dependencies
+		setupAll()
+
+		queueNames, _ := cmd.Flags().GetString("queue")
+		interval, _ := cmd.Flags().GetInt("interval")
+		failures, _ := cmd.Flags().GetInt("failures")
+		once, _ := cmd.Flags().GetBool("once")
+
+		var thresholds queue.MonitorThresholds
+		thresholds.MaxPending, _ = cmd.Flags().GetInt64("max-pending")
+		thresholds.MaxFailed, _ = cmd.Flags().GetInt64("max-failed")
+		thresholds.MaxFailuresPerMinute, _ = cmd.Flags().GetInt64("max-failures-per-minute")
+
+		var names []string
+		for _, name := range strings.Split(queueNames, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
 		}
 
-		for _, j := range unfinishedJobs {
-			jobItem, err := job.NewJob(
-				j.HandlerName,
-				j.Payload,
-				j.MaxAttempts,
-				j.Delay,
-			)
+		if interval < 1 {
+			interval = 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			snapshot, err := queue.TakeMonitorSnapshot(ctx, names, failures)
 			if err != nil {
-				logger.Log.Error("New job error", zap.Error(err))
-				continue
+				logger.Log.Error("Queue monitor failed", zap.Error(err))
+				os.Exit(1)
 			}
 
-			if j.Status == job.StatusFailed {
-				err = q.EnqueueFailedJobs(ctx, jobItem)
-			} else if j.Status == job.StatusPending {
-				err = q.EnqueuePendingJobs(ctx, jobItem)
+			if !once {
+				// Clear the terminal before drawing the next refresh
+				fmt.Print("\033[H\033[2J")
+				fmt.Printf("Queue monitor - %s, refreshed every %ds, press Ctrl+C to quit\n", snapshot.TakenAt.Format(time.DateTime), interval)
 			}
+			printQueueMonitor(snapshot)
 
-			if err != nil {
-				logger.Log.Error("Restore job error", zap.Error(err))
-			} else {
-				logger.Log.Info(fmt.Sprintf("Queue restore completed. Job %s restored to queue %s", j.ID, queueName))
+			if breaches := thresholds.Breaches(snapshot); len(breaches) > 0 {
+				for _, breach := range breaches {
+					fmt.Println("Threshold breached:", breach)
+				}
+				os.Exit(1)
 			}
-		}
 
+			if once {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
 	},
 }
+
+func printQueueMonitor(snapshot queue.MonitorSnapshot) {
+	queueTable := table.NewWriter()
+	queueTable.SetOutputMirror(os.Stdout)
+	queueTable.AppendHeader(table.Row{"Queue", "Status", "Pending", "Processing", "Delayed", "Failed", "Enqueued/min", "Completed/min", "Failed/min"})
+	for _, stats := range snapshot.Queues {
+		status := "running"
+		if stats.Paused {
+			status = "paused"
+		}
+
+		queueTable.AppendRow(table.Row{
+			stats.Name,
+			status,
+			stats.Counts.Pending,
+			stats.Counts.Processing,
+			stats.Counts.Delayed,
+			stats.Counts.Failed,
+			stats.Activity.Enqueued,
+			stats.Activity.Completed,
+			stats.Activity.Failed,
+		})
+	}
+	queueTable.Render()
+
+	if len(snapshot.Failures) == 0 {
+		return
+	}
+
+	failureTable := table.NewWriter()
+	failureTable.SetOutputMirror(os.Stdout)
+	failureTable.SetTitle("Latest failures")
+	failureTable.AppendHeader(table.Row{"Failed At", "Queue", "Job ID", "Error"})
+	failureTable.SetColumnConfigs([]table.ColumnConfig{{Name: "Error", WidthMax: 80}})
+	for _, failedJob := range snapshot.Failures {
+		failureTable.AppendRow(table.Row{
+			failedJob.FailedAt.Format(time.DateTime),
+			failedJob.Queue,
+			failedJob.JobID,
+			failedJob.Error,
+		})
+	}
+	failureTable.Render()
+}
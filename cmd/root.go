@@ -14,6 +14,7 @@ import (
 	"webapi/internal/db/pgx"
 	"webapi/internal/db/rdb"
 	"webapi/internal/logger"
+	internal_minio "webapi/pkg/minio"
 )
 
 const defaultConfigFile = "config/config.yaml"
@@ -41,6 +42,7 @@ func setupAll() {
 	setUpLogger()
 	setUpPostgres()
 	setUpRedis()
+	setUpMinio()
 	setUpSentry()
 }
 
@@ -105,6 +107,19 @@ func setUpRedis() {
 
 }
 
+func setUpMinio() {
+	// Don't initialize minio if it is not enabled
+	if !config.GetConfig().Minio.Enable {
+		return
+	}
+
+	logger.Log.Info("Initializing minio")
+	if err := internal_minio.Setup(); err != nil {
+		logger.Log.Fatal("internal_minio.Setup()", zap.Error(err))
+	}
+	logger.Log.Info("minio initialized")
+}
+
 func setUpSentry() {
 	// Don't initialize sentry if DSN is not set
 	if config.GetConfig().Sentry.Dsn == "" {
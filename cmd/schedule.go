@@ -1,12 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/lnquy/cron"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 	"webapi/config"
+	"webapi/internal/db/model"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
 	"webapi/internal/scheduler"
 )
 
@@ -15,7 +26,27 @@ func init() {
 	rootCmd.AddCommand(
 		listScheduleCommand,
 		startScheduleCommand,
+		scheduleHistoryCommand,
+		scheduleRunTaskCommand,
+		scheduleNextCommand,
 	)
+
+	scheduleNextCommand.Flags().IntP("count", "c", 5, "(optional) number of upcoming runs to show per task")
+	scheduleNextCommand.Flags().Bool("all", false, "(optional) include the disabled schedules")
+	scheduleNextCommand.Flags().Bool("json", false, "(optional) print JSON instead of a table")
+	scheduleNextCommand.Flags().Bool("lint", false, "(optional) report the schedules that never fire or fire more often than every minute, exit with status 1 when there is one")
+	scheduleNextCommand.Flags().Bool("from-config", false, "(optional) read the schedules of the config instead of the database")
+	scheduleNextCommand.Example = "  schedule:next"
+	scheduleNextCommand.Example += "\n  schedule:next --count 10 --json"
+	scheduleNextCommand.Example += "\n  schedule:next --lint --from-config"
+
+	scheduleRunTaskCommand.Example = "  schedule:run-task PruneJobs"
+
+	scheduleHistoryCommand.Flags().StringP("task", "t", "", "(optional) task name. default is every task")
+	scheduleHistoryCommand.Flags().IntP("limit", "l", 20, "(optional) number of runs to show")
+	scheduleHistoryCommand.Flags().IntP("page", "p", 1, "(optional) page of runs to show, latest first")
+	scheduleHistoryCommand.Example = "  schedule:history"
+	scheduleHistoryCommand.Example += "\n  schedule:history -t PruneJobs -l 50"
 }
 
 var startScheduleCommand = &cobra.Command{
@@ -26,8 +57,17 @@ var startScheduleCommand = &cobra.Command{
 		// Setup all the required dependencies
 		setupAll()
 
-		printScheduleList()
-		scheduler.Start()
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		if err := scheduler.LoadTimezone(); err != nil {
+			logger.Log.Fatal("Invalid scheduler config", zap.Error(err))
+		}
+		printScheduleList(loadSchedules(ctx))
+
+		if err := scheduler.Start(ctx); err != nil {
+			logger.Log.Fatal("Cannot start the scheduler", zap.Error(err))
+		}
 	},
 }
 
@@ -35,34 +75,313 @@ var listScheduleCommand = &cobra.Command{
 	Use:     "schedule:list",
 	Short:   "List all schedule jobs",
 	GroupID: "schedule",
-	Run: func(_ *cobra.Command, _ []string) {
+	Run: func(cmd *cobra.Command, _ []string) {
+		// Setup all the required dependencies
+		setUpConfig()
+		setUpLogger()
+		setUpPostgres()
+
+		if err := scheduler.LoadTimezone(); err != nil {
+			logger.Log.Fatal("Invalid scheduler config", zap.Error(err))
+		}
+		schedules := loadSchedules(cmd.Context())
+		printScheduleList(schedules)
+
+		if err := scheduler.Validate(schedules); err != nil {
+			logger.Log.Error("Invalid schedules", zap.Error(err))
+			os.Exit(1)
+		}
+	},
+}
+
+var scheduleHistoryCommand = &cobra.Command{
+	Use:     "schedule:history",
+	Short:   "List the latest runs of the scheduled tasks",
+	GroupID: "schedule",
+	Run: func(cmd *cobra.Command, _ []string) {
 		// Setup all the required dependencies
 		setUpConfig()
 		setUpLogger()
+		setUpPostgres()
+
+		if err := scheduler.LoadTimezone(); err != nil {
+			logger.Log.Fatal("Invalid scheduler config", zap.Error(err))
+		}
+
+		task, _ := cmd.Flags().GetString("task")
+		limit, _ := cmd.Flags().GetInt("limit")
+		page, _ := cmd.Flags().GetInt("page")
+		if limit <= 0 || page <= 0 {
+			logger.Log.Fatal("--limit and --page must be positive")
+		}
+
+		runs, total, err := repository.NewRepository().ScheduleRun.GetScheduleRuns(cmd.Context(), task, int64((page-1)*limit), int64(limit))
+		if err != nil {
+			logger.Log.Fatal("Cannot get the schedule history", zap.Error(err))
+		}
+
+		tableWriter := table.NewWriter()
+		tableWriter.SetOutputMirror(os.Stdout)
+		tableWriter.AppendHeader(table.Row{"ID", "Task", "Status", "Started At", "Duration", "Host", "Error"})
+		for _, run := range runs {
+			duration := "-"
+			if run.DurationMs != nil {
+				duration = (time.Duration(*run.DurationMs) * time.Millisecond).String()
+			}
+
+			tableWriter.AppendRow(table.Row{
+				run.ID,
+				run.Task,
+				run.Status,
+				run.StartedAt.In(scheduler.Timezone).Format(time.DateTime),
+				duration,
+				run.Host,
+				run.Error,
+			})
+		}
+		tableWriter.AppendFooter(table.Row{"", "", "", "", "", "Total", total})
+		tableWriter.Render()
+	},
+}
+
+var scheduleRunTaskCommand = &cobra.Command{
+	Use:     "schedule:run-task <name>",
+	Short:   "Run a registered task once, right now",
+	GroupID: "schedule",
+	Args:    cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		// Setup all the required dependencies
+		setupAll()
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		err := scheduler.RunTask(ctx, args[0])
+		if errors.Is(err, scheduler.ErrTaskNotRegistered) {
+			logger.Log.Error("Unknown task", zap.String("task", args[0]), zap.Strings("registered", scheduler.TaskNames()))
+			os.Exit(1)
+		}
+		if errors.Is(err, scheduler.ErrTaskLocked) {
+			logger.Log.Error("Task is running on another instance", zap.String("task", args[0]))
+			os.Exit(1)
+		}
+		if err != nil {
+			// the error was logged by the scheduler
+			os.Exit(1)
+		}
+	},
+}
+
+var scheduleNextCommand = &cobra.Command{
+	Use:     "schedule:next",
+	Short:   "Show the upcoming runs of the schedules",
+	GroupID: "schedule",
+	Run: func(cmd *cobra.Command, _ []string) {
+		count, _ := cmd.Flags().GetInt("count")
+		all, _ := cmd.Flags().GetBool("all")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		lint, _ := cmd.Flags().GetBool("lint")
+		fromConfig, _ := cmd.Flags().GetBool("from-config")
+
+		// Setup all the required dependencies
+		setUpConfig()
+		setUpLogger()
+
+		if err := scheduler.LoadTimezone(); err != nil {
+			logger.Log.Fatal("Invalid scheduler config", zap.Error(err))
+		}
+
+		var schedules []model.Schedule
+		if fromConfig {
+			schedules = scheduler.FromConfig(config.GetConfig().Schedules)
+		} else {
+			setUpPostgres()
+			schedules = loadSchedules(cmd.Context())
+		}
+
+		now := time.Now()
+		if lint {
+			if !printScheduleLint(schedules, now, asJSON) {
+				os.Exit(1)
+			}
+			return
+		}
 
-		printScheduleList()
+		if count <= 0 {
+			logger.Log.Fatal("--count must be positive")
+		}
 
+		var upcoming []upcomingRuns
+		for _, schedule := range schedules {
+			if !schedule.IsEnabled && !all {
+				continue
+			}
+
+			runs, err := scheduler.NextRuns(schedule, now, count)
+			if err != nil {
+				logger.Log.Error("Invalid schedule", zap.String("task", schedule.Task), zap.Error(err))
+				continue
+			}
+
+			u := upcomingRuns{Task: schedule.Task, Cron: schedule.Cron, Timezone: schedule.Timezone, Enabled: schedule.IsEnabled, Runs: []upcomingRun{}}
+			for _, run := range runs {
+				u.Runs = append(u.Runs, upcomingRun{Local: run.In(scheduler.Timezone), UTC: run.UTC()})
+			}
+			upcoming = append(upcoming, u)
+		}
+
+		if asJSON {
+			printJSON(upcoming)
+			return
+		}
+
+		tableWriter := table.NewWriter()
+		tableWriter.SetOutputMirror(os.Stdout)
+		tableWriter.AppendHeader(table.Row{"Task", "Cron Expression", "Run", "Time (" + scheduler.Timezone.String() + ")", "Time (UTC)"})
+		for _, u := range upcoming {
+			if len(u.Runs) == 0 {
+				tableWriter.AppendRow(table.Row{u.Task, u.Cron, "-", "never fires", "never fires"})
+			}
+			for i, run := range u.Runs {
+				tableWriter.AppendRow(table.Row{u.Task, u.Cron, i + 1, run.Local.Format(time.DateTime), run.UTC.Format(time.DateTime)})
+			}
+			tableWriter.AppendSeparator()
+		}
+		tableWriter.Render()
 	},
 }
 
-func printScheduleList() {
+// upcomingRuns are the next runs of a schedule printed by schedule:next.
+type upcomingRuns struct {
+	Task     string        `json:"task"`
+	Cron     string        `json:"cron"`
+	Timezone string        `json:"timezone"`
+	Enabled  bool          `json:"enabled"`
+	Runs     []upcomingRun `json:"runs"`
+}
+
+type upcomingRun struct {
+	Local time.Time `json:"local"` // in scheduler.timezone
+	UTC   time.Time `json:"utc"`
+}
+
+// scheduleLint is a schedule with problems printed by schedule:next --lint.
+type scheduleLint struct {
+	Task     string   `json:"task"`
+	Cron     string   `json:"cron"`
+	Problems []string `json:"problems"`
+}
+
+// printScheduleLint prints the problems of the schedules and returns whether there were none.
+func printScheduleLint(schedules []model.Schedule, now time.Time, asJSON bool) bool {
+	lints := []scheduleLint{}
+	for _, schedule := range schedules {
+		if problems := scheduler.Lint(schedule, now); len(problems) > 0 {
+			lints = append(lints, scheduleLint{Task: schedule.Task, Cron: schedule.Cron, Problems: problems})
+		}
+	}
+
+	if asJSON {
+		printJSON(lints)
+		return len(lints) == 0
+	}
+
+	if len(lints) == 0 {
+		fmt.Printf("No problems found in %d schedules\n", len(schedules))
+		return true
+	}
+
+	tableWriter := table.NewWriter()
+	tableWriter.SetOutputMirror(os.Stdout)
+	tableWriter.AppendHeader(table.Row{"Task", "Cron Expression", "Problem"})
+	for _, lint := range lints {
+		for _, problem := range lint.Problems {
+			tableWriter.AppendRow(table.Row{lint.Task, lint.Cron, problem})
+		}
+	}
+	tableWriter.Render()
+
+	return false
+}
+
+func printJSON(v any) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		logger.Log.Fatal("Cannot encode the output", zap.Error(err))
+	}
+	fmt.Println(string(out))
+}
+
+// loadSchedules seeds the schedules of the config that are not in the database yet and returns the schedules of the database.
+func loadSchedules(ctx context.Context) []model.Schedule {
+	seeded, err := scheduler.Seed(ctx)
+	if err != nil {
+		logger.Log.Fatal("Cannot seed the schedules", zap.Error(err))
+	}
+	if seeded > 0 {
+		logger.Log.Info("Seeded schedules from the config", zap.Int64("count", seeded))
+	}
+
+	schedules, err := scheduler.Schedules(ctx)
+	if err != nil {
+		logger.Log.Fatal("Cannot load the schedules", zap.Error(err))
+	}
+
+	return schedules
+}
+
+func printScheduleList(schedules []model.Schedule) {
 	exprDesc, _ := cron.NewDescriptor()
 
 	// Print the job list as a table in the console
 	tableWriter := table.NewWriter()
 	tableWriter.SetOutputMirror(os.Stdout)
-	tableWriter.AppendHeader(table.Row{"No.", "Job Name", "Cron Expression", "Schedule"})
-	for i, schedule := range config.GetConfig().Schedules {
+	tableWriter.AppendHeader(table.Row{"No.", "Job Name", "Cron Expression", "Schedule", "Timezone", "Jitter", "Misfire", "Next Run", "Enabled", "Registered"})
+	for i, schedule := range schedules {
 		desc, _ := exprDesc.ToDescription(schedule.Cron, cron.Locale_en)
+		if _, err := scheduler.ParseCron(schedule.Cron); err != nil {
+			desc = "invalid cron expression"
+		}
+
+		timezone := schedule.Timezone
+		if timezone == "" {
+			timezone = config.GetConfig().Scheduler.Timezone
+		}
+
+		nextRun := "-"
+		if runs, err := scheduler.NextRuns(schedule, time.Now(), 1); err == nil && len(runs) > 0 {
+			nextRun = runs[0].In(scheduler.Timezone).Format(time.DateTime)
+		}
+
+		misfire := schedule.MisfirePolicy
+		if misfire == "" {
+			misfire = scheduler.MisfireSkip
+		}
+		if misfire == scheduler.MisfireRunAll && schedule.MisfireLimit > 0 {
+			misfire = fmt.Sprintf("%s (%d)", misfire, schedule.MisfireLimit)
+		}
 
 		tableWriter.AppendRow(table.Row{
 			i + 1,
-			schedule.Job,
+			schedule.Task,
 			schedule.Cron,
 			desc,
+			timezone,
+			(time.Duration(schedule.Jitter) * time.Second).String(),
+			misfire,
+			nextRun,
+			yesNo(schedule.IsEnabled),
+			yesNo(scheduler.IsRegistered(schedule.Task)),
 		})
 
 	}
 
 	tableWriter.Render()
 }
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"webapi/config"
+	"webapi/internal/helper/queue"
 	"webapi/internal/http/validation"
 	"webapi/internal/logger"
 	"webapi/internal/router"
@@ -30,6 +31,9 @@ var serveAPICmd = &cobra.Command{
 		// Setup all the required dependencies
 		setupAll()
 
+		// Report the queue depths on /metrics
+		queue.RegisterMetrics()
+
 		// Create controllers router
 		r := router.NewFiberRouter()
 
@@ -18,6 +18,7 @@ type Config struct {
 	Log        Log        `yaml:"log"`
 	Scheduler  Scheduler  `yaml:"scheduler"`
 	Schedules  []Schedule `yaml:"schedules"`
+	Queue      Queue      `yaml:"queue"`
 	Postgres   Postgres   `yaml:"postgres"`
 	Minio      Minio      `yaml:"minio"`
 	Redis      []Redis    `yaml:"redis"`
@@ -85,13 +86,64 @@ type Sentry struct {
 }
 
 type Scheduler struct {
-	Timezone string `yaml:"timezone"`
+	Timezone       string `yaml:"timezone"`
+	LeaseTTL       int    `yaml:"leaseTTL"`       // seconds a dead leader keeps the lease before another instance takes over, default is 15
+	FailureWebhook string `yaml:"failureWebhook"` // URL the run of a failed task is posted to, signed like job callbacks
+	ReloadInterval int    `yaml:"reloadInterval"` // seconds between reloads of the schedules from the database, default is 30
+}
+
+type Queue struct {
+	Strategy     string          `yaml:"strategy"`     // strict, weighted
+	PollInterval int             `yaml:"pollInterval"` // milliseconds to wait when every queue is empty
+	MetricsPort  int             `yaml:"metricsPort"`  // port serving /metrics for queue:work, 0 disables it
+	Queues       []QueueOptions  `yaml:"queues"`
+	Prune        QueuePrune      `yaml:"prune"`
+	Encryption   QueueEncryption `yaml:"encryption"`
+	Callbacks    QueueCallbacks  `yaml:"callbacks"`
+}
+
+type QueueCallbacks struct {
+	Queue       string `yaml:"queue"`       // queue the deliveries are enqueued to, default is default
+	Secret      string `yaml:"secret"`      // key of the HMAC-SHA256 signature of every delivery
+	MaxAttempts int    `yaml:"maxAttempts"` // default is 5
+	RetryDelay  int    `yaml:"retryDelay"`  // seconds between attempts, default is 30
+	Timeout     int    `yaml:"timeout"`     // seconds to wait for a response, default is 10
+}
+
+type QueueEncryption struct {
+	CurrentKey string          `yaml:"currentKey"` // id of the key new payloads are encrypted with
+	Keys       []EncryptionKey `yaml:"keys"`       // keep retired keys until no job encrypted with them is left
+}
+
+type EncryptionKey struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key"` // base64 encoded 32 byte AES-256 key
+}
+
+type QueuePrune struct {
+	CompletedOlderThan string `yaml:"completedOlderThan"` // for example 7d or 12h, empty keeps completed jobs
+	FailedOlderThan    string `yaml:"failedOlderThan"`    // for example 30d, empty keeps failed, cancelled and expired jobs
+	BatchSize          int    `yaml:"batchSize"`          // jobs deleted per transaction, default is 1000
+	Archive            bool   `yaml:"archive"`            // upload the pruned jobs to minio as gzip compressed JSONL before deleting them
+	ArchiveBucket      string `yaml:"archiveBucket"`      // default is minio.bucketName
+}
+
+type QueueOptions struct {
+	Name        string `yaml:"name"`
+	Concurrency int    `yaml:"concurrency"` // max jobs of this queue running at once per process, 0 means unlimited
+	Backend     string `yaml:"backend"`     // redis, stream, postgres or memory, default is redis
 }
 
 type Schedule struct {
-	Job       string `yaml:"job"`
-	Cron      string `yaml:"cron"`
-	IsEnabled bool   `yaml:"isEnabled"`
+	Job           string `yaml:"job"`
+	Cron          string `yaml:"cron"`
+	IsEnabled     bool   `yaml:"isEnabled"`
+	Timezone      string `yaml:"timezone"`      // timezone of the cron expression, default is scheduler.timezone
+	Jitter        int    `yaml:"jitter"`        // longest random delay in seconds before each run
+	Lock          bool   `yaml:"lock"`          // never run the task on two instances at once
+	LockTTL       int    `yaml:"lockTTL"`       // seconds the lock of an instance that died while running is kept, default is 3600
+	MisfirePolicy string `yaml:"misfirePolicy"` // runs missed since the last successful run: skip (default), run_once or run_all
+	MisfireLimit  int    `yaml:"misfireLimit"`  // most missed runs run with run_all, default is 10
 }
 
 type Authentication struct {
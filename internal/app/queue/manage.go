@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"webapi/internal/helper/queue"
+	"webapi/internal/job"
+	"webapi/pkg/exception"
+)
+
+type GetQueueJobsDTI struct {
+	Key   string `json:"key"`
+	State string `json:"state"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+type QueueJobDTI struct {
+	Key string    `json:"key"`
+	ID  uuid.UUID `json:"id"`
+}
+
+// CancelJobDTO holds the status of a job after it was cancelled. A running job stays processing
+// until its handler returned.
+type CancelJobDTO struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+}
+
+type GetQueueDetailDTO struct {
+	Key              string            `json:"key"`
+	KeyWithoutPrefix string            `json:"key_without_prefix"`
+	Counts           queue.QueueCounts `json:"counts"`
+	Paused           bool              `json:"paused"`
+}
+
+type GetQueueJobsDTO struct {
+	Total int       `json:"total"`
+	Data  []job.Job `json:"data"`
+}
+
+func (app *queueApp) GetQueueByKey(ctx context.Context, input GetQueueDTI) (GetQueueDetailDTO, error) {
+	q := queue.NewQueue(input.Key)
+
+	counts, err := q.Counts(ctx)
+	if err != nil {
+		return GetQueueDetailDTO{}, err
+	}
+
+	paused, err := q.IsPaused(ctx)
+	if err != nil {
+		return GetQueueDetailDTO{}, err
+	}
+
+	return GetQueueDetailDTO{
+		Key:              q.Key,
+		KeyWithoutPrefix: q.KeyWithoutPrefix,
+		Counts:           counts,
+		Paused:           paused,
+	}, nil
+}
+
+func (app *queueApp) GetQueueJobs(ctx context.Context, input GetQueueJobsDTI) (GetQueueJobsDTO, error) {
+	switch input.State {
+	case queue.StatePending, queue.StateProcessing, queue.StateFailed, queue.StateDelayed:
+	default:
+		return GetQueueJobsDTO{}, exception.InvalidRequestQueryParamError
+	}
+	if input.Page < 1 || input.Limit < 1 {
+		return GetQueueJobsDTO{}, exception.InvalidRequestQueryParamError
+	}
+
+	offset := int64((input.Page - 1) * input.Limit)
+	jobs, total, err := queue.NewQueue(input.Key).ListJobs(ctx, input.State, offset, int64(input.Limit))
+	if err != nil {
+		return GetQueueJobsDTO{}, err
+	}
+
+	return GetQueueJobsDTO{
+		Total: int(total),
+		Data:  jobs,
+	}, nil
+}
+
+func (app *queueApp) RetryFailedJob(ctx context.Context, input QueueJobDTI) error {
+	err := queue.NewQueue(input.Key).RetryFailedByJobID(ctx, input.ID)
+	if errors.Is(err, queue.ErrJobNotFound) {
+		return exception.DataNotFoundError
+	}
+
+	return err
+}
+
+func (app *queueApp) RetryAllFailedJobs(ctx context.Context, input GetQueueDTI) (int, error) {
+	return queue.NewQueue(input.Key).RetryAllFailed(ctx)
+}
+
+func (app *queueApp) ForgetJob(ctx context.Context, input QueueJobDTI) error {
+	err := queue.NewQueue(input.Key).Forget(ctx, input.ID)
+	if errors.Is(err, queue.ErrJobNotFound) {
+		return exception.DataNotFoundError
+	}
+
+	return err
+}
+
+// CancelJob removes a pending or delayed job, or signals a running job to stop.
+func (app *queueApp) CancelJob(ctx context.Context, id uuid.UUID) (CancelJobDTO, error) {
+	status, err := queue.Cancel(ctx, id)
+	if errors.Is(err, queue.ErrJobNotFound) {
+		return CancelJobDTO{}, exception.DataNotFoundError
+	}
+	if errors.Is(err, queue.ErrJobNotCancellable) {
+		return CancelJobDTO{}, exception.JobAlreadyFinishedError
+	}
+	if err != nil {
+		return CancelJobDTO{}, err
+	}
+
+	return CancelJobDTO{ID: id, Status: status}, nil
+}
+
+func (app *queueApp) ClearQueue(ctx context.Context, input GetQueueDTI) (int64, error) {
+	return queue.NewQueue(input.Key).Clear(ctx)
+}
+
+func (app *queueApp) FlushFailedJobs(ctx context.Context, input GetQueueDTI) (int64, error) {
+	return queue.NewQueue(input.Key).RemoveAllFailed(ctx)
+}
+
+func (app *queueApp) RestoreQueue(ctx context.Context, input GetQueueDTI) (int, error) {
+	return queue.NewQueue(input.Key).Restore(ctx)
+}
+
+func (app *queueApp) PauseQueue(ctx context.Context, input GetQueueDTI) error {
+	return queue.NewQueue(input.Key).Pause(ctx)
+}
+
+func (app *queueApp) ResumeQueue(ctx context.Context, input GetQueueDTI) error {
+	return queue.NewQueue(input.Key).Resume(ctx)
+}
@@ -2,14 +2,33 @@ package queue
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"webapi/internal/helper/queue"
+	"webapi/internal/job"
 	"webapi/internal/repository"
+	"webapi/pkg/exception"
 )
 
 type QueueApp interface {
 	GetQueues(ctx context.Context) ([]GetQueueDTO, error)
-	// GetQueueByID(GetQueueDTI) (GetQueueDTO, error)
+	GetQueueByKey(ctx context.Context, input GetQueueDTI) (GetQueueDetailDTO, error)
+	GetQueueJobs(ctx context.Context, input GetQueueJobsDTI) (GetQueueJobsDTO, error)
+	RetryFailedJob(ctx context.Context, input QueueJobDTI) error
+	RetryAllFailedJobs(ctx context.Context, input GetQueueDTI) (int, error)
+	ForgetJob(ctx context.Context, input QueueJobDTI) error
+	ClearQueue(ctx context.Context, input GetQueueDTI) (int64, error)
+	FlushFailedJobs(ctx context.Context, input GetQueueDTI) (int64, error)
+	RestoreQueue(ctx context.Context, input GetQueueDTI) (int, error)
+	PauseQueue(ctx context.Context, input GetQueueDTI) error
+	ResumeQueue(ctx context.Context, input GetQueueDTI) error
+	GetBatchByID(ctx context.Context, id uuid.UUID) (GetBatchDTO, error)
+	GetJobByID(ctx context.Context, id uuid.UUID) (GetJobDTO, error)
+	CancelJob(ctx context.Context, id uuid.UUID) (CancelJobDTO, error)
 }
 
 type queueApp struct {
@@ -30,6 +49,39 @@ type GetQueueDTO struct {
 	Key              string `json:"key,omitempty"`
 	KeyWithoutPrefix string `json:"key_without_prefix,omitempty"`
 	NumberOfItems    int64  `json:"number_of_items"`
+	Paused           bool   `json:"paused"`
+}
+
+type GetBatchDTO struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Queue         string     `json:"queue"`
+	TotalJobs     int        `json:"total_jobs"`
+	PendingJobs   int        `json:"pending_jobs"`
+	ProcessedJobs int        `json:"processed_jobs"`
+	FailedJobs    int        `json:"failed_jobs"`
+	Progress      int        `json:"progress"` // percentage of processed jobs
+	AllowFailures bool       `json:"allow_failures"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CancelledAt   *time.Time `json:"cancelled_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+}
+
+type GetJobDTO struct {
+	ID              uuid.UUID       `json:"id"`
+	Queue           string          `json:"queue"`
+	HandlerName     string          `json:"handler_name"`
+	Codec           string          `json:"codec"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	MaxAttempts     int             `json:"max_attempts"`
+	Errors          []string        `json:"errors"`
+	Progress        int             `json:"progress"`
+	ProgressMessage string          `json:"progress_message"`
+	Result          json.RawMessage `json:"result"`
+	BatchID         *uuid.UUID      `json:"batch_id"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
 func (app *queueApp) GetQueues(ctx context.Context) ([]GetQueueDTO, error) {
@@ -44,9 +96,74 @@ func (app *queueApp) GetQueues(ctx context.Context) ([]GetQueueDTO, error) {
 			Key:              q.Key,
 			KeyWithoutPrefix: q.KeyWithoutPrefix,
 			NumberOfItems:    q.NumberOfItems,
+			Paused:           q.Paused,
 		}
 		queues = append(queues, queue)
 	}
 
 	return queues, nil
 }
+
+func (app *queueApp) GetBatchByID(ctx context.Context, id uuid.UUID) (GetBatchDTO, error) {
+	batch, err := app.Repo.JobBatch.GetBatchByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return GetBatchDTO{}, exception.DataNotFoundError
+	}
+	if err != nil {
+		return GetBatchDTO{}, err
+	}
+
+	processedJobs := batch.TotalJobs - batch.PendingJobs
+	progress := 0
+	if batch.TotalJobs > 0 {
+		progress = processedJobs * 100 / batch.TotalJobs
+	}
+
+	return GetBatchDTO{
+		ID:            batch.ID,
+		Name:          batch.Name,
+		Queue:         batch.Queue,
+		TotalJobs:     batch.TotalJobs,
+		PendingJobs:   batch.PendingJobs,
+		ProcessedJobs: processedJobs,
+		FailedJobs:    batch.FailedJobs,
+		Progress:      progress,
+		AllowFailures: batch.AllowFailures,
+		CreatedAt:     batch.CreatedAt,
+		CancelledAt:   batch.CancelledAt,
+		FinishedAt:    batch.FinishedAt,
+	}, nil
+}
+
+func (app *queueApp) GetJobByID(ctx context.Context, id uuid.UUID) (GetJobDTO, error) {
+	j, err := app.Repo.Job.GetJobByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return GetJobDTO{}, exception.DataNotFoundError
+	}
+	if err != nil {
+		return GetJobDTO{}, err
+	}
+
+	// The result is stored with the codec of the job, the caller polling for it gets it decoded
+	result, err := (&job.Job{ID: j.ID, Codec: j.Codec}).DecodeResult(j.Result)
+	if err != nil {
+		return GetJobDTO{}, err
+	}
+
+	return GetJobDTO{
+		ID:              j.ID,
+		Queue:           j.Queue,
+		HandlerName:     j.HandlerName,
+		Codec:           j.Codec,
+		Status:          j.Status,
+		Attempts:        j.Attempts,
+		MaxAttempts:     j.MaxAttempts,
+		Errors:          j.Errors,
+		Progress:        j.Progress,
+		ProgressMessage: j.ProgressMessage,
+		Result:          result,
+		BatchID:         j.BatchID,
+		CreatedAt:       j.CreatedAt,
+		UpdatedAt:       j.UpdatedAt,
+	}, nil
+}
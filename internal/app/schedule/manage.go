@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"webapi/internal/db/model"
+	"webapi/internal/http/requests"
+	"webapi/internal/logger"
+	"webapi/internal/scheduler"
+	"webapi/pkg/exception"
+)
+
+func (app *scheduleApp) GetSchedules(ctx context.Context) ([]model.Schedule, error) {
+	return app.Repo.Schedule.GetSchedules(ctx)
+}
+
+func (app *scheduleApp) GetSchedule(ctx context.Context, task string) (model.Schedule, error) {
+	schedule, err := app.Repo.Schedule.GetScheduleByTask(ctx, task)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Schedule{}, exception.DataNotFoundError
+	}
+
+	return schedule, err
+}
+
+func (app *scheduleApp) CreateSchedule(ctx context.Context, input requests.CreateScheduleRequest) (model.Schedule, error) {
+	schedule := model.Schedule{
+		Task:      input.Task,
+		Cron:      input.Cron,
+		Timezone:  input.Timezone,
+		Jitter:    input.Jitter,
+		IsEnabled: input.IsEnabled,
+		Lock:      input.Lock,
+		LockTTL:   input.LockTTL,
+
+		MisfirePolicy: input.MisfirePolicy,
+		MisfireLimit:  input.MisfireLimit,
+	}
+	if err := scheduler.ValidateSchedule(schedule); err != nil {
+		return model.Schedule{}, invalidScheduleError(err)
+	}
+
+	_, err := app.Repo.Schedule.GetScheduleByTask(ctx, input.Task)
+	if err == nil {
+		return model.Schedule{}, exception.ScheduleAlreadyExistsError
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return model.Schedule{}, err
+	}
+
+	schedule, err = app.Repo.Schedule.AddSchedule(ctx, schedule)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	notifyChanged(ctx)
+	return schedule, nil
+}
+
+// UpdateSchedule changes the fields of the schedule given in the input.
+func (app *scheduleApp) UpdateSchedule(ctx context.Context, task string, input requests.UpdateScheduleRequest) (model.Schedule, error) {
+	schedule, err := app.GetSchedule(ctx, task)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	if input.Cron != nil {
+		schedule.Cron = *input.Cron
+	}
+	if input.Timezone != nil {
+		schedule.Timezone = *input.Timezone
+	}
+	if input.Jitter != nil {
+		schedule.Jitter = *input.Jitter
+	}
+	if input.IsEnabled != nil {
+		schedule.IsEnabled = *input.IsEnabled
+	}
+	if input.Lock != nil {
+		schedule.Lock = *input.Lock
+	}
+	if input.LockTTL != nil {
+		schedule.LockTTL = *input.LockTTL
+	}
+	if input.MisfirePolicy != nil {
+		schedule.MisfirePolicy = *input.MisfirePolicy
+	}
+	if input.MisfireLimit != nil {
+		schedule.MisfireLimit = *input.MisfireLimit
+	}
+	if err := scheduler.ValidateSchedule(schedule); err != nil {
+		return model.Schedule{}, invalidScheduleError(err)
+	}
+
+	schedule, err = app.Repo.Schedule.UpdateSchedule(ctx, schedule)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Schedule{}, exception.DataNotFoundError
+	}
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	notifyChanged(ctx)
+	return schedule, nil
+}
+
+func (app *scheduleApp) DeleteSchedule(ctx context.Context, task string) error {
+	err := app.Repo.Schedule.DeleteSchedule(ctx, task)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return exception.DataNotFoundError
+	}
+	if err != nil {
+		return err
+	}
+
+	notifyChanged(ctx)
+	return nil
+}
+
+// notifyChanged makes the running scheduler reload the schedules. When redis is unavailable the change
+// is still picked up at the next scheduler.reloadInterval.
+func notifyChanged(ctx context.Context) {
+	if err := scheduler.NotifyChanged(ctx); err != nil {
+		logger.Log.Warn("Error notifying the scheduler of a schedule change", zap.Error(err))
+	}
+}
+
+func invalidScheduleError(err error) *exception.ExceptionErrors {
+	return exception.NewExceptionErrors(http.StatusUnprocessableEntity, "validation failed").Append(&exception.ExceptionError{
+		Message:      err.Error(),
+		Type:         exception.ERROR_TYPE_VALIDATION_ERROR,
+		ErrorSubcode: exception.SUBCODE_VALIDATION_FAILED,
+	})
+}
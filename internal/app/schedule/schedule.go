@@ -0,0 +1,81 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+
+	"webapi/internal/db/model"
+	"webapi/internal/http/requests"
+	"webapi/internal/repository"
+	"webapi/internal/scheduler"
+	"webapi/pkg/exception"
+)
+
+type ScheduleApp interface {
+	GetScheduleRuns(ctx context.Context, input GetScheduleRunsDTI) (GetScheduleRunsDTO, error)
+	RunTask(ctx context.Context, name string) (RunTaskDTO, error)
+	GetSchedules(ctx context.Context) ([]model.Schedule, error)
+	GetSchedule(ctx context.Context, task string) (model.Schedule, error)
+	CreateSchedule(ctx context.Context, input requests.CreateScheduleRequest) (model.Schedule, error)
+	UpdateSchedule(ctx context.Context, task string, input requests.UpdateScheduleRequest) (model.Schedule, error)
+	DeleteSchedule(ctx context.Context, task string) error
+}
+
+type scheduleApp struct {
+	Repo *repository.Repository
+}
+
+func NewScheduleApp(repo *repository.Repository) ScheduleApp {
+	return &scheduleApp{
+		Repo: repo,
+	}
+}
+
+type GetScheduleRunsDTI struct {
+	Task  string `json:"task"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+type GetScheduleRunsDTO struct {
+	Total int                 `json:"total"`
+	Data  []model.ScheduleRun `json:"data"`
+}
+
+// RunTaskDTO holds the task started by RunTask, its outcome is recorded in the schedule runs.
+type RunTaskDTO struct {
+	Task   string `json:"task"`
+	Status string `json:"status"`
+}
+
+// GetScheduleRuns returns a page of the runs of a task, or of every task when no task is given, latest first.
+func (app *scheduleApp) GetScheduleRuns(ctx context.Context, input GetScheduleRunsDTI) (GetScheduleRunsDTO, error) {
+	runs, total, err := app.Repo.ScheduleRun.GetScheduleRuns(ctx, input.Task, int64((input.Page-1)*input.Limit), int64(input.Limit))
+	if err != nil {
+		return GetScheduleRunsDTO{}, err
+	}
+
+	return GetScheduleRunsDTO{
+		Total: int(total),
+		Data:  runs,
+	}, nil
+}
+
+// RunTask starts a registered task once, in the background, with the lock of its schedule.
+func (app *scheduleApp) RunTask(ctx context.Context, name string) (RunTaskDTO, error) {
+	err := scheduler.StartTask(ctx, name)
+	if errors.Is(err, scheduler.ErrTaskNotRegistered) {
+		return RunTaskDTO{}, exception.DataNotFoundError
+	}
+	if errors.Is(err, scheduler.ErrTaskLocked) {
+		return RunTaskDTO{}, exception.TaskAlreadyRunningError
+	}
+	if err != nil {
+		return RunTaskDTO{}, err
+	}
+
+	return RunTaskDTO{
+		Task:   name,
+		Status: scheduler.RunRunning,
+	}, nil
+}
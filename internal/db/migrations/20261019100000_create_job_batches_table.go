@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, createJobBatchesTable)
+}
+
+var createJobBatchesTable = &Migration{
+	Name: "20261019100000_create_job_batches_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS job_batches (
+			"id" UUID PRIMARY KEY,
+			"name" VARCHAR(255),
+			"queue" VARCHAR(255),
+			"total_jobs" INTEGER NOT NULL DEFAULT 0,
+			"pending_jobs" INTEGER NOT NULL DEFAULT 0,
+			"failed_jobs" INTEGER NOT NULL DEFAULT 0,
+			"allow_failures" BOOLEAN NOT NULL DEFAULT FALSE,
+			"then_job" JSONB,
+			"catch_job" JSONB,
+			"finally_job" JSONB,
+			"created_at" TIMESTAMPTZ DEFAULT NOW(),
+			"cancelled_at" TIMESTAMPTZ,
+			"finished_at" TIMESTAMPTZ
+		  );
+
+		  ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "batch_id" UUID;
+		  ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "chain" JSONB;
+
+		  COMMENT ON COLUMN jobs.chain IS 'The jobs to dispatch one after another once this job succeeds.';
+
+		  CREATE INDEX IF NOT EXISTS idx_jobs_batch_id ON jobs (batch_id);
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP INDEX IF EXISTS idx_jobs_batch_id;
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "chain";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "batch_id";
+			DROP TABLE IF EXISTS job_batches;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
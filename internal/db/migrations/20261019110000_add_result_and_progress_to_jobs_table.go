@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addResultAndProgressToJobsTable)
+}
+
+var addResultAndProgressToJobsTable = &Migration{
+	Name: "20261019110000_add_result_and_progress_to_jobs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "attempts" INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "errors" JSONB;
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "progress" INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "progress_message" TEXT NOT NULL DEFAULT '';
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "result" JSONB;
+
+			COMMENT ON COLUMN jobs.progress IS 'The progress percentage (0-100) reported by the handler while running.';
+			COMMENT ON COLUMN jobs.result IS 'The result payload returned by the handler once the job completed.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "result";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "progress_message";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "progress";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "errors";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "attempts";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addQueueBackendColumnsToJobsTable)
+}
+
+var addQueueBackendColumnsToJobsTable = &Migration{
+	Name: "20261019120000_add_queue_backend_columns_to_jobs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "available_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "reserved_at" TIMESTAMPTZ;
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "unique_key" VARCHAR(255) NOT NULL DEFAULT '';
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "unique_for" INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "unique_until" VARCHAR(32) NOT NULL DEFAULT '';
+
+			COMMENT ON COLUMN jobs.available_at IS 'When a pending job of a queue on the postgres backend can be reserved.';
+			COMMENT ON COLUMN jobs.reserved_at IS 'When a job of a queue on the postgres backend was reserved by a worker.';
+
+			CREATE INDEX IF NOT EXISTS idx_jobs_queue_status_available_at ON jobs (queue, status, available_at);
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP INDEX IF EXISTS idx_jobs_queue_status_available_at;
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "unique_until";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "unique_for";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "unique_key";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "reserved_at";
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "available_at";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addTTLToJobsTable)
+}
+
+var addTTLToJobsTable = &Migration{
+	Name: "20261019130000_add_ttl_to_jobs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "ttl" INTEGER NOT NULL DEFAULT 0;
+
+			COMMENT ON COLUMN jobs.ttl IS 'Seconds after its creation a job is skipped instead of run, 0 means it never expires.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "ttl";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addStatusUpdatedAtIndexToJobsTable)
+}
+
+var addStatusUpdatedAtIndexToJobsTable = &Migration{
+	Name: "20261019140000_add_status_updated_at_index_to_jobs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			CREATE INDEX IF NOT EXISTS idx_jobs_status_updated_at ON jobs (status, updated_at);
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP INDEX IF EXISTS idx_jobs_status_updated_at;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
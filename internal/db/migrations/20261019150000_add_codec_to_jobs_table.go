@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addCodecToJobsTable)
+}
+
+var addCodecToJobsTable = &Migration{
+	Name: "20261019150000_add_codec_to_jobs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "codec" VARCHAR(64) NOT NULL DEFAULT '';
+
+			COMMENT ON COLUMN jobs.codec IS 'Version and steps the payload is encoded with, for example v1:zstd+aes-gcm. Empty for a plain JSON payload.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "codec";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, createJobOutboxTable)
+}
+
+var createJobOutboxTable = &Migration{
+	Name: "20261019160000_create_job_outbox_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			CREATE TABLE IF NOT EXISTS job_outbox (
+				"id" BIGSERIAL PRIMARY KEY,
+				"job_id" UUID NOT NULL,
+				"queue" VARCHAR(255) NOT NULL,
+				"job" JSONB NOT NULL,
+				"created_at" TIMESTAMPTZ DEFAULT NOW()
+			);
+
+			COMMENT ON TABLE job_outbox IS 'Jobs enqueued within a database transaction, waiting for queue:relay to publish them to their queue.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP TABLE IF EXISTS job_outbox;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addCallbackURLToJobsTable)
+}
+
+var addCallbackURLToJobsTable = &Migration{
+	Name: "20261019170000_add_callback_url_to_jobs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS "callback_url" TEXT NOT NULL DEFAULT '';
+
+			COMMENT ON COLUMN jobs.callback_url IS 'URL the final state of the job is posted to, empty for none.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS "callback_url";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, createScheduleRunsTable)
+}
+
+var createScheduleRunsTable = &Migration{
+	Name: "20261019180000_create_schedule_runs_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			CREATE TABLE IF NOT EXISTS schedule_runs (
+				"id" BIGSERIAL PRIMARY KEY,
+				"task" VARCHAR(255) NOT NULL,
+				"status" VARCHAR(20) NOT NULL DEFAULT 'running',
+				"error" TEXT NOT NULL DEFAULT '',
+				"host" VARCHAR(255) NOT NULL DEFAULT '',
+				"started_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				"finished_at" TIMESTAMPTZ,
+				"duration_ms" BIGINT
+			);
+
+			CREATE INDEX IF NOT EXISTS schedule_runs_task_started_at_index ON schedule_runs (task, started_at DESC);
+			CREATE INDEX IF NOT EXISTS schedule_runs_started_at_index ON schedule_runs (started_at DESC);
+
+			COMMENT ON TABLE schedule_runs IS 'Runs of the scheduled tasks, status is running, succeeded, failed or skipped.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP TABLE IF EXISTS schedule_runs;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, createSchedulesTable)
+}
+
+var createSchedulesTable = &Migration{
+	Name: "20261019190000_create_schedules_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			CREATE TABLE IF NOT EXISTS schedules (
+				"id" BIGSERIAL PRIMARY KEY,
+				"task" VARCHAR(255) NOT NULL UNIQUE,
+				"cron" VARCHAR(255) NOT NULL,
+				"timezone" VARCHAR(64) NOT NULL DEFAULT '',
+				"jitter" INT NOT NULL DEFAULT 0,
+				"is_enabled" BOOLEAN NOT NULL DEFAULT FALSE,
+				"lock" BOOLEAN NOT NULL DEFAULT FALSE,
+				"lock_ttl" INT NOT NULL DEFAULT 0,
+				"created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				"updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);
+
+			COMMENT ON TABLE schedules IS 'Schedules of the scheduler, seeded from the schedules of the config and editable at runtime.';
+			COMMENT ON COLUMN schedules.timezone IS 'Timezone of the cron expression, empty for scheduler.timezone.';
+			COMMENT ON COLUMN schedules.jitter IS 'Longest random delay in seconds before each run.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP TABLE IF EXISTS schedules;
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addMisfirePolicyToSchedulesTable)
+}
+
+var addMisfirePolicyToSchedulesTable = &Migration{
+	Name: "20261019200000_add_misfire_policy_to_schedules_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE schedules ADD COLUMN IF NOT EXISTS "misfire_policy" VARCHAR(20) NOT NULL DEFAULT 'skip';
+			ALTER TABLE schedules ADD COLUMN IF NOT EXISTS "misfire_limit" INT NOT NULL DEFAULT 0;
+
+			COMMENT ON COLUMN schedules.misfire_policy IS 'What to do on startup with the runs missed since the last successful run: skip, run_once or run_all.';
+			COMMENT ON COLUMN schedules.misfire_limit IS 'Most missed runs run with run_all, 0 for the default.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE schedules DROP COLUMN IF EXISTS "misfire_policy";
+			ALTER TABLE schedules DROP COLUMN IF EXISTS "misfire_limit";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
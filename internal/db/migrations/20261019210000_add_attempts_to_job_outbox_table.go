@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+
+	"webapi/internal/db/pgx"
+)
+
+func init() {
+	Migrations = append(Migrations, addAttemptsToJobOutboxTable)
+}
+
+var addAttemptsToJobOutboxTable = &Migration{
+	Name: "20261019210000_add_attempts_to_job_outbox_table",
+	Up: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			ALTER TABLE job_outbox ADD COLUMN IF NOT EXISTS "attempts" INT NOT NULL DEFAULT 0;
+			ALTER TABLE job_outbox ADD COLUMN IF NOT EXISTS "last_error" TEXT;
+			ALTER TABLE job_outbox ADD COLUMN IF NOT EXISTS "next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT NOW();
+			ALTER TABLE job_outbox ADD COLUMN IF NOT EXISTS "dead_at" TIMESTAMPTZ;
+
+			CREATE INDEX IF NOT EXISTS idx_job_outbox_next_attempt_at ON job_outbox (next_attempt_at, id) WHERE dead_at IS NULL;
+
+			COMMENT ON COLUMN job_outbox.attempts IS 'Failed attempts to publish the job.';
+			COMMENT ON COLUMN job_outbox.last_error IS 'Error of the last failed attempt.';
+			COMMENT ON COLUMN job_outbox.next_attempt_at IS 'When the job is published again after a failed attempt.';
+			COMMENT ON COLUMN job_outbox.dead_at IS 'When the job was given up after too many failed attempts, it is not published anymore.';
+		`)
+
+		if err != nil {
+			return err
+		}
+		return nil
+
+	},
+	Down: func() error {
+		_, err := pgx.GetPgxPool().Exec(context.Background(), `
+			DROP INDEX IF EXISTS idx_job_outbox_next_attempt_at;
+			ALTER TABLE job_outbox DROP COLUMN IF EXISTS "attempts";
+			ALTER TABLE job_outbox DROP COLUMN IF EXISTS "last_error";
+			ALTER TABLE job_outbox DROP COLUMN IF EXISTS "next_attempt_at";
+			ALTER TABLE job_outbox DROP COLUMN IF EXISTS "dead_at";
+		`)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
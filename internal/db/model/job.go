@@ -8,16 +8,45 @@ import (
 )
 
 type Job struct {
-	ID          uuid.UUID       `json:"id"`
-	Queue       string          `json:"queue"`
-	HandlerName string          `json:"handler_name"`
-	Payload     json.RawMessage `json:"payload"`
-	MaxAttempts int             `json:"max_attempts"`
-	Delay       int             `json:"delay"`
-	Status      string          `json:"status"` // "pending", "processing", "completed", "failed"
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
-	FailedJob   []FailedJob     `json:"failed_job"`
+	ID              uuid.UUID       `json:"id"`
+	Queue           string          `json:"queue"`
+	HandlerName     string          `json:"handler_name"`
+	Payload         json.RawMessage `json:"payload"`
+	MaxAttempts     int             `json:"max_attempts"`
+	Delay           int             `json:"delay"`
+	Status          string          `json:"status"` // "pending", "processing", "completed", "failed"
+	BatchID         *uuid.UUID      `json:"batch_id"`
+	Chain           json.RawMessage `json:"chain"`
+	Attempts        int             `json:"attempts"`
+	Errors          []string        `json:"errors"`
+	Progress        int             `json:"progress"`
+	ProgressMessage string          `json:"progress_message"`
+	Result          json.RawMessage `json:"result"`
+	UniqueKey       string          `json:"unique_key"`
+	UniqueFor       int             `json:"unique_for"`
+	UniqueUntil     string          `json:"unique_until"`
+	TTL             int             `json:"ttl"`
+	Codec           string          `json:"codec"`
+	CallbackURL     string          `json:"callback_url"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	FailedJob       []FailedJob     `json:"failed_job"`
+}
+
+type JobBatch struct {
+	ID            uuid.UUID       `json:"id"`
+	Name          string          `json:"name"`
+	Queue         string          `json:"queue"`
+	TotalJobs     int             `json:"total_jobs"`
+	PendingJobs   int             `json:"pending_jobs"`
+	FailedJobs    int             `json:"failed_jobs"`
+	AllowFailures bool            `json:"allow_failures"`
+	ThenJob       json.RawMessage `json:"then_job"`
+	CatchJob      json.RawMessage `json:"catch_job"`
+	FinallyJob    json.RawMessage `json:"finally_job"`
+	CreatedAt     time.Time       `json:"created_at"`
+	CancelledAt   *time.Time      `json:"cancelled_at"`
+	FinishedAt    *time.Time      `json:"finished_at"`
 }
 
 type FailedJob struct {
@@ -28,3 +57,24 @@ type FailedJob struct {
 	Error    string          `json:"error"`
 	FailedAt time.Time       `json:"failed_at"`
 }
+
+// OutboxJob is a job enqueued within a database transaction that was not published to its queue yet.
+type OutboxJob struct {
+	ID            int64           `json:"id"`
+	JobID         uuid.UUID       `json:"job_id"`
+	Queue         string          `json:"queue"`
+	Job           json.RawMessage `json:"job"`
+	Attempts      int             `json:"attempts"`        // failed attempts to publish the job
+	LastError     *string         `json:"last_error"`      // error of the last failed attempt
+	NextAttemptAt time.Time       `json:"next_attempt_at"` // when the job is published again after a failed attempt
+	DeadAt        *time.Time      `json:"dead_at"`         // when the job was given up, it is not published anymore
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// QueueActivity is the number of jobs of a queue enqueued, completed and failed within a period.
+type QueueActivity struct {
+	Queue     string `json:"queue"`
+	Enqueued  int64  `json:"enqueued"`
+	Completed int64  `json:"completed"`
+	Failed    int64  `json:"failed"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// ScheduleRun is a run of a scheduled task. FinishedAt and DurationMs are nil while it is running.
+type ScheduleRun struct {
+	ID         int64      `json:"id"`
+	Task       string     `json:"task"`
+	Status     string     `json:"status"` // "running", "succeeded", "failed", "skipped"
+	Error      string     `json:"error"`
+	Host       string     `json:"host"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	DurationMs *int64     `json:"duration_ms"`
+}
+
+// Schedule runs a registered task on a cron expression. The schedules of the config are seeded into the database,
+// from where they can be edited at runtime.
+type Schedule struct {
+	ID            int64     `json:"id"`
+	Task          string    `json:"task"`
+	Cron          string    `json:"cron"`
+	Timezone      string    `json:"timezone"` // empty for scheduler.timezone
+	Jitter        int       `json:"jitter"`   // longest random delay in seconds before each run
+	IsEnabled     bool      `json:"is_enabled"`
+	Lock          bool      `json:"lock"`
+	LockTTL       int       `json:"lock_ttl"`
+	MisfirePolicy string    `json:"misfire_policy"` // "skip", "run_once" or "run_all", for the runs missed while no scheduler ran
+	MisfireLimit  int       `json:"misfire_limit"`  // most missed runs run with "run_all"
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
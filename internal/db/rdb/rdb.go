@@ -86,6 +86,11 @@ func GetRedisClient() redis.Cmdable {
 	return rdb
 }
 
+// Subscribe subscribes to redis pub/sub channels. The caller closes the returned PubSub.
+func Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return GetRedisClient().(redis.UniversalClient).Subscribe(ctx, channels...)
+}
+
 func AddPrefix(key string) string {
 	if prefix == "" {
 		m.Lock()
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"webapi/config"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+	"webapi/internal/repository"
+)
+
+const (
+	BackendRedis    = "redis"    // BackendRedis keeps the jobs in redis lists. It is the default backend.
+	BackendStream   = "stream"   // BackendStream keeps the jobs in a redis stream read by a consumer group.
+	BackendPostgres = "postgres" // BackendPostgres keeps the jobs in the postgres jobs table.
+	BackendMemory   = "memory"   // BackendMemory keeps the jobs in the memory of the process, for tests.
+
+	// How long a job may stay reserved before another worker takes it over,
+	// for the backends that recover the jobs of dead workers.
+	reservationTimeout = 15 * time.Minute
+)
+
+// Backend stores the jobs of a queue and moves them between the pending, processing, delayed and failed states.
+// The status, errors and result of a job stay in postgres whatever the backend.
+type Backend interface {
+	// Push adds a job to the pending jobs.
+	Push(ctx context.Context, j *job.Job) error
+	// Schedule adds a job to the delayed jobs. It becomes pending at the given time.
+	Schedule(ctx context.Context, j *job.Job, at time.Time) error
+	// AddFailed adds a job to the failed jobs, when it is restored.
+	AddFailed(ctx context.Context, j *job.Job) error
+
+	// Pop reserves the next pending job, increments its attempts and returns it, or nil when there is none.
+	// It waits up to timeout for a job, a zero timeout returns immediately.
+	Pop(ctx context.Context, timeout time.Duration) (*job.Job, error)
+	// Ack removes a reserved job once it was handled.
+	Ack(ctx context.Context, j *job.Job) error
+	// Nack puts a reserved job back to the pending jobs, through the delayed jobs when delay is not zero.
+	Nack(ctx context.Context, j *job.Job, delay time.Duration) error
+	// Fail moves a reserved job to the failed jobs.
+	Fail(ctx context.Context, j *job.Job) error
+
+	// Retry moves a failed job back to the pending jobs with its attempts reset.
+	Retry(ctx context.Context, jobID uuid.UUID) (*job.Job, error)
+	// Find returns the job in the given state, or ErrJobNotFound.
+	Find(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error)
+	// Remove deletes a pending, delayed or failed job and returns it, or ErrJobNotFound.
+	Remove(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error)
+	// List returns a page of the jobs in the given state and the total number of jobs in that state.
+	// A limit of 0 returns every job from the offset.
+	List(ctx context.Context, state string, offset int64, limit int64) ([]job.Job, int64, error)
+	// Oldest returns the pending job that has waited the longest, or nil when there is none.
+	Oldest(ctx context.Context) (*job.Job, error)
+	// Counts returns the number of jobs in each state.
+	Counts(ctx context.Context) (QueueCounts, error)
+	// Purge deletes every job in the given states and returns how many were deleted.
+	Purge(ctx context.Context, states ...string) (int64, error)
+}
+
+// backendName returns the backend configured for the queue.
+func backendName(queue string) string {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return BackendRedis
+	}
+
+	for _, options := range cfg.Queue.Queues {
+		if options.Name == queue && options.Backend != "" {
+			return options.Backend
+		}
+	}
+
+	return BackendRedis
+}
+
+// validateBackend returns an error for a backend name that is not known.
+func validateBackend(name string) error {
+	switch name {
+	case "", BackendRedis, BackendStream, BackendPostgres, BackendMemory:
+		return nil
+	default:
+		return fmt.Errorf("unknown queue backend %q, expected %s, %s, %s or %s", name, BackendRedis, BackendStream, BackendPostgres, BackendMemory)
+	}
+}
+
+// newBackend creates the backend configured for the queue.
+func newBackend(queue string, repo *repository.Repository) Backend {
+	switch backendName(queue) {
+	case BackendStream:
+		return newStreamBackend(rdb.AddQueuePrefix(queue))
+	case BackendPostgres:
+		return newPostgresBackend(queue, repo)
+	case BackendMemory:
+		return memoryBackendFor(queue)
+	default:
+		return newRedisListBackend(rdb.AddQueuePrefix(queue))
+	}
+}
+
+// configuredQueues returns the names of the queues configured with a backend that does not keep
+// its pending jobs in a redis list, so that they are listed next to the queues found in redis.
+func configuredQueues() []string {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return nil
+	}
+
+	var names []string
+	for _, options := range cfg.Queue.Queues {
+		if options.Backend != "" && options.Backend != BackendRedis {
+			names = append(names, options.Name)
+		}
+	}
+
+	return names
+}
+
+// oldestByList returns the first pending job of a backend that lists the oldest pending job first.
+func oldestByList(ctx context.Context, b Backend) (*job.Job, error) {
+	jobs, _, err := b.List(ctx, StatePending, 0, 1)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	return &jobs[0], nil
+}
+
+// pageOf returns the page of the jobs from offset, every job when limit is 0.
+func pageOf(jobs []job.Job, offset int64, limit int64) []job.Job {
+	if offset >= int64(len(jobs)) {
+		return []job.Job{}
+	}
+
+	end := int64(len(jobs))
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	return jobs[offset:end]
+}
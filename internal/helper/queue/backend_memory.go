@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"webapi/internal/job"
+)
+
+// How often Pop looks for a job again while it waits.
+const memoryPollInterval = 10 * time.Millisecond
+
+// The memory backends by queue name, so that every Queue of a name in the process shares its jobs.
+var memoryBackends sync.Map
+
+type delayedJob struct {
+	job job.Job
+	at  time.Time
+}
+
+// memoryBackend keeps the jobs of a queue in the memory of the process. The jobs are lost when it exits,
+// it is meant for unit tests and local development.
+type memoryBackend struct {
+	mu       sync.Mutex
+	pending  []job.Job
+	reserved []job.Job
+	delayed  []delayedJob
+	failed   []job.Job
+}
+
+// NewMemoryBackend creates an empty in-memory backend, to be used with NewQueueWithBackend.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{}
+}
+
+func memoryBackendFor(queue string) Backend {
+	backend, _ := memoryBackends.LoadOrStore(queue, NewMemoryBackend())
+	return backend.(Backend)
+}
+
+func (b *memoryBackend) Push(_ context.Context, j *job.Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, *j)
+	return nil
+}
+
+func (b *memoryBackend) Schedule(_ context.Context, j *job.Job, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.delayed = append(b.delayed, delayedJob{job: *j, at: at})
+	slices.SortStableFunc(b.delayed, func(a, b delayedJob) int {
+		return a.at.Compare(b.at)
+	})
+	return nil
+}
+
+func (b *memoryBackend) AddFailed(_ context.Context, j *job.Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failed = append(b.failed, *j)
+	return nil
+}
+
+func (b *memoryBackend) Pop(ctx context.Context, timeout time.Duration) (*job.Job, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if j := b.tryPop(); j != nil {
+			return j, nil
+		}
+
+		wait := min(memoryPollInterval, time.Until(deadline))
+		if wait <= 0 {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (b *memoryBackend) tryPop() *job.Job {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Move the delayed jobs that are due to the pending jobs
+	now := time.Now()
+	for len(b.delayed) > 0 && !b.delayed[0].at.After(now) {
+		b.pending = append(b.pending, b.delayed[0].job)
+		b.delayed = b.delayed[1:]
+	}
+
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	j := b.pending[0]
+	b.pending = b.pending[1:]
+	j.Attempts++
+	b.reserved = append(b.reserved, j)
+
+	return &j
+}
+
+func (b *memoryBackend) unreserve(jobID uuid.UUID) error {
+	index := indexOfJob(b.reserved, jobID)
+	if index < 0 {
+		return fmt.Errorf("job with ID %s is not reserved: %w", jobID, ErrJobNotFound)
+	}
+
+	b.reserved = slices.Delete(b.reserved, index, index+1)
+	return nil
+}
+
+func (b *memoryBackend) Ack(_ context.Context, j *job.Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.unreserve(j.ID)
+}
+
+func (b *memoryBackend) Nack(ctx context.Context, j *job.Job, delay time.Duration) error {
+	b.mu.Lock()
+	err := b.unreserve(j.ID)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if delay > 0 {
+		return b.Schedule(ctx, j, time.Now().Add(delay))
+	}
+
+	return b.Push(ctx, j)
+}
+
+func (b *memoryBackend) Fail(ctx context.Context, j *job.Job) error {
+	b.mu.Lock()
+	err := b.unreserve(j.ID)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return b.AddFailed(ctx, j)
+}
+
+func (b *memoryBackend) Retry(_ context.Context, jobID uuid.UUID) (*job.Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	index := indexOfJob(b.failed, jobID)
+	if index < 0 {
+		return nil, fmt.Errorf("job with ID %s not found in failed jobs: %w", jobID, ErrJobNotFound)
+	}
+
+	j := b.failed[index]
+	b.failed = slices.Delete(b.failed, index, index+1)
+
+	// Add the job to the front of the queue with the reset attempts counter
+	j.Attempts = 0
+	b.pending = slices.Insert(b.pending, 0, j)
+
+	return &j, nil
+}
+
+// jobs returns the jobs in the given state.
+func (b *memoryBackend) jobs(state string) ([]job.Job, error) {
+	switch state {
+	case StatePending:
+		return b.pending, nil
+	case StateProcessing:
+		return b.reserved, nil
+	case StateFailed:
+		return b.failed, nil
+	case StateDelayed:
+		jobs := make([]job.Job, 0, len(b.delayed))
+		for _, d := range b.delayed {
+			jobs = append(jobs, d.job)
+		}
+		return jobs, nil
+	default:
+		return nil, fmt.Errorf("unknown job state %q", state)
+	}
+}
+
+func (b *memoryBackend) Find(_ context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	jobs, err := b.jobs(state)
+	if err != nil {
+		return nil, err
+	}
+
+	index := indexOfJob(jobs, jobID)
+	if index < 0 {
+		return nil, fmt.Errorf("job with ID %s not found in %s jobs: %w", jobID, state, ErrJobNotFound)
+	}
+
+	j := jobs[index]
+	return &j, nil
+}
+
+func (b *memoryBackend) Remove(_ context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	jobs, err := b.jobs(state)
+	if err != nil {
+		return nil, err
+	}
+
+	index := indexOfJob(jobs, jobID)
+	if index < 0 {
+		return nil, fmt.Errorf("job with ID %s not found in %s jobs: %w", jobID, state, ErrJobNotFound)
+	}
+	j := jobs[index]
+
+	switch state {
+	case StatePending:
+		b.pending = slices.Delete(b.pending, index, index+1)
+	case StateProcessing:
+		b.reserved = slices.Delete(b.reserved, index, index+1)
+	case StateFailed:
+		b.failed = slices.Delete(b.failed, index, index+1)
+	case StateDelayed:
+		b.delayed = slices.Delete(b.delayed, index, index+1)
+	}
+
+	return &j, nil
+}
+
+func (b *memoryBackend) List(_ context.Context, state string, offset int64, limit int64) ([]job.Job, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	jobs, err := b.jobs(state)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return slices.Clone(pageOf(jobs, offset, limit)), int64(len(jobs)), nil
+}
+
+func (b *memoryBackend) Oldest(ctx context.Context) (*job.Job, error) {
+	return oldestByList(ctx, b)
+}
+
+func (b *memoryBackend) Counts(_ context.Context) (QueueCounts, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return QueueCounts{
+		Pending:    int64(len(b.pending)),
+		Processing: int64(len(b.reserved)),
+		Failed:     int64(len(b.failed)),
+		Delayed:    int64(len(b.delayed)),
+	}, nil
+}
+
+func (b *memoryBackend) Purge(_ context.Context, states ...string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := int64(0)
+	for _, state := range states {
+		switch state {
+		case StatePending:
+			total += int64(len(b.pending))
+			b.pending = nil
+		case StateProcessing:
+			total += int64(len(b.reserved))
+			b.reserved = nil
+		case StateFailed:
+			total += int64(len(b.failed))
+			b.failed = nil
+		case StateDelayed:
+			total += int64(len(b.delayed))
+			b.delayed = nil
+		default:
+			return total, fmt.Errorf("unknown job state %q", state)
+		}
+	}
+
+	return total, nil
+}
+
+func indexOfJob(jobs []job.Job, jobID uuid.UUID) int {
+	return slices.IndexFunc(jobs, func(j job.Job) bool {
+		return j.ID == jobID
+	})
+}
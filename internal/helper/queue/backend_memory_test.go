@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"webapi/internal/job"
+)
+
+func newTestJob(t *testing.T, data string) *job.Job {
+	t.Helper()
+
+	j, err := job.NewJob("ProcessExample", job.ProcessExample{Data: data}, 2, 0)
+	require.NoError(t, err)
+
+	return j
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+
+	first := newTestJob(t, "first")
+	second := newTestJob(t, "second")
+	require.NoError(t, b.Push(ctx, first))
+	require.NoError(t, b.Push(ctx, second))
+
+	// Pop reserves the jobs in order and counts the attempt
+	popped, err := b.Pop(ctx, 0)
+	require.NoError(t, err)
+	require.NotNil(t, popped)
+	assert.Equal(t, first.ID, popped.ID)
+	assert.Equal(t, 1, popped.Attempts)
+
+	counts, err := b.Counts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, QueueCounts{Pending: 1, Processing: 1}, counts)
+
+	reserved, err := b.Find(ctx, StateProcessing, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, reserved.ID)
+
+	// Nack with a delay moves the job to the delayed jobs until it is due
+	require.NoError(t, b.Nack(ctx, popped, 20*time.Millisecond))
+	counts, err = b.Counts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, QueueCounts{Pending: 1, Delayed: 1}, counts)
+
+	popped, err = b.Pop(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, popped.ID)
+	require.NoError(t, b.Ack(ctx, popped))
+
+	popped, err = b.Pop(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, popped, "the delayed job should be popped once due")
+	assert.Equal(t, first.ID, popped.ID)
+	assert.Equal(t, 2, popped.Attempts)
+
+	// Fail and retry
+	require.NoError(t, b.Fail(ctx, popped))
+	failed, total, err := b.List(ctx, StateFailed, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, first.ID, failed[0].ID)
+
+	retried, err := b.Retry(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, retried.Attempts)
+
+	_, err = b.Retry(ctx, first.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	// Remove and purge
+	removed, err := b.Remove(ctx, StatePending, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, removed.ID)
+
+	_, err = b.Remove(ctx, StatePending, first.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	require.NoError(t, b.Push(ctx, newTestJob(t, "third")))
+	require.NoError(t, b.Schedule(ctx, newTestJob(t, "fourth"), time.Now().Add(time.Hour)))
+	purged, err := b.Purge(ctx, StatePending, StateDelayed)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	popped, err = b.Pop(ctx, 0)
+	require.NoError(t, err)
+	assert.Nil(t, popped, "Pop should return nil on an empty queue")
+
+	err = b.Ack(ctx, first)
+	assert.ErrorIs(t, err, ErrJobNotFound, "Ack should fail for a job that is not reserved")
+}
+
+func TestPageOf(t *testing.T) {
+	jobs := []job.Job{{Delay: 1}, {Delay: 2}, {Delay: 3}}
+
+	tests := []struct {
+		name   string
+		offset int64
+		limit  int64
+		want   int
+	}{
+		{name: "every job", offset: 0, limit: 0, want: 3},
+		{name: "first page", offset: 0, limit: 2, want: 2},
+		{name: "last page", offset: 2, limit: 2, want: 1},
+		{name: "past the end", offset: 5, limit: 2, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, pageOf(jobs, tt.offset, tt.limit), tt.want)
+		})
+	}
+}
+
+func TestRangeStop(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		limit  int64
+		want   int64
+	}{
+		{name: "every job", offset: 0, limit: 0, want: -1},
+		{name: "every job after offset", offset: 5, limit: 0, want: -1},
+		{name: "first page", offset: 0, limit: 2, want: 1},
+		{name: "second page", offset: 2, limit: 2, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rangeStop(tt.offset, tt.limit))
+		})
+	}
+}
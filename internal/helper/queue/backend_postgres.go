@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"webapi/internal/db/model"
+	"webapi/internal/job"
+	"webapi/internal/repository"
+)
+
+// How often Pop looks for a job again while it waits.
+const postgresPollInterval = 500 * time.Millisecond
+
+/*
+postgresBackend is a queue on the postgres jobs table, the row written for every enqueued job is the job itself.
+Workers reserve jobs with FOR UPDATE SKIP LOCKED, delayed jobs are pending rows with an available_at in the future
+and a job reserved for longer than reservationTimeout is taken over by another worker.
+*/
+type postgresBackend struct {
+	queue string
+	repo  *repository.Repository
+}
+
+func newPostgresBackend(queue string, repo *repository.Repository) *postgresBackend {
+	return &postgresBackend{queue: queue, repo: repo}
+}
+
+func (b *postgresBackend) notFound(jobID uuid.UUID, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("job with ID %s not found in queue %s: %w", jobID, b.queue, ErrJobNotFound)
+	}
+
+	return err
+}
+
+func (b *postgresBackend) Push(ctx context.Context, j *job.Job) error {
+	return b.repo.Job.RequeueJob(ctx, j.ID, j.Attempts, time.Now())
+}
+
+func (b *postgresBackend) Schedule(ctx context.Context, j *job.Job, at time.Time) error {
+	return b.repo.Job.RequeueJob(ctx, j.ID, j.Attempts, at)
+}
+
+func (b *postgresBackend) AddFailed(ctx context.Context, j *job.Job) error {
+	return b.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusFailed)
+}
+
+func (b *postgresBackend) Pop(ctx context.Context, timeout time.Duration) (*job.Job, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		m, err := b.repo.Job.ReserveNextJob(ctx, b.queue, time.Now().Add(-reservationTimeout))
+		if err == nil {
+			return jobFromModel(m)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+
+		wait := min(postgresPollInterval, time.Until(deadline))
+		if wait <= 0 {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (b *postgresBackend) Ack(ctx context.Context, j *job.Job) error {
+	return b.repo.Job.FinishReservedJob(ctx, j.ID, job.StatusCompleted)
+}
+
+func (b *postgresBackend) Nack(ctx context.Context, j *job.Job, delay time.Duration) error {
+	return b.repo.Job.RequeueJob(ctx, j.ID, j.Attempts, time.Now().Add(delay))
+}
+
+func (b *postgresBackend) Fail(ctx context.Context, j *job.Job) error {
+	return b.repo.Job.FinishReservedJob(ctx, j.ID, job.StatusFailed)
+}
+
+func (b *postgresBackend) Retry(ctx context.Context, jobID uuid.UUID) (*job.Job, error) {
+	m, err := b.repo.Job.RequeueFailedJob(ctx, b.queue, jobID)
+	if err != nil {
+		return nil, b.notFound(jobID, err)
+	}
+
+	return jobFromModel(m)
+}
+
+func (b *postgresBackend) Find(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	m, err := b.repo.Job.GetQueueJob(ctx, b.queue, state, jobID)
+	if err != nil {
+		return nil, b.notFound(jobID, err)
+	}
+
+	return jobFromModel(m)
+}
+
+func (b *postgresBackend) Remove(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	m, err := b.repo.Job.DeleteQueueJob(ctx, b.queue, state, jobID)
+	if err != nil {
+		return nil, b.notFound(jobID, err)
+	}
+
+	return jobFromModel(m)
+}
+
+func (b *postgresBackend) List(ctx context.Context, state string, offset int64, limit int64) ([]job.Job, int64, error) {
+	models, total, err := b.repo.Job.GetQueueJobs(ctx, b.queue, state, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs, err := jobsFromModels(models)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+func (b *postgresBackend) Oldest(ctx context.Context) (*job.Job, error) {
+	return oldestByList(ctx, b)
+}
+
+func (b *postgresBackend) Counts(ctx context.Context) (QueueCounts, error) {
+	counts, err := b.repo.Job.CountQueueJobs(ctx, b.queue)
+	if err != nil {
+		return QueueCounts{}, err
+	}
+
+	return QueueCounts{
+		Pending:    counts[StatePending],
+		Processing: counts[StateProcessing],
+		Failed:     counts[StateFailed],
+		Delayed:    counts[StateDelayed],
+	}, nil
+}
+
+func (b *postgresBackend) Purge(ctx context.Context, states ...string) (int64, error) {
+	total := int64(0)
+	for _, state := range states {
+		deleted, err := b.repo.Job.DeleteQueueJobs(ctx, b.queue, state)
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+	}
+
+	return total, nil
+}
+
+func jobsFromModels(models []model.Job) ([]job.Job, error) {
+	jobs := make([]job.Job, 0, len(models))
+	for _, m := range models {
+		j, err := jobFromModel(m)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+
+	return jobs, nil
+}
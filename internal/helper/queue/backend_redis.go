@@ -0,0 +1,582 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+)
+
+// How long Pop blocks on the pending list at once, so that the delayed jobs that became due meanwhile are moved in time.
+const redisBlockTimeout = time.Second
+
+/*
+redisListBackend is a reliable queue on redis lists.
+The job bodies are kept in a hash keyed by job ID and their attempts in a second hash,
+the lists and the delayed sorted set only hold job IDs. Every transition runs as a single
+Lua script, so a job is always in exactly one state even if the worker dies halfway.
+
+Lists written before job bodies moved to the hash hold bodies instead of IDs. Such an item is converted
+to an ID with its body in the hash when it is popped, and processed as any other job.
+*/
+type redisListBackend struct {
+	key string
+}
+
+// pushScript stores the job and adds its ID to a list.
+// KEYS: list, jobs, attempts. ARGV: id, body, attempts.
+var pushScript = redis.NewScript(`
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[3])
+redis.call('LPUSH', KEYS[1], ARGV[1])
+return 1
+`)
+
+// scheduleScript stores the job and adds its ID to the delayed set, scored by when it is due.
+// KEYS: delayed, jobs, attempts. ARGV: id, body, attempts, due at in unix ms.
+var scheduleScript = redis.NewScript(`
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[4], ARGV[1])
+return 1
+`)
+
+// claimFunction defines claim, which increments the attempts of an item just moved to the attempt list and
+// returns the body and attempts of its job. An item holding a body, written before the bodies moved to the hash,
+// is replaced by its ID first. An item that is neither is removed and claim returns false.
+const claimFunction = `
+local function claim(attempt, jobs, counts, item)
+	local body = redis.call('HGET', jobs, item)
+	if not body then
+		local ok, legacy = pcall(cjson.decode, item)
+		if not ok or type(legacy) ~= 'table' or type(legacy['id']) ~= 'string' then
+			redis.call('LREM', attempt, 1, item)
+			return false
+		end
+
+		body = item
+		redis.call('LREM', attempt, 1, item)
+		redis.call('LPUSH', attempt, legacy['id'])
+		redis.call('HSET', jobs, legacy['id'], body)
+		redis.call('HSET', counts, legacy['id'], tonumber(legacy['attempts']) or 0)
+		item = legacy['id']
+	end
+
+	local attempts = redis.call('HINCRBY', counts, item, 1)
+	return {body, attempts}
+end
+`
+
+// dequeueScript moves the due delayed jobs to the pending list, then moves the next pending job
+// to the attempt list and claims it. It returns the body and attempts of the job.
+// KEYS: list, attempt, delayed, jobs, attempts. ARGV: now in unix ms, max delayed jobs to move.
+var dequeueScript = redis.NewScript(claimFunction + `
+local due = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, id in ipairs(due) do
+	redis.call('ZREM', KEYS[3], id)
+	redis.call('LPUSH', KEYS[1], id)
+end
+
+while true do
+	local item = redis.call('RPOPLPUSH', KEYS[1], KEYS[2])
+	if not item then
+		return false
+	end
+
+	local claimed = claim(KEYS[2], KEYS[4], KEYS[5], item)
+	if claimed then
+		return claimed
+	end
+end
+`)
+
+// claimScript claims an item that a blocking move put in the attempt list. It returns the body and attempts of the job.
+// KEYS: attempt, jobs, attempts. ARGV: item.
+var claimScript = redis.NewScript(claimFunction + `
+return claim(KEYS[1], KEYS[2], KEYS[3], ARGV[1])
+`)
+
+// ackScript removes a reserved job and its body.
+// KEYS: attempt, jobs, attempts. ARGV: id.
+var ackScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1
+`)
+
+// nackScript moves a reserved job back to the pending list, or to the delayed set when it is given a due time.
+// KEYS: attempt, list, delayed, jobs, attempts. ARGV: id, body, attempts, due at in unix ms or 0.
+var nackScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[5], ARGV[1], ARGV[3])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[1])
+else
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// failScript moves a reserved job to the failed list.
+// KEYS: attempt, failed, jobs, attempts. ARGV: id, body, attempts.
+var failScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[3])
+redis.call('LPUSH', KEYS[2], ARGV[1])
+return 1
+`)
+
+// retryScript moves a failed job to the front of the pending list with its attempts reset and returns its body.
+// KEYS: failed, list, jobs, attempts. ARGV: id.
+var retryScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return false
+end
+redis.call('HSET', KEYS[4], ARGV[1], 0)
+redis.call('RPUSH', KEYS[2], ARGV[1])
+return redis.call('HGET', KEYS[3], ARGV[1])
+`)
+
+// removeScript deletes a job from a list or sorted set and returns its body.
+// KEYS: list or sorted set, jobs, attempts. ARGV: id, 1 for a sorted set.
+var removeScript = redis.NewScript(`
+local removed
+if ARGV[2] == '1' then
+	removed = redis.call('ZREM', KEYS[1], ARGV[1])
+else
+	removed = redis.call('LREM', KEYS[1], 1, ARGV[1])
+end
+if removed == 0 then
+	return false
+end
+local body = redis.call('HGET', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return body or ''
+`)
+
+// purgeScript deletes a list or sorted set with the bodies of its jobs and returns how many jobs it held.
+// KEYS: list or sorted set, jobs, attempts. ARGV: 1 for a sorted set.
+var purgeScript = redis.NewScript(`
+local ids
+if ARGV[1] == '1' then
+	ids = redis.call('ZRANGE', KEYS[1], 0, -1)
+else
+	ids = redis.call('LRANGE', KEYS[1], 0, -1)
+end
+for _, id in ipairs(ids) do
+	redis.call('HDEL', KEYS[2], id)
+	redis.call('HDEL', KEYS[3], id)
+end
+redis.call('DEL', KEYS[1])
+return #ids
+`)
+
+func newRedisListBackend(key string) *redisListBackend {
+	return &redisListBackend{key: key}
+}
+
+func (b *redisListBackend) attemptKey() string {
+	return b.key + "_attempt"
+}
+
+func (b *redisListBackend) failedKey() string {
+	return b.key + "_failed"
+}
+
+func (b *redisListBackend) delayedKey() string {
+	return b.key + "_delayed"
+}
+
+func (b *redisListBackend) jobsKey() string {
+	return b.key + "_bodies"
+}
+
+func (b *redisListBackend) attemptsKey() string {
+	return b.key + "_attempt_counts"
+}
+
+// stateKey returns the key holding the IDs of the jobs in the given state and whether it is a sorted set.
+func (b *redisListBackend) stateKey(state string) (string, bool, error) {
+	switch state {
+	case StatePending:
+		return b.key, false, nil
+	case StateProcessing:
+		return b.attemptKey(), false, nil
+	case StateFailed:
+		return b.failedKey(), false, nil
+	case StateDelayed:
+		return b.delayedKey(), true, nil
+	default:
+		return "", false, fmt.Errorf("unknown job state %q", state)
+	}
+}
+
+func sortedFlag(sorted bool) string {
+	if sorted {
+		return "1"
+	}
+
+	return "0"
+}
+
+func (b *redisListBackend) add(ctx context.Context, key string, j *job.Job) error {
+	body, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	return pushScript.Run(ctx, rdb.GetRedisClient(), []string{key, b.jobsKey(), b.attemptsKey()}, j.ID.String(), body, j.Attempts).Err()
+}
+
+func (b *redisListBackend) Push(ctx context.Context, j *job.Job) error {
+	return b.add(ctx, b.key, j)
+}
+
+func (b *redisListBackend) Schedule(ctx context.Context, j *job.Job, at time.Time) error {
+	body, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	return scheduleScript.Run(ctx, rdb.GetRedisClient(), []string{b.delayedKey(), b.jobsKey(), b.attemptsKey()},
+		j.ID.String(), body, j.Attempts, at.UnixMilli()).Err()
+}
+
+func (b *redisListBackend) AddFailed(ctx context.Context, j *job.Job) error {
+	return b.add(ctx, b.failedKey(), j)
+}
+
+func (b *redisListBackend) Pop(ctx context.Context, timeout time.Duration) (*job.Job, error) {
+	deadline := time.Now().Add(timeout)
+	rdbClient := rdb.GetRedisClient()
+
+	for {
+		j, err := b.tryPop(ctx)
+		if err != nil || j != nil {
+			return j, err
+		}
+
+		if time.Until(deadline) <= 0 {
+			return nil, nil
+		}
+
+		// Wait for a job to be pushed, then claim it. Redis blocks for whole seconds, Pop may wait up to one longer than timeout
+		item, err := rdbClient.BLMove(ctx, b.key, b.attemptKey(), "RIGHT", "LEFT", redisBlockTimeout).Result()
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		j, err = b.claim(ctx, item)
+		if err != nil || j != nil {
+			return j, err
+		}
+	}
+}
+
+func (b *redisListBackend) tryPop(ctx context.Context) (*job.Job, error) {
+	keys := []string{b.key, b.attemptKey(), b.delayedKey(), b.jobsKey(), b.attemptsKey()}
+	return reservedJob(dequeueScript.Run(ctx, rdb.GetRedisClient(), keys, time.Now().UnixMilli(), promoteDelayedBatchSize).Slice())
+}
+
+// claim claims an item moved to the attempt list. It returns nil when the item is not a job.
+func (b *redisListBackend) claim(ctx context.Context, item string) (*job.Job, error) {
+	keys := []string{b.attemptKey(), b.jobsKey(), b.attemptsKey()}
+	return reservedJob(claimScript.Run(ctx, rdb.GetRedisClient(), keys, item).Slice())
+}
+
+// reservedJob returns the job of the body and attempts returned by a script that reserved it, or nil when it reserved none.
+func reservedJob(result []any, err error) (*job.Job, error) {
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != 2 {
+		return nil, fmt.Errorf("unexpected dequeue result %v", result)
+	}
+
+	body, _ := result[0].(string)
+	attempts, _ := result[1].(int64)
+
+	var j job.Job
+	if err := sonic.Unmarshal([]byte(body), &j); err != nil {
+		return nil, err
+	}
+	j.Attempts = int(attempts)
+
+	return &j, nil
+}
+
+// transition runs a script that moves a reserved job and returns ErrJobNotFound when the job was not reserved.
+func (b *redisListBackend) transition(ctx context.Context, script *redis.Script, keys []string, j *job.Job, args ...any) error {
+	moved, err := script.Run(ctx, rdb.GetRedisClient(), keys, append([]any{j.ID.String()}, args...)...).Int()
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		return fmt.Errorf("job with ID %s is not reserved: %w", j.ID, ErrJobNotFound)
+	}
+
+	return nil
+}
+
+func (b *redisListBackend) Ack(ctx context.Context, j *job.Job) error {
+	return b.transition(ctx, ackScript, []string{b.attemptKey(), b.jobsKey(), b.attemptsKey()}, j)
+}
+
+func (b *redisListBackend) Nack(ctx context.Context, j *job.Job, delay time.Duration) error {
+	body, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	dueAt := int64(0)
+	if delay > 0 {
+		dueAt = time.Now().Add(delay).UnixMilli()
+	}
+
+	keys := []string{b.attemptKey(), b.key, b.delayedKey(), b.jobsKey(), b.attemptsKey()}
+	return b.transition(ctx, nackScript, keys, j, body, j.Attempts, dueAt)
+}
+
+func (b *redisListBackend) Fail(ctx context.Context, j *job.Job) error {
+	body, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{b.attemptKey(), b.failedKey(), b.jobsKey(), b.attemptsKey()}
+	return b.transition(ctx, failScript, keys, j, body, j.Attempts)
+}
+
+func (b *redisListBackend) Retry(ctx context.Context, jobID uuid.UUID) (*job.Job, error) {
+	keys := []string{b.failedKey(), b.key, b.jobsKey(), b.attemptsKey()}
+	body, err := retryScript.Run(ctx, rdb.GetRedisClient(), keys, jobID.String()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("job with ID %s not found in %s: %w", jobID, b.failedKey(), ErrJobNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var j job.Job
+	if err := sonic.Unmarshal([]byte(body), &j); err != nil {
+		return nil, err
+	}
+	j.Attempts = 0
+
+	return &j, nil
+}
+
+func (b *redisListBackend) Find(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	key, sorted, err := b.stateKey(state)
+	if err != nil {
+		return nil, err
+	}
+
+	rdbClient := rdb.GetRedisClient()
+	id := jobID.String()
+
+	if sorted {
+		err = rdbClient.ZScore(ctx, key, id).Err()
+	} else {
+		err = rdbClient.LPos(ctx, key, id, redis.LPosArgs{}).Err()
+	}
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("job with ID %s not found in %s: %w", jobID, key, ErrJobNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := b.jobs(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("job with ID %s has no body: %w", jobID, ErrJobNotFound)
+	}
+
+	return &jobs[0], nil
+}
+
+func (b *redisListBackend) Remove(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	key, sorted, err := b.stateKey(state)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := removeScript.Run(ctx, rdb.GetRedisClient(), []string{key, b.jobsKey(), b.attemptsKey()}, jobID.String(), sortedFlag(sorted)).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("job with ID %s not found in %s: %w", jobID, key, ErrJobNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	j := job.Job{ID: jobID}
+	if body != "" {
+		if err := sonic.Unmarshal([]byte(body), &j); err != nil {
+			return nil, err
+		}
+	}
+
+	return &j, nil
+}
+
+// jobs returns the jobs with the given IDs, with their current attempts. IDs without a body are skipped.
+func (b *redisListBackend) jobs(ctx context.Context, ids []string) ([]job.Job, error) {
+	if len(ids) == 0 {
+		return []job.Job{}, nil
+	}
+
+	rdbClient := rdb.GetRedisClient()
+
+	var bodies, attempts *redis.SliceCmd
+	_, err := rdbClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		bodies = pipe.HMGet(ctx, b.jobsKey(), ids...)
+		attempts = pipe.HMGet(ctx, b.attemptsKey(), ids...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]job.Job, 0, len(ids))
+	for i, value := range bodies.Val() {
+		body, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var j job.Job
+		if err := sonic.Unmarshal([]byte(body), &j); err != nil {
+			return nil, err
+		}
+		if count, ok := attempts.Val()[i].(string); ok {
+			j.Attempts, _ = strconv.Atoi(count)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+func (b *redisListBackend) List(ctx context.Context, state string, offset int64, limit int64) ([]job.Job, int64, error) {
+	key, sorted, err := b.stateKey(state)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rdbClient := rdb.GetRedisClient()
+
+	total, err := listLength(ctx, rdbClient, key, sorted)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stop := rangeStop(offset, limit)
+
+	var ids []string
+	if sorted {
+		ids, err = rdbClient.ZRange(ctx, key, offset, stop).Result()
+	} else {
+		ids, err = rdbClient.LRange(ctx, key, offset, stop).Result()
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs, err := b.jobs(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+func (b *redisListBackend) Oldest(ctx context.Context) (*job.Job, error) {
+	// Jobs are pushed on the left and popped from the right
+	id, err := rdb.GetRedisClient().LIndex(ctx, b.key, -1).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := b.jobs(ctx, []string{id})
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	return &jobs[0], nil
+}
+
+func (b *redisListBackend) Counts(ctx context.Context) (QueueCounts, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	var pending, processing, failed, delayed *redis.IntCmd
+	_, err := rdbClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.LLen(ctx, b.key)
+		processing = pipe.LLen(ctx, b.attemptKey())
+		failed = pipe.LLen(ctx, b.failedKey())
+		delayed = pipe.ZCard(ctx, b.delayedKey())
+		return nil
+	})
+	if err != nil {
+		return QueueCounts{}, err
+	}
+
+	return QueueCounts{
+		Pending:    pending.Val(),
+		Processing: processing.Val(),
+		Failed:     failed.Val(),
+		Delayed:    delayed.Val(),
+	}, nil
+}
+
+func (b *redisListBackend) Purge(ctx context.Context, states ...string) (int64, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	total := int64(0)
+	for _, state := range states {
+		key, sorted, err := b.stateKey(state)
+		if err != nil {
+			return total, err
+		}
+
+		purged, err := purgeScript.Run(ctx, rdbClient, []string{key, b.jobsKey(), b.attemptsKey()}, sortedFlag(sorted)).Int64()
+		if err != nil {
+			return total, fmt.Errorf(ERROR_DELETING_KEY, key, err)
+		}
+
+		total += purged
+	}
+
+	return total, nil
+}
@@ -0,0 +1,545 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+)
+
+const streamGroup = "workers"
+
+// The streams whose consumer group was already created by this process.
+var streamGroups sync.Map
+
+// streamConsumer is the name of this process in the consumer groups.
+var streamConsumer = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}()
+
+/*
+streamBackend is a queue on a redis stream read by a consumer group.
+A reserved job is tracked in a hash next to the stream, so that its attempts and errors survive a retry.
+Acked messages are deleted, so the stream holds the messages delivered to the group, which are in its pending
+entries list until they are acked, and the pending jobs, which are the messages after the last one delivered.
+Messages left unacknowledged by a dead worker are taken over with XAUTOCLAIM once reservationTimeout elapsed.
+Delayed and failed jobs use the same sorted set and list as the redis list backend.
+*/
+type streamBackend struct {
+	key string
+}
+
+// streamReservation is a reserved job with the stream message that delivered it.
+type streamReservation struct {
+	MessageID string  `json:"message_id"`
+	Job       job.Job `json:"job"`
+}
+
+func newStreamBackend(key string) *streamBackend {
+	return &streamBackend{key: key}
+}
+
+func (b *streamBackend) streamKey() string {
+	return b.key + "_stream"
+}
+
+func (b *streamBackend) reservedKey() string {
+	return b.key + "_reserved"
+}
+
+func (b *streamBackend) failedKey() string {
+	return b.key + "_failed"
+}
+
+func (b *streamBackend) delayedKey() string {
+	return b.key + "_delayed"
+}
+
+// ensureGroup creates the consumer group of the stream. The group starts from the first message
+// so that jobs added before it existed are delivered too.
+func (b *streamBackend) ensureGroup(ctx context.Context, rdbClient redis.Cmdable) error {
+	if _, ok := streamGroups.Load(b.streamKey()); ok {
+		return nil
+	}
+
+	err := rdbClient.XGroupCreateMkStream(ctx, b.streamKey(), streamGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	streamGroups.Store(b.streamKey(), true)
+	return nil
+}
+
+func (b *streamBackend) add(ctx context.Context, rdbClient redis.Cmdable, jobBytes []byte) error {
+	return rdbClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: b.streamKey(),
+		Values: map[string]any{"job": jobBytes},
+	}).Err()
+}
+
+func (b *streamBackend) Push(ctx context.Context, j *job.Job) error {
+	jobBytes, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	return b.add(ctx, rdb.GetRedisClient(), jobBytes)
+}
+
+func (b *streamBackend) Schedule(ctx context.Context, j *job.Job, at time.Time) error {
+	return addJobToDelayedSet(ctx, rdb.GetRedisClient(), b.delayedKey(), j, at)
+}
+
+func (b *streamBackend) AddFailed(ctx context.Context, j *job.Job) error {
+	return pushRedisJob(ctx, rdb.GetRedisClient(), b.failedKey(), j)
+}
+
+func (b *streamBackend) Pop(ctx context.Context, timeout time.Duration) (*job.Job, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	if err := b.ensureGroup(ctx, rdbClient); err != nil {
+		return nil, err
+	}
+
+	err := promoteDelayed(ctx, rdbClient, b.delayedKey(), func(item string) error {
+		return b.add(ctx, rdbClient, []byte(item))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Take over a job left behind by a dead worker first
+	claimed, _, err := rdbClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   b.streamKey(),
+		Group:    streamGroup,
+		Consumer: streamConsumer,
+		MinIdle:  reservationTimeout,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(claimed) == 0 {
+		block := timeout
+		if block <= 0 {
+			// A negative block does not block at all, zero would block forever
+			block = -1
+		}
+
+		streams, err := rdbClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    streamGroup,
+			Consumer: streamConsumer,
+			Streams:  []string{b.streamKey(), ">"},
+			Count:    1,
+			Block:    block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, stream := range streams {
+			claimed = append(claimed, stream.Messages...)
+		}
+	}
+
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	return b.reserve(ctx, rdbClient, claimed[0])
+}
+
+// reserve records the job of a delivered message as reserved and increments its attempts.
+// A job delivered again keeps the attempts and errors of its previous reservation.
+func (b *streamBackend) reserve(ctx context.Context, rdbClient redis.Cmdable, message redis.XMessage) (*job.Job, error) {
+	raw, _ := message.Values["job"].(string)
+
+	var j job.Job
+	if err := sonic.Unmarshal([]byte(raw), &j); err != nil {
+		return nil, err
+	}
+
+	if previous, err := b.reservation(ctx, rdbClient, j.ID); err == nil {
+		j = previous.Job
+	} else if !errors.Is(err, ErrJobNotFound) {
+		return nil, err
+	}
+
+	j.Attempts++
+	reservation, err := sonic.Marshal(streamReservation{MessageID: message.ID, Job: j})
+	if err != nil {
+		return nil, err
+	}
+	if err := rdbClient.HSet(ctx, b.reservedKey(), j.ID.String(), reservation).Err(); err != nil {
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+func (b *streamBackend) reservation(ctx context.Context, rdbClient redis.Cmdable, jobID uuid.UUID) (streamReservation, error) {
+	raw, err := rdbClient.HGet(ctx, b.reservedKey(), jobID.String()).Result()
+	if errors.Is(err, redis.Nil) {
+		return streamReservation{}, fmt.Errorf("job with ID %s is not reserved: %w", jobID, ErrJobNotFound)
+	}
+	if err != nil {
+		return streamReservation{}, err
+	}
+
+	var reservation streamReservation
+	err = sonic.Unmarshal([]byte(raw), &reservation)
+
+	return reservation, err
+}
+
+func (b *streamBackend) Ack(ctx context.Context, j *job.Job) error {
+	return b.ack(ctx, j, func(redis.Pipeliner) error { return nil })
+}
+
+func (b *streamBackend) Nack(ctx context.Context, j *job.Job, delay time.Duration) error {
+	return b.ack(ctx, j, func(pipe redis.Pipeliner) error {
+		if delay > 0 {
+			return addJobToDelayedSet(ctx, pipe, b.delayedKey(), j, time.Now().Add(delay))
+		}
+
+		jobBytes, err := sonic.Marshal(j)
+		if err != nil {
+			return err
+		}
+
+		return b.add(ctx, pipe, jobBytes)
+	})
+}
+
+func (b *streamBackend) Fail(ctx context.Context, j *job.Job) error {
+	return b.ack(ctx, j, func(pipe redis.Pipeliner) error {
+		return pushRedisJob(ctx, pipe, b.failedKey(), j)
+	})
+}
+
+// ack acknowledges and deletes the message of a reserved job and runs move in the same transaction,
+// so that a job moved back to the stream, the delayed set or the failed list is never lost or duplicated.
+func (b *streamBackend) ack(ctx context.Context, j *job.Job, move func(pipe redis.Pipeliner) error) error {
+	rdbClient := rdb.GetRedisClient()
+
+	reservation, err := b.reservation(ctx, rdbClient, j.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = rdbClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, b.streamKey(), streamGroup, reservation.MessageID)
+		pipe.XDel(ctx, b.streamKey(), reservation.MessageID)
+		pipe.HDel(ctx, b.reservedKey(), j.ID.String())
+		return move(pipe)
+	})
+
+	return err
+}
+
+func (b *streamBackend) Retry(ctx context.Context, jobID uuid.UUID) (*job.Job, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	j, err := removeRedisJob(ctx, rdbClient, b.failedKey(), false, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	j.Attempts = 0
+	if err := b.Push(ctx, j); err != nil {
+		_ = b.AddFailed(ctx, j)
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// lastDeliveredID returns the ID of the last message delivered to the consumer group, 0-0 when there is none.
+func (b *streamBackend) lastDeliveredID(ctx context.Context, rdbClient redis.Cmdable) (string, error) {
+	groups, err := rdbClient.XInfoGroups(ctx, b.streamKey()).Result()
+	if err != nil && strings.Contains(err.Error(), "no such key") {
+		return "0-0", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, group := range groups {
+		if group.Name == streamGroup {
+			return group.LastDeliveredID, nil
+		}
+	}
+
+	return "0-0", nil
+}
+
+// pending returns up to count messages of the stream that were not delivered yet, with their jobs, every one when count is 0.
+func (b *streamBackend) pending(ctx context.Context, rdbClient redis.Cmdable, count int64) ([]redis.XMessage, []job.Job, error) {
+	lastDelivered, err := b.lastDeliveredID(ctx, rdbClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var messages []redis.XMessage
+	if count > 0 {
+		messages, err = rdbClient.XRangeN(ctx, b.streamKey(), "("+lastDelivered, "+", count).Result()
+	} else {
+		messages, err = rdbClient.XRange(ctx, b.streamKey(), "("+lastDelivered, "+").Result()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jobs := make([]job.Job, 0, len(messages))
+	for _, message := range messages {
+		raw, _ := message.Values["job"].(string)
+
+		var j job.Job
+		if err := sonic.Unmarshal([]byte(raw), &j); err != nil {
+			return nil, nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return messages, jobs, nil
+}
+
+// pendingCount returns the number of messages of the stream that are not in the pending entries list of the group.
+func (b *streamBackend) pendingCount(ctx context.Context, rdbClient redis.Cmdable) (int64, error) {
+	var length *redis.IntCmd
+	var delivered *redis.XPendingCmd
+	_, err := rdbClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		length = pipe.XLen(ctx, b.streamKey())
+		delivered = pipe.XPending(ctx, b.streamKey(), streamGroup)
+		return nil
+	})
+	if err != nil && !strings.HasPrefix(err.Error(), "NOGROUP") {
+		return 0, err
+	}
+	if length.Err() != nil {
+		return 0, length.Err()
+	}
+
+	// The group does not exist before the first Pop, nothing was delivered yet
+	if delivered.Err() != nil {
+		return length.Val(), nil
+	}
+
+	return max(length.Val()-delivered.Val().Count, 0), nil
+}
+
+// reserved returns the reserved jobs.
+func (b *streamBackend) reserved(ctx context.Context, rdbClient redis.Cmdable) ([]job.Job, error) {
+	values, err := rdbClient.HVals(ctx, b.reservedKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]job.Job, 0, len(values))
+	for _, value := range values {
+		var reservation streamReservation
+		if err := sonic.Unmarshal([]byte(value), &reservation); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, reservation.Job)
+	}
+
+	return jobs, nil
+}
+
+func (b *streamBackend) Find(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	switch state {
+	case StateProcessing:
+		reservation, err := b.reservation(ctx, rdbClient, jobID)
+		if err != nil {
+			return nil, err
+		}
+		return &reservation.Job, nil
+	case StatePending:
+		_, jobs, err := b.pending(ctx, rdbClient, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, j := range jobs {
+			if j.ID == jobID {
+				return &j, nil
+			}
+		}
+		return nil, fmt.Errorf("job with ID %s not found in %s: %w", jobID, b.streamKey(), ErrJobNotFound)
+	case StateFailed:
+		j, _, err := findRedisJob(ctx, rdbClient, b.failedKey(), false, jobID)
+		return j, err
+	case StateDelayed:
+		j, _, err := findRedisJob(ctx, rdbClient, b.delayedKey(), true, jobID)
+		return j, err
+	default:
+		return nil, fmt.Errorf("unknown job state %q", state)
+	}
+}
+
+func (b *streamBackend) Remove(ctx context.Context, state string, jobID uuid.UUID) (*job.Job, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	switch state {
+	case StatePending:
+		messages, jobs, err := b.pending(ctx, rdbClient, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := range jobs {
+			if j.ID == jobID {
+				if err := rdbClient.XDel(ctx, b.streamKey(), messages[i].ID).Err(); err != nil {
+					return nil, err
+				}
+				return &j, nil
+			}
+		}
+		return nil, fmt.Errorf("job with ID %s not found in %s: %w", jobID, b.streamKey(), ErrJobNotFound)
+	case StateFailed:
+		return removeRedisJob(ctx, rdbClient, b.failedKey(), false, jobID)
+	case StateDelayed:
+		return removeRedisJob(ctx, rdbClient, b.delayedKey(), true, jobID)
+	default:
+		return nil, fmt.Errorf("cannot remove a %s job", state)
+	}
+}
+
+func (b *streamBackend) List(ctx context.Context, state string, offset int64, limit int64) ([]job.Job, int64, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	switch state {
+	case StatePending:
+		return b.listPending(ctx, rdbClient, offset, limit)
+	case StateProcessing:
+		jobs, err := b.reserved(ctx, rdbClient)
+		if err != nil {
+			return nil, 0, err
+		}
+		return pageOf(jobs, offset, limit), int64(len(jobs)), nil
+	case StateFailed:
+		return listRedisJobs(ctx, rdbClient, b.failedKey(), false, offset, limit)
+	case StateDelayed:
+		return listRedisJobs(ctx, rdbClient, b.delayedKey(), true, offset, limit)
+	default:
+		return nil, 0, fmt.Errorf("unknown job state %q", state)
+	}
+}
+
+// listPending returns a page of the pending jobs, reading the stream only up to the end of the page.
+func (b *streamBackend) listPending(ctx context.Context, rdbClient redis.Cmdable, offset int64, limit int64) ([]job.Job, int64, error) {
+	total, err := b.pendingCount(ctx, rdbClient)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := int64(0)
+	if limit > 0 {
+		count = offset + limit
+	}
+
+	_, jobs, err := b.pending(ctx, rdbClient, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return pageOf(jobs, offset, limit), total, nil
+}
+
+func (b *streamBackend) Oldest(ctx context.Context) (*job.Job, error) {
+	_, jobs, err := b.pending(ctx, rdb.GetRedisClient(), 1)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	return &jobs[0], nil
+}
+
+func (b *streamBackend) Counts(ctx context.Context) (QueueCounts, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	pending, err := b.pendingCount(ctx, rdbClient)
+	if err != nil {
+		return QueueCounts{}, err
+	}
+
+	var processing, failed, delayed *redis.IntCmd
+	_, err = rdbClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		processing = pipe.HLen(ctx, b.reservedKey())
+		failed = pipe.LLen(ctx, b.failedKey())
+		delayed = pipe.ZCard(ctx, b.delayedKey())
+		return nil
+	})
+	if err != nil {
+		return QueueCounts{}, err
+	}
+
+	return QueueCounts{
+		Pending:    pending,
+		Processing: processing.Val(),
+		Failed:     failed.Val(),
+		Delayed:    delayed.Val(),
+	}, nil
+}
+
+func (b *streamBackend) Purge(ctx context.Context, states ...string) (int64, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	total := int64(0)
+	for _, state := range states {
+		switch state {
+		case StatePending:
+			messages, _, err := b.pending(ctx, rdbClient, 0)
+			if err != nil {
+				return total, err
+			}
+			if len(messages) == 0 {
+				continue
+			}
+
+			ids := make([]string, len(messages))
+			for i, message := range messages {
+				ids[i] = message.ID
+			}
+			deleted, err := rdbClient.XDel(ctx, b.streamKey(), ids...).Result()
+			if err != nil {
+				return total, err
+			}
+			total += deleted
+		case StateFailed, StateDelayed:
+			key, sorted := b.failedKey(), false
+			if state == StateDelayed {
+				key, sorted = b.delayedKey(), true
+			}
+
+			length, err := listLength(ctx, rdbClient, key, sorted)
+			if err != nil {
+				return total, fmt.Errorf(ERROR_GETTING_LENGTH_OF_KEY, key, err)
+			}
+			if err := rdbClient.Del(ctx, key).Err(); err != nil {
+				return total, fmt.Errorf(ERROR_DELETING_KEY, key, err)
+			}
+			total += length
+		default:
+			return total, fmt.Errorf("cannot purge %s jobs", state)
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,246 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"webapi/internal/db/model"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
+)
+
+// ErrUniqueJobInBatch is returned when dispatching a batch holding a unique job.
+var ErrUniqueJobInBatch = errors.New("unique jobs cannot be batched")
+
+// Batch is a group of jobs tracked together. The then callback runs once every job succeeded
+// (or finished, when failures are allowed), catch runs on the first failure and finally runs
+// once every job finished, whatever the outcome.
+type Batch struct {
+	name          string
+	queue         string
+	jobs          []*job.Job
+	allowFailures bool
+	then          *job.Job
+	catch         *job.Job
+	finally       *job.Job
+}
+
+// NewBatch creates a batch of jobs. Jobs without a queue are dispatched to the default queue.
+func NewBatch(jobs ...*job.Job) *Batch {
+	return &Batch{
+		queue: "default",
+		jobs:  jobs,
+	}
+}
+
+// Name sets a human readable name for the batch.
+func (b *Batch) Name(name string) *Batch {
+	b.name = name
+	return b
+}
+
+// OnQueue sets the queue for jobs and callbacks of the batch that don't set their own.
+func (b *Batch) OnQueue(queue string) *Batch {
+	b.queue = queue
+	return b
+}
+
+// AllowFailures keeps the batch running when some of its jobs fail.
+func (b *Batch) AllowFailures() *Batch {
+	b.allowFailures = true
+	return b
+}
+
+// Then sets the job dispatched when the batch finished successfully.
+func (b *Batch) Then(j *job.Job) *Batch {
+	b.then = j
+	return b
+}
+
+// Catch sets the job dispatched on the first failed job of the batch.
+func (b *Batch) Catch(j *job.Job) *Batch {
+	b.catch = j
+	return b
+}
+
+// Finally sets the job dispatched when every job of the batch finished.
+func (b *Batch) Finally(j *job.Job) *Batch {
+	b.finally = j
+	return b
+}
+
+// Dispatch stores the batch in postgres and enqueues its jobs. Unique jobs cannot be batched, a duplicate would
+// never be processed as part of the batch. When a job fails to enqueue, it and the jobs after it are removed
+// from the batch, so that the batch still finishes once the jobs enqueued did.
+func (b *Batch) Dispatch(ctx context.Context) (uuid.UUID, error) {
+	if len(b.jobs) == 0 {
+		return uuid.Nil, fmt.Errorf("batch %q has no jobs", b.name)
+	}
+	for _, j := range b.jobs {
+		if j.UniqueKey != "" {
+			return uuid.Nil, fmt.Errorf("batch %q, job %s: %w", b.name, j.ID, ErrUniqueJobInBatch)
+		}
+	}
+
+	batch := model.JobBatch{
+		ID:            uuid.New(),
+		Name:          b.name,
+		Queue:         b.queue,
+		TotalJobs:     len(b.jobs),
+		AllowFailures: b.allowFailures,
+		CreatedAt:     time.Now(),
+	}
+
+	var err error
+	if batch.ThenJob, err = marshalCallback(b.then); err != nil {
+		return uuid.Nil, err
+	}
+	if batch.CatchJob, err = marshalCallback(b.catch); err != nil {
+		return uuid.Nil, err
+	}
+	if batch.FinallyJob, err = marshalCallback(b.finally); err != nil {
+		return uuid.Nil, err
+	}
+
+	repo := repository.NewRepository()
+	if _, err := repo.JobBatch.AddBatch(ctx, batch); err != nil {
+		logger.Log.Error("Error adding job batch to postgres", zap.Error(err))
+		return uuid.Nil, err
+	}
+
+	for i, j := range b.jobs {
+		j.BatchID = &batch.ID
+		q := NewQueue(queueOf(j, b.queue))
+		if err := q.Enqueue(ctx, j); err != nil {
+			q.removeUndispatched(ctx, batch.ID, len(b.jobs)-i, i > 0)
+			return batch.ID, err
+		}
+	}
+
+	return batch.ID, nil
+}
+
+// removeUndispatched removes the jobs of a batch that were not enqueued from its counters. When the jobs
+// enqueued already finished meanwhile, the batch is finished here, nothing else would.
+func (q *Queue) removeUndispatched(ctx context.Context, batchID uuid.UUID, count int, anyDispatched bool) {
+	batch, err := q.repo.JobBatch.RemoveBatchJobs(ctx, batchID, count)
+	if err != nil {
+		logger.Log.Error("Error updating job batch in postgres", zap.String("batch_id", batchID.String()), zap.Error(err))
+		return
+	}
+
+	if anyDispatched && batch.PendingJobs == 0 {
+		q.finishBatch(ctx, batch)
+	}
+}
+
+func marshalCallback(j *job.Job) ([]byte, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	return sonic.Marshal(j)
+}
+
+func queueOf(j *job.Job, fallback string) string {
+	if j.Queue != "" {
+		return j.Queue
+	}
+
+	return fallback
+}
+
+// isBatchCancelled reports whether the job belongs to a batch that was cancelled by a failure.
+func (q *Queue) isBatchCancelled(ctx context.Context, j *job.Job) bool {
+	if j.BatchID == nil {
+		return false
+	}
+
+	batch, err := q.repo.JobBatch.GetBatchByID(ctx, *j.BatchID)
+	if err != nil {
+		logger.Log.Error("Error getting job batch from postgres", zap.String("batch_id", j.BatchID.String()), zap.Error(err))
+		return false
+	}
+
+	return batch.CancelledAt != nil
+}
+
+// afterSuccess dispatches the next job of the chain and updates the batch of a succeeded job.
+func (q *Queue) afterSuccess(ctx context.Context, j *job.Job) {
+	if len(j.Chain) > 0 {
+		next := j.Chain[0]
+		next.Chain = append(next.Chain, j.Chain[1:]...)
+		if err := NewQueue(queueOf(next, q.KeyWithoutPrefix)).Enqueue(ctx, next); err != nil {
+			logger.Log.Error("Error dispatching next job of the chain", zap.String("job_id", j.ID.String()), zap.Error(err))
+		}
+	}
+
+	if j.BatchID != nil {
+		batch, err := q.repo.JobBatch.RecordJobSucceeded(ctx, *j.BatchID)
+		if err != nil {
+			logger.Log.Error("Error updating job batch in postgres", zap.String("batch_id", j.BatchID.String()), zap.Error(err))
+			return
+		}
+
+		if batch.PendingJobs == 0 {
+			q.finishBatch(ctx, batch)
+		}
+	}
+}
+
+// afterFailure updates the batch of a job that failed for good. The rest of its chain is dropped.
+func (q *Queue) afterFailure(ctx context.Context, j *job.Job) {
+	if j.BatchID == nil {
+		return
+	}
+
+	batch, err := q.repo.JobBatch.RecordJobFailed(ctx, *j.BatchID)
+	if err != nil {
+		logger.Log.Error("Error updating job batch in postgres", zap.String("batch_id", j.BatchID.String()), zap.Error(err))
+		return
+	}
+
+	if batch.FailedJobs == 1 {
+		q.dispatchCallback(ctx, batch, batch.CatchJob)
+	}
+
+	if batch.PendingJobs == 0 {
+		q.finishBatch(ctx, batch)
+	}
+}
+
+func (q *Queue) finishBatch(ctx context.Context, batch model.JobBatch) {
+	if batch.FailedJobs == 0 || batch.AllowFailures {
+		q.dispatchCallback(ctx, batch, batch.ThenJob)
+	}
+
+	q.dispatchCallback(ctx, batch, batch.FinallyJob)
+}
+
+// dispatchCallback enqueues a fresh copy of a stored callback job.
+func (q *Queue) dispatchCallback(ctx context.Context, batch model.JobBatch, callback []byte) {
+	if len(callback) == 0 {
+		return
+	}
+
+	var j job.Job
+	if err := sonic.Unmarshal(callback, &j); err != nil {
+		logger.Log.Error("Error unmarshaling job batch callback", zap.String("batch_id", batch.ID.String()), zap.Error(err))
+		return
+	}
+
+	j.ID = uuid.New()
+	j.Attempts = 0
+	j.Errors = nil
+	j.CreatedAt = time.Now()
+
+	if err := NewQueue(queueOf(&j, batch.Queue)).Enqueue(ctx, &j); err != nil {
+		logger.Log.Error("Error dispatching job batch callback", zap.String("batch_id", batch.ID.String()), zap.Error(err))
+	}
+}
@@ -0,0 +1,23 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"webapi/internal/job"
+)
+
+func TestBatchDispatchRejectsUniqueJobs(t *testing.T) {
+	plain, err := job.NewJob("ProcessExample", job.ProcessExample{Data: "plain"}, 1, 0)
+	require.NoError(t, err)
+	unique, err := job.NewJob("ProcessExample", job.ProcessExample{Data: "unique"}, 1, 0, job.WithUnique("report", time.Hour, ""))
+	require.NoError(t, err)
+
+	id, err := NewBatch(plain, unique).Dispatch(context.Background())
+	assert.ErrorIs(t, err, ErrUniqueJobInBatch)
+	assert.Equal(t, uuid.Nil, id)
+}
@@ -0,0 +1,182 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+	"webapi/internal/metrics"
+	"webapi/internal/repository"
+)
+
+// How long a cancelled job is remembered, so that a worker that dequeued it meanwhile skips it.
+const cancelledJobTTL = 24 * time.Hour
+
+var (
+	// ErrJobCancelled is the cause of the context of a running job that was cancelled.
+	ErrJobCancelled = errors.New("job cancelled")
+	// ErrJobNotCancellable is returned when cancelling a job that already finished.
+	ErrJobNotCancellable = errors.New("job already finished")
+
+	// The cancel functions of the jobs running in this process, by job ID.
+	runningJobs         sync.Map
+	watchCancelledsOnce sync.Once
+)
+
+func cancelChannel() string {
+	return rdb.AddPrefix("queue_cancel")
+}
+
+func cancelledJobKey(jobID uuid.UUID) string {
+	return rdb.AddPrefix("cancelled_job:" + jobID.String())
+}
+
+/*
+Cancel cancels a pending, delayed or running job.
+A pending or delayed job is removed from its queue and marked cancelled right away.
+A running job has its context cancelled through a redis pub/sub signal and is marked cancelled by its worker
+once the handler returned. Cancel returns the status of the job after the call.
+*/
+func Cancel(ctx context.Context, jobID uuid.UUID) (string, error) {
+	repo := repository.NewRepository()
+
+	m, err := repo.Job.GetJobByID(ctx, jobID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("job with ID %s: %w", jobID, ErrJobNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if m.Status != job.StatusPending && m.Status != job.StatusProcessing {
+		return m.Status, fmt.Errorf("job with ID %s is %s: %w", jobID, m.Status, ErrJobNotCancellable)
+	}
+
+	rdbClient := rdb.GetRedisClient()
+	if err := rdbClient.Set(ctx, cancelledJobKey(jobID), time.Now().Unix(), cancelledJobTTL).Err(); err != nil {
+		return "", err
+	}
+
+	if m.Status == job.StatusPending {
+		cancelled, err := repo.Job.CancelJob(ctx, jobID, job.StatusPending)
+		if err != nil {
+			return "", err
+		}
+
+		if cancelled {
+			j, err := jobFromModel(m)
+			if err != nil {
+				return "", err
+			}
+
+			q := NewQueue(m.Queue)
+			if err := q.removeCancelled(ctx, j); err != nil {
+				return "", err
+			}
+
+			return job.StatusCancelled, nil
+		}
+
+		// A worker started the job meanwhile, stop it like a running job
+	}
+
+	if err := rdbClient.Publish(ctx, cancelChannel(), jobID.String()).Err(); err != nil {
+		return "", err
+	}
+
+	return job.StatusProcessing, nil
+}
+
+// removeCancelled removes a job that was just cancelled from the pending or delayed jobs of the queue.
+// A job that is not found was dequeued meanwhile, its worker sees it was cancelled and skips it.
+func (q *Queue) removeCancelled(ctx context.Context, j *job.Job) error {
+	for _, state := range []string{StatePending, StateDelayed} {
+		if _, err := q.backend.Remove(ctx, state, j.ID); err != nil && !errors.Is(err, ErrJobNotFound) {
+			return err
+		}
+	}
+
+	q.afterCancel(ctx, j)
+	return nil
+}
+
+// afterCancel releases the locks of a cancelled job and updates its batch, where it counts as failed.
+func (q *Queue) afterCancel(ctx context.Context, j *job.Job) {
+	logger.Log.Info("Job cancelled", zap.String("ID", j.ID.String()), zap.String("queue", q.KeyWithoutPrefix))
+	metrics.JobsCancelled.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
+	q.emit(ctx, EventCancelled, j, nil)
+
+	releaseUniqueLock(ctx, rdb.GetRedisClient(), j)
+	q.afterFailure(ctx, j)
+}
+
+// isCancelled reports whether the job was cancelled.
+func isCancelled(ctx context.Context, jobID uuid.UUID) bool {
+	err := rdb.GetRedisClient().Get(ctx, cancelledJobKey(jobID)).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		logger.Log.Error("Error checking if the job was cancelled", zap.String("ID", jobID.String()), zap.Error(err))
+	}
+
+	return err == nil
+}
+
+// finishCancelled removes a dequeued job that was cancelled from the processing jobs.
+func (q *Queue) finishCancelled(ctx context.Context, j *job.Job) error {
+	// Only the first to mark the job cancelled updates its batch
+	cancelled, err := q.repo.Job.CancelJob(ctx, j.ID, job.StatusPending, job.StatusProcessing)
+	if err != nil {
+		return err
+	}
+
+	if err := q.backend.Ack(ctx, j); err != nil && !errors.Is(err, ErrJobNotFound) {
+		return err
+	}
+
+	if cancelled {
+		q.afterCancel(ctx, j)
+	}
+
+	return nil
+}
+
+// withCancel returns a context of the job that is cancelled when Cancel is called for the job from any process.
+// The returned function must be called once the job returned.
+func withCancel(ctx context.Context, jobID uuid.UUID) (context.Context, func()) {
+	watchCancelledsOnce.Do(func() {
+		go watchCancelled()
+	})
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	runningJobs.Store(jobID, cancel)
+
+	return ctx, func() {
+		runningJobs.Delete(jobID)
+		cancel(nil)
+	}
+}
+
+// watchCancelled cancels the context of the jobs of this process that are cancelled, for as long as the process runs.
+func watchCancelled() {
+	pubsub := rdb.Subscribe(context.Background(), cancelChannel())
+
+	for message := range pubsub.Channel() {
+		jobID, err := uuid.Parse(message.Payload)
+		if err != nil {
+			continue
+		}
+
+		if cancel, ok := runningJobs.Load(jobID); ok {
+			logger.Log.Info("Cancelling running job", zap.String("ID", jobID.String()))
+			cancel.(context.CancelCauseFunc)(ErrJobCancelled)
+		}
+	}
+}
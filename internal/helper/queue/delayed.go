@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"webapi/internal/job"
+)
+
+// How many due jobs are moved from the delayed set to the pending jobs at once.
+const promoteDelayedBatchSize = 100
+
+// addJobToDelayedSet schedules a job to be added back to the pending jobs at the given time.
+func addJobToDelayedSet(ctx context.Context, rdbClient redis.Cmdable, delayedKey string, j *job.Job, at time.Time) error {
+	jobBytes, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	return rdbClient.ZAdd(ctx, delayedKey, redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: jobBytes,
+	}).Err()
+}
+
+// promoteDelayed moves the delayed jobs that are due to the pending jobs with push.
+// ZREM makes sure only one worker moves a given job.
+func promoteDelayed(ctx context.Context, rdbClient redis.Cmdable, delayedKey string, push func(item string) error) error {
+	due, err := rdbClient.ZRangeByScore(ctx, delayedKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: promoteDelayedBatchSize,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range due {
+		removed, err := rdbClient.ZRem(ctx, delayedKey, item).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			// Another worker already moved it
+			continue
+		}
+
+		if err := push(item); err != nil {
+			// Put it back so that it is not lost
+			_ = rdbClient.ZAdd(ctx, delayedKey, redis.Z{Score: 0, Member: item}).Err()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listLength returns the number of jobs in a redis list or sorted set.
+func listLength(ctx context.Context, rdbClient redis.Cmdable, key string, sorted bool) (int64, error) {
+	if sorted {
+		return rdbClient.ZCard(ctx, key).Result()
+	}
+
+	return rdbClient.LLen(ctx, key).Result()
+}
+
+// rangeStop returns the last index of a page for LRANGE and ZRANGE, a limit of 0 returns all the jobs after offset.
+func rangeStop(offset int64, limit int64) int64 {
+	if limit <= 0 {
+		return -1
+	}
+
+	return offset + limit - 1
+}
+
+// listRedisJobs returns a page of the jobs in a redis list or sorted set and the total number of jobs in it.
+func listRedisJobs(ctx context.Context, rdbClient redis.Cmdable, key string, sorted bool, offset int64, limit int64) ([]job.Job, int64, error) {
+	total, err := listLength(ctx, rdbClient, key, sorted)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stop := rangeStop(offset, limit)
+	var items []string
+	if sorted {
+		items, err = rdbClient.ZRange(ctx, key, offset, stop).Result()
+	} else {
+		items, err = rdbClient.LRange(ctx, key, offset, stop).Result()
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs := make([]job.Job, 0, len(items))
+	for _, item := range items {
+		var j job.Job
+		if err := sonic.Unmarshal([]byte(item), &j); err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, total, nil
+}
+
+// findRedisJob returns the job with the given ID in a redis list or sorted set, with the raw item holding it.
+func findRedisJob(ctx context.Context, rdbClient redis.Cmdable, key string, sorted bool, jobID uuid.UUID) (*job.Job, string, error) {
+	var items []string
+	var err error
+	if sorted {
+		items, err = rdbClient.ZRange(ctx, key, 0, -1).Result()
+	} else {
+		items, err = rdbClient.LRange(ctx, key, 0, -1).Result()
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, item := range items {
+		var j job.Job
+		if err := sonic.Unmarshal([]byte(item), &j); err != nil {
+			return nil, "", err
+		}
+		if j.ID == jobID {
+			return &j, item, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("job with ID %s not found in %s: %w", jobID, key, ErrJobNotFound)
+}
+
+// removeRedisJob deletes the job with the given ID from a redis list or sorted set.
+func removeRedisJob(ctx context.Context, rdbClient redis.Cmdable, key string, sorted bool, jobID uuid.UUID) (*job.Job, error) {
+	j, item, err := findRedisJob(ctx, rdbClient, key, sorted, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sorted {
+		err = rdbClient.ZRem(ctx, key, item).Err()
+	} else {
+		err = rdbClient.LRem(ctx, key, 1, item).Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// pushRedisJob adds a job to a redis list.
+func pushRedisJob(ctx context.Context, rdbClient redis.Cmdable, key string, j *job.Job) error {
+	jobBytes, err := sonic.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	return rdbClient.LPush(ctx, key, jobBytes).Err()
+}
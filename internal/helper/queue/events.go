@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+)
+
+const (
+	EventEnqueued  = "enqueued"  // EventEnqueued is emitted when a job is added to its queue.
+	EventStarted   = "started"   // EventStarted is emitted when a worker starts running a job.
+	EventSucceeded = "succeeded" // EventSucceeded is emitted when a job completed.
+	EventFailed    = "failed"    // EventFailed is emitted when a job failed its last attempt.
+	EventRetried   = "retried"   // EventRetried is emitted when a failed job is put back to its queue.
+	EventCancelled = "cancelled" // EventCancelled is emitted when a job was cancelled.
+	EventExpired   = "expired"   // EventExpired is emitted when a job was skipped because its TTL elapsed.
+)
+
+// Event is a change in the lifecycle of a job.
+type Event struct {
+	Type        string    `json:"type"`
+	JobID       uuid.UUID `json:"job_id"`
+	Queue       string    `json:"queue"`
+	HandlerName string    `json:"handler_name"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// IsFinal reports whether the job reached a final state with the event.
+func (e Event) IsFinal() bool {
+	return e.Type == EventSucceeded || e.Type == EventFailed || e.Type == EventCancelled || e.Type == EventExpired
+}
+
+// eventMessage is an event as published to redis, with the process it was emitted by.
+type eventMessage struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+var (
+	// Identifies this process, so that it skips its own events coming back from redis
+	eventOrigin = uuid.NewString()
+
+	eventSubscribersMu sync.RWMutex
+	eventSubscribers   = make(map[int]func(Event))
+	nextSubscriberID   int
+	watchEventsOnce    sync.Once
+)
+
+func eventChannel() string {
+	return rdb.AddPrefix("queue_events")
+}
+
+/*
+SubscribeEvents calls fn with the lifecycle events of every job, emitted by this process or, through redis,
+by any other process such as the workers. fn is called from a single goroutine and must not block.
+The returned function removes the subscription.
+*/
+func SubscribeEvents(fn func(Event)) func() {
+	watchEventsOnce.Do(func() {
+		go watchEvents()
+	})
+
+	eventSubscribersMu.Lock()
+	id := nextSubscriberID
+	nextSubscriberID++
+	eventSubscribers[id] = fn
+	eventSubscribersMu.Unlock()
+
+	return func() {
+		eventSubscribersMu.Lock()
+		delete(eventSubscribers, id)
+		eventSubscribersMu.Unlock()
+	}
+}
+
+// emit delivers the event of a job to the subscribers of this process and publishes it to the other processes.
+// Failing to publish is logged, it never fails the job.
+func (q *Queue) emit(ctx context.Context, eventType string, j *job.Job, jobError error) {
+	event := Event{
+		Type:        eventType,
+		JobID:       j.ID,
+		Queue:       q.KeyWithoutPrefix,
+		HandlerName: j.HandlerName,
+		Attempts:    j.Attempts,
+		Time:        time.Now(),
+	}
+	if jobError != nil {
+		event.Error = jobError.Error()
+	}
+
+	deliverEvent(event)
+
+	message, err := sonic.Marshal(eventMessage{Origin: eventOrigin, Event: event})
+	if err == nil {
+		err = rdb.GetRedisClient().Publish(ctx, eventChannel(), message).Err()
+	}
+	if err != nil {
+		logger.Log.Error("Error publishing job event", zap.String("ID", j.ID.String()), zap.String("event", eventType), zap.Error(err))
+	}
+
+	if event.IsFinal() && j.CallbackURL != "" {
+		q.sendCallback(ctx, j, event)
+	}
+}
+
+func deliverEvent(event Event) {
+	eventSubscribersMu.RLock()
+	defer eventSubscribersMu.RUnlock()
+
+	for _, fn := range eventSubscribers {
+		fn(event)
+	}
+}
+
+// watchEvents delivers the events published by other processes, for as long as the process runs.
+func watchEvents() {
+	pubsub := rdb.Subscribe(context.Background(), eventChannel())
+
+	for message := range pubsub.Channel() {
+		var m eventMessage
+		if err := sonic.UnmarshalString(message.Payload, &m); err != nil || m.Origin == eventOrigin {
+			continue
+		}
+
+		deliverEvent(m.Event)
+	}
+}
+
+// sendCallback enqueues the delivery of the final event of a job to its callback URL.
+// The delivery is a job of its own, retried on its own attempts.
+func (q *Queue) sendCallback(ctx context.Context, j *job.Job, event Event) {
+	body, err := sonic.Marshal(event)
+	if err != nil {
+		logger.Log.Error("Error encoding job callback", zap.String("ID", j.ID.String()), zap.Error(err))
+		return
+	}
+
+	callback, err := job.NewCallback(j.CallbackURL, body)
+	if err != nil {
+		logger.Log.Error("Error creating job callback", zap.String("ID", j.ID.String()), zap.Error(err))
+		return
+	}
+
+	if err := NewQueue(callback.Queue).Enqueue(ctx, callback); err != nil {
+		logger.Log.Error("Error enqueuing job callback", zap.String("ID", j.ID.String()), zap.Error(err))
+	}
+}
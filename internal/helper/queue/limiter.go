@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+)
+
+const (
+	defaultRateLimitPeriod   = time.Second
+	defaultConcurrencyLease  = 15 * time.Minute
+	concurrencyReleaseDelay  = time.Second // How long a job over its concurrency limit waits before it is tried again.
+	minRateLimitReleaseDelay = 100 * time.Millisecond
+	limiterErrorReleaseDelay = 5 * time.Second // How long a job waits before it is tried again when its limits could not be checked.
+)
+
+// rateLimitScript counts the jobs started in the current window.
+// It returns 0 when the job may start, otherwise the milliseconds left in the window.
+var rateLimitScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= tonumber(ARGV[1]) then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl < 0 then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		return tonumber(ARGV[2])
+	end
+	return ttl
+end
+current = redis.call('INCR', KEYS[1])
+if current == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// acquireSlotScript holds a concurrency slot in a sorted set scored by the lease expiry.
+// Expired leases are dropped first so that a slot held by a dead worker is freed.
+// It returns 1 when the slot was acquired.
+var acquireSlotScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// limiterFor returns the limits of the handler, or false when it declares none.
+func limiterFor(handlerName string, handler job.JobHandler) (job.RateLimit, bool) {
+	h, ok := handler.(job.RateLimitedHandler)
+	if !ok {
+		return job.RateLimit{}, false
+	}
+
+	limit := h.RateLimit()
+	if limit.Limit <= 0 && limit.Concurrency <= 0 {
+		return job.RateLimit{}, false
+	}
+	if limit.Key == "" {
+		limit.Key = handlerName
+	}
+	if limit.Period <= 0 {
+		limit.Period = defaultRateLimitPeriod
+	}
+	if limit.Lease <= 0 {
+		limit.Lease = defaultConcurrencyLease
+	}
+
+	return limit, true
+}
+
+func rateLimitKey(key string) string {
+	return rdb.AddPrefix("job_rate_limit_" + key)
+}
+
+func concurrencyKey(key string) string {
+	return rdb.AddPrefix("job_concurrency_" + key)
+}
+
+// acquireLimits checks the rate limit and takes a concurrency slot for the job.
+// When the job is over one of its limits it returns false and how long to wait before trying again.
+func acquireLimits(ctx context.Context, rdbClient redis.Cmdable, limit job.RateLimit, jobID uuid.UUID) (bool, time.Duration, error) {
+	if limit.Concurrency > 0 {
+		now := time.Now()
+		acquired, err := acquireSlotScript.Run(ctx, rdbClient, []string{concurrencyKey(limit.Key)},
+			now.UnixMilli(), limit.Concurrency, now.Add(limit.Lease).UnixMilli(), jobID.String(), limit.Lease.Milliseconds()).Int()
+		if err != nil {
+			return false, 0, err
+		}
+		if acquired == 0 {
+			return false, concurrencyReleaseDelay, nil
+		}
+	}
+
+	if limit.Limit > 0 {
+		wait, err := rateLimitScript.Run(ctx, rdbClient, []string{rateLimitKey(limit.Key)}, limit.Limit, limit.Period.Milliseconds()).Int64()
+		if err != nil {
+			releaseLimits(ctx, rdbClient, limit, jobID)
+			return false, 0, err
+		}
+		if wait > 0 {
+			releaseLimits(ctx, rdbClient, limit, jobID)
+			return false, max(time.Duration(wait)*time.Millisecond, minRateLimitReleaseDelay), nil
+		}
+	}
+
+	return true, 0, nil
+}
+
+// releaseLimits frees the concurrency slot held by the job.
+func releaseLimits(ctx context.Context, rdbClient redis.Cmdable, limit job.RateLimit, jobID uuid.UUID) {
+	if limit.Concurrency <= 0 {
+		return
+	}
+
+	if err := rdbClient.ZRem(ctx, concurrencyKey(limit.Key), jobID.String()).Err(); err != nil {
+		logger.Log.Error("Error releasing job concurrency slot", zap.String("job_id", jobID.String()), zap.Error(err))
+	}
+}
+
+// release puts a claimed job back to the delayed jobs without counting the attempt,
+// so a job over its limits is tried again later instead of failing.
+func (q *Queue) release(ctx context.Context, jobID uuid.UUID, delay time.Duration) error {
+	j, err := q.backend.Find(ctx, StateProcessing, jobID)
+	if err != nil {
+		return err
+	}
+
+	if j.Attempts > 0 {
+		j.Attempts--
+	}
+	if err := q.backend.Nack(ctx, j, delay); err != nil {
+		return err
+	}
+
+	if err := q.repo.Job.ReleaseJob(ctx, j.ID, j.Attempts); err != nil {
+		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"webapi/internal/job"
+)
+
+type limitedHandler struct {
+	limit job.RateLimit
+}
+
+func (h *limitedHandler) Handle() error { return nil }
+
+func (h *limitedHandler) RateLimit() job.RateLimit { return h.limit }
+
+func TestLimiterFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler job.JobHandler
+		want    job.RateLimit
+		wantOK  bool
+	}{
+		{
+			name:    "handler without limits",
+			handler: &job.ProcessExample{},
+		},
+		{
+			name:    "zero limits",
+			handler: &limitedHandler{},
+		},
+		{
+			name:    "rate limit with defaults",
+			handler: &limitedHandler{limit: job.RateLimit{Limit: 10}},
+			want:    job.RateLimit{Key: "SendSMS", Limit: 10, Period: time.Second, Lease: defaultConcurrencyLease},
+			wantOK:  true,
+		},
+		{
+			name:    "shared concurrency limit",
+			handler: &limitedHandler{limit: job.RateLimit{Key: "sms-provider", Concurrency: 3, Lease: time.Minute}},
+			want:    job.RateLimit{Key: "sms-provider", Period: time.Second, Concurrency: 3, Lease: time.Minute},
+			wantOK:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := limiterFor("SendSMS", tt.handler)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
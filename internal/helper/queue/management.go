@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"webapi/internal/db/model"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+)
+
+// ErrJobNotFound is returned when a job is not in the list it is looked up in.
+var ErrJobNotFound = errors.New("job not found")
+
+const (
+	StatePending    = "pending"    // StatePending is the state of jobs waiting in the source list.
+	StateProcessing = "processing" // StateProcessing is the state of jobs in the temporary list.
+	StateFailed     = "failed"     // StateFailed is the state of jobs in the failed list.
+	StateDelayed    = "delayed"    // StateDelayed is the state of jobs waiting for their retry delay.
+)
+
+// QueueCounts holds the number of jobs of a queue in each state.
+type QueueCounts struct {
+	Pending    int64 `json:"pending"`
+	Processing int64 `json:"processing"`
+	Failed     int64 `json:"failed"`
+	Delayed    int64 `json:"delayed"`
+}
+
+// Counts returns the number of jobs of the queue in each state.
+func (q *Queue) Counts(ctx context.Context) (QueueCounts, error) {
+	return q.backend.Counts(ctx)
+}
+
+// ListJobs returns a page of the jobs of the queue in the given state and the total number of jobs in that state.
+// A limit of 0 returns every job from the offset.
+func (q *Queue) ListJobs(ctx context.Context, state string, offset int64, limit int64) ([]job.Job, int64, error) {
+	switch state {
+	case StatePending, StateProcessing, StateFailed, StateDelayed:
+		return q.backend.List(ctx, state, offset, limit)
+	default:
+		return nil, 0, fmt.Errorf("unknown job state %q", state)
+	}
+}
+
+// Forget deletes a failed job, or a pending job if it is not in the failed list.
+func (q *Queue) Forget(ctx context.Context, jobID uuid.UUID) error {
+	err := q.RemoveFailedByID(ctx, jobID)
+	if errors.Is(err, ErrJobNotFound) {
+		removed, err := q.RemoveJobByID(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("job with ID %s not found in queue %s: %w", jobID, q.KeyWithoutPrefix, ErrJobNotFound)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// remove job from failed_jobs in postgres
+	if err := q.repo.Job.RemoveFailedJob(ctx, jobID); err != nil {
+		logger.Log.Error("Error removing job from failed_jobs in postgres", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Restore adds the unfinished jobs of the queue stored in postgres back to the backend.
+// Jobs still in the backend are skipped and processing jobs are restored as pending.
+func (q *Queue) Restore(ctx context.Context) (int, error) {
+	unfinishedJobs, err := q.repo.Job.GetUnfinishedJobsByQueue(ctx, q.KeyWithoutPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	present, err := q.jobIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, m := range unfinishedJobs {
+		if present[m.ID] {
+			continue
+		}
+
+		j, err := jobFromModel(m)
+		if err != nil {
+			logger.Log.Error("Cannot restore job", zap.String("job_id", m.ID.String()), zap.Error(err))
+			continue
+		}
+
+		if m.Status == job.StatusFailed {
+			err = q.EnqueueFailedJobs(ctx, j)
+		} else {
+			if m.Status == job.StatusProcessing {
+				if err := q.repo.Job.UpdateJobStatus(ctx, m.ID, job.StatusPending); err != nil {
+					return restored, err
+				}
+			}
+			err = q.EnqueuePendingJobs(ctx, j)
+		}
+		if err != nil {
+			return restored, err
+		}
+
+		restored++
+	}
+
+	return restored, nil
+}
+
+// jobIDs returns the IDs of all jobs of the queue that are in the backend, whatever their state.
+func (q *Queue) jobIDs(ctx context.Context) (map[uuid.UUID]bool, error) {
+	ids := make(map[uuid.UUID]bool)
+
+	for _, state := range []string{StatePending, StateProcessing, StateFailed, StateDelayed} {
+		jobs, _, err := q.ListJobs(ctx, state, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, j := range jobs {
+			ids[j.ID] = true
+		}
+	}
+
+	return ids, nil
+}
+
+func jobFromModel(m model.Job) (*job.Job, error) {
+	j := &job.Job{
+		ID:          m.ID,
+		Queue:       m.Queue,
+		HandlerName: m.HandlerName,
+		Payload:     m.Payload,
+		CreatedAt:   m.CreatedAt,
+		MaxAttempts: m.MaxAttempts,
+		Attempts:    m.Attempts,
+		Delay:       m.Delay,
+		Errors:      m.Errors,
+		UniqueKey:   m.UniqueKey,
+		UniqueFor:   m.UniqueFor,
+		UniqueUntil: m.UniqueUntil,
+		TTL:         m.TTL,
+		Codec:       m.Codec,
+		CallbackURL: m.CallbackURL,
+		BatchID:     m.BatchID,
+	}
+
+	if len(m.Chain) > 0 {
+		if err := sonic.Unmarshal(m.Chain, &j.Chain); err != nil {
+			return nil, err
+		}
+	}
+
+	return j, nil
+}
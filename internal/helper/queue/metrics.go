@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"webapi/internal/logger"
+)
+
+// How long a scrape may spend reading the queues.
+const metricsCollectTimeout = 5 * time.Second
+
+var registerMetricsOnce sync.Once
+
+// RegisterMetrics registers the collector of the queue depths with the default prometheus registry.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(newDepthCollector())
+	})
+}
+
+// depthCollector reads the number of jobs in each state and the age of the oldest pending job of every queue when scraped.
+type depthCollector struct {
+	depth     *prometheus.Desc
+	oldestAge *prometheus.Desc
+}
+
+func newDepthCollector() *depthCollector {
+	return &depthCollector{
+		depth: prometheus.NewDesc("queue_depth", "Number of jobs in a queue by state.",
+			[]string{"queue", "state"}, nil),
+		oldestAge: prometheus.NewDesc("queue_oldest_pending_job_age_seconds", "Age of the oldest pending job of a queue, 0 when there is none.",
+			[]string{"queue"}, nil),
+	}
+}
+
+func (c *depthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.oldestAge
+}
+
+func (c *depthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsCollectTimeout)
+	defer cancel()
+
+	names, err := ListQueueKeys(ctx)
+	if err != nil {
+		logger.Log.Error("Error listing queues for metrics", zap.Error(err))
+		return
+	}
+
+	for _, name := range names {
+		q := NewQueue(name)
+
+		counts, err := q.Counts(ctx)
+		if err != nil {
+			logger.Log.Error("Error counting jobs for metrics", zap.String("queue", name), zap.Error(err))
+			continue
+		}
+
+		for state, count := range map[string]int64{
+			StatePending:    counts.Pending,
+			StateProcessing: counts.Processing,
+			StateDelayed:    counts.Delayed,
+			StateFailed:     counts.Failed,
+		} {
+			ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(count), name, state)
+		}
+
+		oldest, err := q.backend.Oldest(ctx)
+		if err != nil {
+			logger.Log.Error("Error reading the oldest job for metrics", zap.String("queue", name), zap.Error(err))
+			continue
+		}
+
+		age := 0.0
+		if oldest != nil && !oldest.CreatedAt.IsZero() {
+			age = time.Since(oldest.CreatedAt).Seconds()
+		}
+		ch <- prometheus.MustNewConstMetric(c.oldestAge, prometheus.GaugeValue, age, name)
+	}
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"webapi/internal/db/model"
+	"webapi/internal/repository"
+)
+
+// MonitorWindow is the period the rates of the monitor are counted over.
+const MonitorWindow = time.Minute
+
+// QueueStats is the state of a queue as shown by the monitor.
+type QueueStats struct {
+	Name     string
+	Paused   bool
+	Counts   QueueCounts
+	Activity model.QueueActivity // jobs enqueued, completed and failed within MonitorWindow
+}
+
+// MonitorSnapshot is the state of the monitored queues at one point in time.
+type MonitorSnapshot struct {
+	TakenAt  time.Time
+	Queues   []QueueStats
+	Failures []model.FailedJob // the latest failed jobs, newest first
+}
+
+// TakeMonitorSnapshot reads the counts and rates of the given queues, of every queue when names is empty,
+// and their latest failed jobs.
+func TakeMonitorSnapshot(ctx context.Context, names []string, failures int) (MonitorSnapshot, error) {
+	repo := repository.NewRepository()
+	snapshot := MonitorSnapshot{TakenAt: time.Now()}
+
+	if len(names) == 0 {
+		var err error
+		if names, err = ListQueueKeys(ctx); err != nil {
+			return snapshot, fmt.Errorf(ERROR_LISTING_QUEUE_KEY, err)
+		}
+		slices.Sort(names)
+	}
+
+	activity, err := repo.Job.GetQueueActivity(ctx, snapshot.TakenAt.Add(-MonitorWindow))
+	if err != nil {
+		return snapshot, fmt.Errorf("error reading queue activity: %w", err)
+	}
+
+	for _, name := range names {
+		q := NewQueue(name)
+
+		counts, err := q.Counts(ctx)
+		if err != nil {
+			return snapshot, fmt.Errorf("error counting jobs of %s: %w", name, err)
+		}
+
+		paused, err := q.IsPaused(ctx)
+		if err != nil {
+			return snapshot, fmt.Errorf("error checking if %s is paused: %w", name, err)
+		}
+
+		snapshot.Queues = append(snapshot.Queues, QueueStats{
+			Name:     name,
+			Paused:   paused,
+			Counts:   counts,
+			Activity: activity[name],
+		})
+	}
+
+	if failures > 0 {
+		snapshot.Failures, err = repo.Job.GetLatestFailedJobs(ctx, names, failures)
+		if err != nil {
+			return snapshot, fmt.Errorf("error reading failed jobs: %w", err)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// MonitorThresholds are the limits a queue may not exceed. A zero limit is not checked.
+type MonitorThresholds struct {
+	MaxPending           int64
+	MaxFailed            int64
+	MaxFailuresPerMinute int64
+}
+
+// Breaches describes every threshold exceeded by a queue of the snapshot.
+func (t MonitorThresholds) Breaches(snapshot MonitorSnapshot) []string {
+	var breaches []string
+
+	for _, stats := range snapshot.Queues {
+		if t.MaxPending > 0 && stats.Counts.Pending > t.MaxPending {
+			breaches = append(breaches, fmt.Sprintf("queue %s has %d pending jobs, more than %d", stats.Name, stats.Counts.Pending, t.MaxPending))
+		}
+		if t.MaxFailed > 0 && stats.Counts.Failed > t.MaxFailed {
+			breaches = append(breaches, fmt.Sprintf("queue %s has %d failed jobs, more than %d", stats.Name, stats.Counts.Failed, t.MaxFailed))
+		}
+		if t.MaxFailuresPerMinute > 0 && stats.Activity.Failed > t.MaxFailuresPerMinute {
+			breaches = append(breaches, fmt.Sprintf("queue %s failed %d jobs in the last minute, more than %d", stats.Name, stats.Activity.Failed, t.MaxFailuresPerMinute))
+		}
+	}
+
+	return breaches
+}
@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"webapi/internal/db/model"
+)
+
+func TestMonitorThresholdsBreaches(t *testing.T) {
+	snapshot := MonitorSnapshot{
+		Queues: []QueueStats{
+			{Name: "default", Counts: QueueCounts{Pending: 10, Failed: 2}, Activity: model.QueueActivity{Failed: 1}},
+			{Name: "emails", Counts: QueueCounts{Pending: 1, Failed: 5}, Activity: model.QueueActivity{Failed: 4}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		thresholds MonitorThresholds
+		want       int
+	}{
+		{name: "no thresholds", thresholds: MonitorThresholds{}, want: 0},
+		{name: "pending under the limit", thresholds: MonitorThresholds{MaxPending: 10}, want: 0},
+		{name: "pending over the limit", thresholds: MonitorThresholds{MaxPending: 5}, want: 1},
+		{name: "failed over the limit", thresholds: MonitorThresholds{MaxFailed: 1}, want: 2},
+		{name: "failure rate over the limit", thresholds: MonitorThresholds{MaxFailuresPerMinute: 3}, want: 1},
+		{name: "every limit", thresholds: MonitorThresholds{MaxPending: 5, MaxFailed: 1, MaxFailuresPerMinute: 3}, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, tt.thresholds.Breaches(snapshot), tt.want)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"webapi/internal/db/model"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
+)
+
+const (
+	defaultRelayBatchSize    = 100
+	defaultRelayPollInterval = time.Second
+	maxRelayAttempts         = 10 // failed attempts to publish a job before it is moved to the dead letters of the outbox
+)
+
+// EnqueueTx adds jobs to the outbox within the caller's transaction instead of enqueuing them right away.
+// The jobs are enqueued by queue:relay once the transaction commits, and never when it rolls back.
+// Unique jobs are deduplicated when they are relayed, so j.ID is not replaced by the ID of an existing job.
+func (q *Queue) EnqueueTx(ctx context.Context, tx pgx.Tx, jobs ...*job.Job) error {
+	outboxJobs := make([]model.OutboxJob, 0, len(jobs))
+	for _, j := range jobs {
+		if j.Queue == "" {
+			j.Queue = q.KeyWithoutPrefix
+		}
+
+		jobBytes, err := sonic.Marshal(j)
+		if err != nil {
+			return err
+		}
+
+		outboxJobs = append(outboxJobs, model.OutboxJob{
+			JobID:     j.ID,
+			Queue:     q.KeyWithoutPrefix,
+			Job:       jobBytes,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	return q.repo.JobOutbox.AddOutboxJobs(ctx, tx, outboxJobs...)
+}
+
+// RelayOptions configures Relay. Zero values use the defaults.
+type RelayOptions struct {
+	BatchSize    int           // jobs published per transaction
+	PollInterval time.Duration // longest wait for new jobs, in case a notification was missed
+}
+
+// RelayOnce enqueues up to batchSize jobs of the outbox and returns how many were enqueued.
+// A job that cannot be enqueued stays in the outbox and is tried again after a backoff, until it failed maxRelayAttempts times.
+func RelayOnce(ctx context.Context, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultRelayBatchSize
+	}
+
+	return repository.NewRepository().JobOutbox.RelayOutboxJobs(ctx, batchSize, maxRelayAttempts, func(m model.OutboxJob) error {
+		if err := publishOutboxJob(ctx, m); err != nil {
+			return fmt.Errorf("error relaying job %s to %s: %w", m.JobID, m.Queue, err)
+		}
+		return nil
+	})
+}
+
+// Relay enqueues the jobs added to the outbox as their transactions commit, until the context is canceled.
+func Relay(ctx context.Context, opts RelayOptions) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultRelayBatchSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultRelayPollInterval
+	}
+
+	repo := repository.NewRepository()
+
+	for {
+		relayed, err := RelayOnce(ctx, opts.BatchSize)
+		if err != nil {
+			logger.Log.Error("Error relaying outbox jobs", zap.Error(err))
+		}
+		if relayed > 0 {
+			logger.Log.Info("Relayed outbox jobs", zap.Int("count", relayed))
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A full batch means more jobs are waiting
+		if relayed == opts.BatchSize {
+			continue
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, opts.PollInterval)
+		err = repo.JobOutbox.WaitForOutboxJobs(waitCtx)
+		cancel()
+		if err != nil && waitCtx.Err() == nil {
+			// Listening failed, fall back to polling
+			logger.Log.Error("Error waiting for outbox jobs", zap.Error(err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(opts.PollInterval):
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// publishOutboxJob enqueues a job of the outbox. A job already stored by an earlier relay that stopped before
+// deleting it from the outbox is only pushed to its backend again when it is still pending and not there.
+func publishOutboxJob(ctx context.Context, m model.OutboxJob) error {
+	var j job.Job
+	if err := sonic.Unmarshal(m.Job, &j); err != nil {
+		return err
+	}
+
+	q := NewQueue(m.Queue)
+
+	existing, err := q.repo.Job.GetJobByID(ctx, j.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return q.Enqueue(ctx, &j)
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.Status != job.StatusPending {
+		return nil
+	}
+
+	for _, state := range []string{StatePending, StateDelayed} {
+		_, err := q.backend.Find(ctx, state, j.ID)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrJobNotFound) {
+			return err
+		}
+	}
+
+	return q.EnqueuePendingJobs(ctx, &j)
+}
@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"webapi/internal/db/rdb"
+)
+
+func (q *Queue) pausedKey() string {
+	return q.Key + "_paused"
+}
+
+// Pause stops workers from dequeuing jobs of the queue. Jobs can still be enqueued and running jobs finish normally.
+func (q *Queue) Pause(ctx context.Context) error {
+	return rdb.GetRedisClient().Set(ctx, q.pausedKey(), time.Now().Unix(), 0).Err()
+}
+
+// Resume lets workers dequeue jobs of a paused queue again.
+func (q *Queue) Resume(ctx context.Context) error {
+	return rdb.GetRedisClient().Del(ctx, q.pausedKey()).Err()
+}
+
+// IsPaused reports whether the queue is paused.
+func (q *Queue) IsPaused(ctx context.Context) (bool, error) {
+	err := rdb.GetRedisClient().Get(ctx, q.pausedKey()).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,213 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"webapi/config"
+	"webapi/internal/db/model"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
+	internal_minio "webapi/pkg/minio"
+)
+
+const defaultPruneBatchSize = 1000
+
+// PruneOptions selects the finished jobs deleted by Prune. A zero age keeps the jobs of that kind.
+type PruneOptions struct {
+	CompletedOlderThan time.Duration
+	FailedOlderThan    time.Duration // applies to failed, cancelled and expired jobs
+	BatchSize          int
+	Archive            bool
+	ArchiveBucket      string
+}
+
+// PruneResult is the number of jobs deleted by Prune and the archive objects written.
+type PruneResult struct {
+	Completed int64
+	Failed    int64
+	Archives  []string
+}
+
+// PruneOptionsFromConfig returns the options configured in queue.prune.
+func PruneOptionsFromConfig() (PruneOptions, error) {
+	cfg := config.GetConfig()
+
+	completed, err := ParseAge(cfg.Queue.Prune.CompletedOlderThan)
+	if err != nil {
+		return PruneOptions{}, fmt.Errorf("queue.prune.completedOlderThan: %w", err)
+	}
+	failed, err := ParseAge(cfg.Queue.Prune.FailedOlderThan)
+	if err != nil {
+		return PruneOptions{}, fmt.Errorf("queue.prune.failedOlderThan: %w", err)
+	}
+
+	bucket := cfg.Queue.Prune.ArchiveBucket
+	if bucket == "" {
+		bucket = cfg.Minio.BucketName
+	}
+
+	return PruneOptions{
+		CompletedOlderThan: completed,
+		FailedOlderThan:    failed,
+		BatchSize:          cfg.Queue.Prune.BatchSize,
+		Archive:            cfg.Queue.Prune.Archive,
+		ArchiveBucket:      bucket,
+	}, nil
+}
+
+// ParseAge parses an age like 30d, 12h or 90m. Days are not supported by time.ParseDuration.
+// An empty string is a zero age.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+
+	return d, nil
+}
+
+/*
+Prune deletes the completed, failed, cancelled and expired jobs older than the given ages from postgres,
+in batches of one short transaction each so that the jobs table is never locked for long.
+With Archive set, every batch is uploaded to minio as a gzip compressed JSONL file before it is deleted,
+a batch that cannot be archived is not deleted. The archive is named after the jobs it holds, so a batch
+archived again after its deletion failed overwrites the same object.
+*/
+func Prune(ctx context.Context, opts PruneOptions) (PruneResult, error) {
+	var result PruneResult
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultPruneBatchSize
+	}
+	if opts.Archive {
+		if internal_minio.GetMinio() == nil {
+			return result, errors.New("archiving pruned jobs requires minio to be enabled")
+		}
+		if opts.ArchiveBucket == "" {
+			return result, errors.New("archiving pruned jobs requires a bucket")
+		}
+	}
+
+	now := time.Now()
+	repo := repository.NewRepository()
+
+	kinds := []struct {
+		name      string
+		statuses  []string
+		olderThan time.Duration
+		count     *int64
+	}{
+		{name: job.StatusCompleted, statuses: []string{job.StatusCompleted}, olderThan: opts.CompletedOlderThan, count: &result.Completed},
+		{name: job.StatusFailed, statuses: []string{job.StatusFailed, job.StatusCancelled, job.StatusExpired}, olderThan: opts.FailedOlderThan, count: &result.Failed},
+	}
+
+	for _, kind := range kinds {
+		if kind.olderThan <= 0 {
+			continue
+		}
+
+		before := now.Add(-kind.olderThan)
+		for {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+
+			jobs, err := repo.Job.GetPrunableJobs(ctx, kind.statuses, before, opts.BatchSize)
+			if err != nil {
+				return result, err
+			}
+			if len(jobs) == 0 {
+				break
+			}
+
+			if opts.Archive {
+				object := archiveObjectName(kind.name, jobs)
+				if err := uploadArchive(ctx, opts.ArchiveBucket, object, jobs); err != nil {
+					return result, fmt.Errorf("error archiving pruned jobs to %s: %w", object, err)
+				}
+				result.Archives = append(result.Archives, object)
+			}
+
+			ids := make([]uuid.UUID, len(jobs))
+			for i, j := range jobs {
+				ids[i] = j.ID
+			}
+			deleted, err := repo.Job.PruneJobs(ctx, ids, kind.statuses, before)
+			if err != nil {
+				return result, err
+			}
+
+			*kind.count += deleted
+			if len(jobs) < opts.BatchSize {
+				break
+			}
+		}
+
+		logger.Log.Info("Pruned jobs", zap.String("status", kind.name), zap.Int64("count", *kind.count), zap.Time("before", before))
+	}
+
+	return result, nil
+}
+
+// archiveObjectName names the archive of a batch after the update time of its first job and a hash of the IDs of
+// its jobs, for example jobs/completed/20261019T023000Z-3f2a9c1e7b5d4a60.jsonl.gz. The same jobs get the same name.
+func archiveObjectName(kind string, jobs []model.Job) string {
+	hash := sha256.New()
+	for _, j := range jobs {
+		hash.Write(j.ID[:])
+	}
+
+	return fmt.Sprintf("jobs/%s/%s-%x.jsonl.gz", kind, jobs[0].UpdatedAt.UTC().Format("20060102T150405Z"), hash.Sum(nil)[:8])
+}
+
+func uploadArchive(ctx context.Context, bucket string, object string, jobs []model.Job) error {
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, jobs); err != nil {
+		return err
+	}
+
+	_, err := internal_minio.UploadObject(ctx, bucket, object, &buf, int64(buf.Len()), "application/gzip")
+	return err
+}
+
+// writeArchive writes the jobs as gzip compressed JSONL, one job per line.
+func writeArchive(buf *bytes.Buffer, jobs []model.Job) error {
+	gz := gzip.NewWriter(buf)
+
+	for _, j := range jobs {
+		line, err := sonic.Marshal(j)
+		if err != nil {
+			return err
+		}
+		if _, err := gz.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"webapi/internal/db/model"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "7d", want: 7 * 24 * time.Hour},
+		{input: "12h", want: 12 * time.Hour},
+		{input: "90m", want: 90 * time.Minute},
+		{input: "d", wantErr: true},
+		{input: "-1d", wantErr: true},
+		{input: "-1h", wantErr: true},
+		{input: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAge(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	jobs := []model.Job{
+		{ID: uuid.New(), Queue: "default", HandlerName: "ProcessExample", Status: "completed"},
+		{ID: uuid.New(), Queue: "emails", HandlerName: "ProcessExample", Status: "failed"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeArchive(&buf, jobs))
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+
+	var ids []uuid.UUID
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var j model.Job
+		require.NoError(t, sonic.Unmarshal(scanner.Bytes(), &j))
+		ids = append(ids, j.ID)
+	}
+	require.NoError(t, scanner.Err())
+
+	assert.Equal(t, []uuid.UUID{jobs[0].ID, jobs[1].ID}, ids)
+}
+
+func TestArchiveObjectName(t *testing.T) {
+	updatedAt := time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)
+	jobs := []model.Job{{ID: uuid.New(), UpdatedAt: updatedAt}, {ID: uuid.New(), UpdatedAt: updatedAt.Add(time.Minute)}}
+
+	name := archiveObjectName("completed", jobs)
+	assert.Regexp(t, `^jobs/completed/20261019T023000Z-[0-9a-f]{16}\.jsonl\.gz$`, name)
+	assert.Equal(t, name, archiveObjectName("completed", []model.Job{jobs[0], jobs[1]}))
+	assert.NotEqual(t, name, archiveObjectName("completed", jobs[:1]))
+}
@@ -2,31 +2,34 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"webapi/internal/db/model"
 	"webapi/internal/db/rdb"
 	"webapi/internal/job"
 	"webapi/internal/logger"
+	"webapi/internal/metrics"
 	"webapi/internal/repository"
 )
 
 /*
 Queue is a FIFO.
-Implement redis BLMOVE with the RIGHT and LEFT arguments.
+The jobs are kept by the backend configured for the queue, redis lists by default.
+Every job is also stored in postgres, which keeps its status, errors and result.
 */
 
 type Queue struct {
 	Key              string
 	KeyWithoutPrefix string
 
-	repo *repository.Repository
+	repo    *repository.Repository
+	backend Backend
 }
 
 // QueueInfo holds information about a specific queue.
@@ -34,50 +37,58 @@ type QueueInfo struct {
 	Key              string `json:"key"`
 	KeyWithoutPrefix string `json:"key_without_prefix"`
 	NumberOfItems    int64  `json:"number_of_items"`
+	Paused           bool   `json:"paused"`
+}
+
+func init() {
+	// job.Dispatch enqueues through the queues without importing this package
+	job.SetDispatcher(func(ctx context.Context, name string, j *job.Job) error {
+		return NewQueue(name).Enqueue(ctx, j)
+	})
 }
 
 func NewQueue(key string) *Queue {
+	repo := repository.NewRepository()
+
+	return NewQueueWithBackend(key, newBackend(key, repo))
+}
+
+// NewQueueWithBackend creates a queue that keeps its jobs in the given backend instead of the configured one.
+func NewQueueWithBackend(key string, backend Backend) *Queue {
 	return &Queue{
 		Key:              rdb.AddQueuePrefix(key),
 		KeyWithoutPrefix: key,
 		repo:             repository.NewRepository(),
+		backend:          backend,
 	}
 }
 
 // Adds an item to the source list (the end of the queue).
+// A unique job whose key is still locked is not enqueued, instead its ID is set to the ID of the existing job.
 func (q *Queue) Enqueue(ctx context.Context, jobs ...*job.Job) error {
 	rdbClient := rdb.GetRedisClient()
 
 	for _, j := range jobs {
-		jobBytes, err := sonic.Marshal(j)
-		if err != nil {
-			return err
+		if j.Queue == "" {
+			j.Queue = q.KeyWithoutPrefix
 		}
 
-		// Add job to postgres for backup
-		_, err = q.repo.Job.AddJob(ctx, model.Job{
-			ID:          j.ID,
-			Queue:       q.KeyWithoutPrefix,
-			HandlerName: j.HandlerName,
-			Payload:     j.Payload,
-			MaxAttempts: j.MaxAttempts,
-			Delay:       j.Delay,
-			Status:      job.StatusPending,
-			CreatedAt:   j.CreatedAt,
-		})
-		if err != nil {
-			logger.Log.Error("Error adding job to postgres", zap.Error(err))
-			return err
-		}
+		if j.UniqueKey != "" {
+			existingID, err := acquireUniqueLock(ctx, rdbClient, j)
+			if err != nil {
+				logger.Log.Error("Error acquiring unique job lock", zap.Error(err))
+				return err
+			}
 
-		// Add job to redis
-		err = rdbClient.LPush(ctx, q.Key, jobBytes).Err()
-		if err != nil {
-			return err
+			if existingID != uuid.Nil {
+				logger.Log.Info("Skipping duplicate job", zap.String("unique_key", j.UniqueKey), zap.String("existing_job_id", existingID.String()))
+				j.ID = existingID
+				continue
+			}
 		}
 
-		if err != nil {
-			logger.Log.Error("Error adding job to redis", zap.Error(err))
+		if err := q.push(ctx, j); err != nil {
+			releaseUniqueLock(ctx, rdbClient, j)
 			return err
 		}
 	}
@@ -85,58 +96,69 @@ func (q *Queue) Enqueue(ctx context.Context, jobs ...*job.Job) error {
 	return nil
 }
 
-// Restore pending jobs from postgres to redis.
-func (q *Queue) EnqueuePendingJobs(ctx context.Context, jobs ...*job.Job) error {
-	rdbClient := rdb.GetRedisClient()
-
-	for _, j := range jobs {
-		jobBytes, err := sonic.Marshal(j)
-		if err != nil {
-			return err
-		}
-		if err != nil {
-			logger.Log.Error("Error adding job to postgres", zap.Error(err))
+// push stores the job in postgres for backup and adds it to the backend.
+func (q *Queue) push(ctx context.Context, j *job.Job) error {
+	var chainBytes []byte
+	if len(j.Chain) > 0 {
+		var err error
+		if chainBytes, err = sonic.Marshal(j.Chain); err != nil {
 			return err
 		}
+	}
 
-		// Add job to redis
-		err = rdbClient.LPush(ctx, q.Key, jobBytes).Err()
-		if err != nil {
-			return err
-		}
+	// Add job to postgres for backup
+	_, err := q.repo.Job.AddJob(ctx, model.Job{
+		ID:          j.ID,
+		Queue:       q.KeyWithoutPrefix,
+		HandlerName: j.HandlerName,
+		Payload:     j.Payload,
+		MaxAttempts: j.MaxAttempts,
+		Delay:       j.Delay,
+		Status:      job.StatusPending,
+		BatchID:     j.BatchID,
+		Chain:       chainBytes,
+		UniqueKey:   j.UniqueKey,
+		UniqueFor:   j.UniqueFor,
+		UniqueUntil: j.UniqueUntil,
+		TTL:         j.TTL,
+		Codec:       j.Codec,
+		CallbackURL: j.CallbackURL,
+		CreatedAt:   j.CreatedAt,
+	})
+	if err != nil {
+		logger.Log.Error("Error adding job to postgres", zap.Error(err))
+		return err
+	}
 
-		if err != nil {
-			logger.Log.Error("Error adding job to redis", zap.Error(err))
-			return err
-		}
+	// Add job to the backend
+	if err := q.backend.Push(ctx, j); err != nil {
+		logger.Log.Error("Error adding job to the queue backend", zap.Error(err))
+		return err
 	}
 
+	metrics.JobsEnqueued.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
+	q.emit(ctx, EventEnqueued, j, nil)
+
 	return nil
 }
 
-// Restore failed jobs from postgres to redis.
-func (q *Queue) EnqueueFailedJobs(ctx context.Context, jobs ...*job.Job) error {
-	rdbClient := rdb.GetRedisClient()
-
+// Restore pending jobs from postgres to the backend.
+func (q *Queue) EnqueuePendingJobs(ctx context.Context, jobs ...*job.Job) error {
 	for _, j := range jobs {
-		jobBytes, err := sonic.Marshal(j)
-		if err != nil {
-			return err
-		}
-
-		if err != nil {
-			logger.Log.Error("Error adding job to postgres", zap.Error(err))
+		if err := q.backend.Push(ctx, j); err != nil {
+			logger.Log.Error("Error adding job to the queue backend", zap.Error(err))
 			return err
 		}
+	}
 
-		// Add job to redis
-		err = rdbClient.LPush(ctx, q.Key+"_failed", jobBytes).Err()
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		if err != nil {
-			logger.Log.Error("Error adding job to redis", zap.Error(err))
+// Restore failed jobs from postgres to the backend.
+func (q *Queue) EnqueueFailedJobs(ctx context.Context, jobs ...*job.Job) error {
+	for _, j := range jobs {
+		if err := q.backend.AddFailed(ctx, j); err != nil {
+			logger.Log.Error("Error adding job to the queue backend", zap.Error(err))
 			return err
 		}
 	}
@@ -144,192 +166,197 @@ func (q *Queue) EnqueueFailedJobs(ctx context.Context, jobs ...*job.Job) error {
 	return nil
 }
 
-// Removes an item from the source list (the start of the queue) and adds it to the destkey list (temporary storage location).
+// Removes an item from the start of the queue and holds it until it is processed, waiting up to timeout for one.
 func (q *Queue) Dequeue(ctx context.Context, timeout time.Duration) (*job.Job, error) {
-	sourceKey := q.Key
-	destKey := q.Key + "_attempt"
-	rdbClient := rdb.GetRedisClient()
-
-	// Move the job from the source list to the temporary list
-	result, err := rdbClient.BLMove(ctx, sourceKey, destKey, "RIGHT", "LEFT", timeout).Result()
-
-	if err == redis.Nil {
-		return nil, nil
-	}
-
-	if err != nil {
+	j, err := q.backend.Pop(ctx, timeout)
+	if err != nil || j == nil {
 		return nil, err
 	}
 
-	var j job.Job
-	err = sonic.Unmarshal([]byte(result), &j)
-	if err != nil {
-		return nil, err
-	}
+	return q.claim(ctx, j)
+}
 
-	// Update status of job in postgres
-	if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusProcessing); err != nil {
-		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+// TryDequeue works like Dequeue but returns immediately with a nil job when the queue is empty.
+func (q *Queue) TryDequeue(ctx context.Context) (*job.Job, error) {
+	j, err := q.backend.Pop(ctx, 0)
+	if err != nil || j == nil {
 		return nil, err
 	}
 
-	// Check if the job has reached the maximum number of attempts
-	if j.MaxAttempts > 0 && j.Attempts >= j.MaxAttempts {
-		// Remove the job from the temporary list, it has reached the maximum number of attempts
-		_, _ = rdbClient.LRem(ctx, destKey, 1, result).Result()
-		return nil, fmt.Errorf("job %s reached the maximum number of attempts (%d)", j.ID, j.MaxAttempts)
-	}
+	return q.claim(ctx, j)
+}
 
-	// Increment the number of attempts and update the job in the temporary list
-	j.Attempts++
-	jobBytes, err := sonic.Marshal(&j)
-	if err != nil {
+// claim marks a job that was just reserved by the backend as processing.
+func (q *Queue) claim(ctx context.Context, j *job.Job) (*job.Job, error) {
+	rdbClient := rdb.GetRedisClient()
+
+	// Check if the job has exceeded the maximum number of attempts, the backend already counted this one.
+	// It is failed like a job whose handler failed its last attempt, so that postgres and the failed jobs know about it
+	if j.MaxAttempts > 0 && j.Attempts > j.MaxAttempts {
+		err := fmt.Errorf("job %s reached the maximum number of attempts (%d)", j.ID, j.MaxAttempts)
+		if failErr := q.RemoveProcessed(ctx, j.ID, err); failErr != nil {
+			logger.Log.Error("Error failing job over its maximum number of attempts", zap.String("ID", j.ID.String()), zap.Error(failErr))
+		}
 		return nil, err
 	}
 
-	// Remove the job from the temporary list
-	_, err = rdbClient.LRem(ctx, destKey, 1, result).Result()
-	if err != nil {
+	// Update status and attempts of job in postgres
+	if err := q.repo.Job.MarkJobProcessing(ctx, j.ID, j.Attempts); err != nil {
+		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
 		return nil, err
 	}
 
-	// Add the updated job to the temporary list
-	err = rdbClient.LPush(ctx, destKey, jobBytes).Err()
-	if err != nil {
-		return nil, err
+	// A job unique until processing may be enqueued again as soon as it starts
+	if j.UniqueUntil == job.UniqueUntilProcessing {
+		releaseUniqueLock(ctx, rdbClient, j)
 	}
 
-	return &j, nil
+	return j, nil
 }
 
-// Removes the processed item with the given job ID from the destkey list (temporary storage location).
+// Removes the processed job with the given job ID from the processing jobs.
 func (q *Queue) RemoveProcessed(ctx context.Context, jobID uuid.UUID, jobError error) error {
-	destKey := q.Key + "_attempt"
-	sourceKey := q.Key
-	failedJobsKey := q.Key + "_failed"
 	rdbClient := rdb.GetRedisClient()
 
-	queues := rdbClient.LRange(ctx, destKey, 0, -1)
-	for _, queueItem := range queues.Val() {
-		var j job.Job
-		if err := sonic.Unmarshal([]byte(queueItem), &j); err != nil {
-			return err
+	j, err := q.backend.Find(ctx, StateProcessing, jobID)
+	if errors.Is(err, ErrJobNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// if the job failed, retry it or move it to the failed jobs
+	if jobError != nil {
+		j.Errors = append(j.Errors, jobError.Error())
+		if err := q.repo.Job.UpdateJobErrors(ctx, j.ID, j.Errors); err != nil {
+			logger.Log.Error("Error updating job errors in postgres", zap.Error(err))
 		}
 
-		if j.ID == jobID {
-			// Remove the job from the temporary list
-			if err := removeJobFromList(ctx, rdbClient, destKey, queueItem); err != nil {
-				return err
-			}
+		if err := q.handleFailedJob(ctx, j); err != nil {
+			return err
+		}
 
-			// if the job failed, add it to the failed_jobs list
-			if jobError != nil {
-				j.Errors = append(j.Errors, jobError.Error())
-				return handleFailedJob(ctx, rdbClient, q.KeyWithoutPrefix, q.repo, j, sourceKey, failedJobsKey)
-			}
+		if isFinalAttempt(*j) {
+			q.emit(ctx, EventFailed, j, jobError)
+			q.afterFailure(ctx, j)
+		} else {
+			q.emit(ctx, EventRetried, j, jobError)
+		}
+		return nil
+	}
 
-			// if the job was successful, then update the job status to completed in postgres
-			if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusCompleted); err != nil {
-				logger.Log.Error("Error updating job status in postgres", zap.Error(err))
-				return err
-			}
+	// Remove the job from the processing jobs
+	if err := q.backend.Ack(ctx, j); err != nil {
+		return err
+	}
 
-			break
-		}
+	// if the job was successful, then update the job status to completed in postgres
+	if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusCompleted); err != nil {
+		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+		return err
 	}
 
+	releaseUniqueLock(ctx, rdbClient, j)
+	metrics.JobsProcessed.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
+	q.emit(ctx, EventSucceeded, j, nil)
+	q.afterSuccess(ctx, j)
+
 	return nil
 }
 
-// Remove the job from the temporary list if it was processed successfully
-func removeJobFromList(ctx context.Context, rdbClient redis.Cmdable, destKey string, queueItem string) error {
-	// Remove the job from the temporary list
-	if _, err := rdbClient.LRem(ctx, destKey, 1, queueItem).Result(); err != nil {
+// finishExpired removes a dequeued job whose TTL elapsed from the processing jobs. Its chain is dropped
+// and it counts as failed in its batch.
+func (q *Queue) finishExpired(ctx context.Context, j *job.Job) error {
+	if err := q.backend.Ack(ctx, j); err != nil && !errors.Is(err, ErrJobNotFound) {
 		return err
 	}
+
+	if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusExpired); err != nil {
+		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+		return err
+	}
+
+	releaseUniqueLock(ctx, rdb.GetRedisClient(), j)
+	q.emit(ctx, EventExpired, j, nil)
+	q.afterFailure(ctx, j)
+
 	return nil
 }
 
-// If the job failed, add it to the failed_jobs list
-func handleFailedJob(ctx context.Context, rdbClient redis.Cmdable, queue string, repo *repository.Repository, j job.Job, sourceKey string, failedJobsKey string) error {
-	if j.MaxAttempts == 0 || j.Attempts < j.MaxAttempts {
-		time.Sleep(time.Duration(j.Delay) * time.Second)
-		jobBytes, err := sonic.Marshal(&j)
-		if err != nil {
-			return err
-		}
+// isFinalAttempt reports whether a failed job has no attempts left and goes to the failed_jobs list.
+func isFinalAttempt(j job.Job) bool {
+	return j.MaxAttempts > 0 && j.Attempts >= j.MaxAttempts
+}
 
+// handleFailedJob puts a failed job back to the queue, or moves it to the failed jobs when it has no attempts left.
+func (q *Queue) handleFailedJob(ctx context.Context, j *job.Job) error {
+	if !isFinalAttempt(*j) {
 		// Update job status in postgres
-		if err := repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusPending); err != nil {
+		if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusPending); err != nil {
 			logger.Log.Error("Error updating job status in postgres", zap.Error(err))
 			return err
 		}
 
-		// Add the job back to the source list (the beginning of the queue) with the delay
-		err = rdbClient.LPush(ctx, sourceKey, jobBytes).Err()
-		if err != nil {
-			return err
-		}
-	} else {
-		// update job status in postgres
-		if err := repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusFailed); err != nil {
-			logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+		// Add the job back to the queue, through the delayed jobs if it has a delay
+		if err := q.backend.Nack(ctx, j, time.Duration(j.Delay)*time.Second); err != nil {
 			return err
 		}
 
-		// Add failed job to postgres
-		_, err := repo.Job.AddFailedJob(ctx, model.FailedJob{
-			JobID:    j.ID,
-			Queue:    queue,
-			Payload:  j.Payload,
-			Error:    strings.Join(j.Errors, ","),
-			FailedAt: time.Now(),
-		})
-		if err != nil {
-			logger.Log.Error("Error adding failed job to postgres", zap.Error(err))
-			return err
-		}
+		metrics.JobsRetried.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
+		return nil
+	}
 
-		if err := addJobToFailedList(ctx, rdbClient, j, failedJobsKey); err != nil {
-			return err
-		}
+	// update job status in postgres
+	if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusFailed); err != nil {
+		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+		return err
 	}
-	return nil
-}
 
-func addJobToFailedList(ctx context.Context, rdbClient redis.Cmdable, job job.Job, failedJobsKey string) error {
-	logger.Log.Info("Job has reached the maximum number of attempts. It will be added to the failed_jobs list", zap.String("job_id", job.ID.String()))
-	jobBytes, err := sonic.Marshal(&job)
+	// Add failed job to postgres
+	_, err := q.repo.Job.AddFailedJob(ctx, model.FailedJob{
+		JobID:    j.ID,
+		Queue:    q.KeyWithoutPrefix,
+		Payload:  j.Payload,
+		Error:    strings.Join(j.Errors, ","),
+		FailedAt: time.Now(),
+	})
 	if err != nil {
+		logger.Log.Error("Error adding failed job to postgres", zap.Error(err))
 		return err
 	}
-	err = rdbClient.LPush(ctx, failedJobsKey, jobBytes).Err()
-	if err != nil {
+
+	logger.Log.Info("Job has reached the maximum number of attempts. It will be added to the failed_jobs list", zap.String("job_id", j.ID.String()))
+	if err := q.backend.Fail(ctx, j); err != nil {
 		return err
 	}
+
+	releaseUniqueLock(ctx, rdb.GetRedisClient(), j)
+	metrics.JobsFailed.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
 	return nil
 }
 
-// RetryFailedByJobID moves a failed item with the given job ID from the destkey list back to the source list for retrying.
+// RetryFailedByJobID moves the failed job with the given job ID back to the queue for retrying.
 func (q *Queue) RetryFailedByJobID(ctx context.Context, jobID uuid.UUID) error {
-	failedJobsKey := q.Key + "_failed"
-	destkey := q.Key
-	rdbClient := rdb.GetRedisClient()
-
-	queues, err := rdbClient.LRange(ctx, failedJobsKey, 0, -1).Result()
+	j, err := q.backend.Retry(ctx, jobID)
 	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return fmt.Errorf("job with ID %s not found in failed list: %w", jobID, ErrJobNotFound)
+		}
 		return err
 	}
 
-	found, err := findAndRetryJob(ctx, rdbClient, queues, jobID, failedJobsKey, destkey)
-	if err != nil {
+	metrics.JobsRetried.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
+	if err := q.markRetried(ctx, jobID); err != nil {
 		return err
 	}
 
-	if !found {
-		return fmt.Errorf("job with ID %s not found in temporary list", jobID)
-	}
+	q.emit(ctx, EventRetried, j, nil)
+	return nil
+}
 
+// markRetried sets a retried job back to pending in postgres and removes its failed_jobs record.
+func (q *Queue) markRetried(ctx context.Context, jobID uuid.UUID) error {
 	// update job status in postgres
 	if err := q.repo.Job.UpdateJobStatus(ctx, jobID, job.StatusPending); err != nil {
 		logger.Log.Error("Error updating job status in postgres", zap.Error(err))
@@ -345,93 +372,28 @@ func (q *Queue) RetryFailedByJobID(ctx context.Context, jobID uuid.UUID) error {
 	return nil
 }
 
-func findAndRetryJob(ctx context.Context, rdbClient redis.Cmdable, queues []string, jobID uuid.UUID, failedJobsKey, destkey string) (bool, error) {
-	for _, failedItem := range queues {
-		var job job.Job
-		if err := sonic.Unmarshal([]byte(failedItem), &job); err != nil {
-			return false, err
-		}
-
-		if job.ID == jobID {
-			if err := resetAndMoveJob(ctx, rdbClient, job, failedJobsKey, destkey, failedItem); err != nil {
-				return false, err
-			}
-			return true, nil
-		}
-	}
-	return false, nil
-}
-
-func resetAndMoveJob(ctx context.Context, rdbClient redis.Cmdable, job job.Job, failedJobsKey, destkey, failedItem string) error {
-	job.Attempts = 0
-	updatedItem, err := sonic.Marshal(&job)
-	if err != nil {
-		return err
-	}
-
-	if _, err := rdbClient.LRem(ctx, failedJobsKey, 1, failedItem).Result(); err != nil {
-		return err
-	}
-
-	err = rdbClient.RPush(ctx, destkey, updatedItem).Err()
+// Moves all failed jobs back to the queue for retrying.
+func (q *Queue) RetryAllFailed(ctx context.Context) (int, error) {
+	failedJobs, _, err := q.backend.List(ctx, StateFailed, 0, 0)
 	if err != nil {
-		_ = rdbClient.LPush(ctx, failedJobsKey, failedItem).Err()
-		return err
+		return 0, err
 	}
 
-	return nil
-}
-
-// Moves all failed items from the destkey list back to the source list for retrying.
-func (q *Queue) RetryAllFailed(ctx context.Context) (int, error) {
-	failedJobsKey := q.Key + "_failed"
-	destkey := q.Key
-	rdbClient := rdb.GetRedisClient()
-
 	count := 0
-
-	for {
-		// Remove the failed item from the temporary list.
-		failedItem, err := rdbClient.LPop(ctx, failedJobsKey).Result()
-		if err == redis.Nil {
-			// No more items left in the temporary list, break the loop.
-			break
-		}
-		if err != nil {
-			return count, err
-		}
-
-		// Reset the attempts counter
-		var j job.Job
-		if err := sonic.Unmarshal([]byte(failedItem), &j); err != nil {
-			return count, err
-		}
-		j.Attempts = 0
-		updatedItem, err := sonic.Marshal(&j)
-		if err != nil {
-			return count, err
-		}
-
-		// Add the failed item back to the source list with the reset attempts counter.
-		err = rdbClient.RPush(ctx, destkey, updatedItem).Err()
-		if err != nil {
-
-			// Add the failed item back to the temporary list in case of an RPush error.
-			_ = rdbClient.LPush(ctx, failedJobsKey, failedItem).Err()
-			return count, err
-		}
-
-		// update job status in postgres
-		if err := q.repo.Job.UpdateJobStatus(ctx, j.ID, job.StatusPending); err != nil {
-			logger.Log.Error("Error updating job status in postgres", zap.Error(err))
+	for _, j := range failedJobs {
+		if _, err := q.backend.Retry(ctx, j.ID); err != nil {
+			if errors.Is(err, ErrJobNotFound) {
+				// Retried or removed meanwhile
+				continue
+			}
 			return count, err
 		}
 
-		// remove job from failed_jobs in postgres
-		if err := q.repo.Job.RemoveFailedJob(ctx, j.ID); err != nil {
-			logger.Log.Error("Error removing job from failed_jobs in postgres", zap.Error(err))
+		metrics.JobsRetried.WithLabelValues(q.KeyWithoutPrefix, j.HandlerName).Inc()
+		if err := q.markRetried(ctx, j.ID); err != nil {
 			return count, err
 		}
+		q.emit(ctx, EventRetried, &j, nil)
 
 		count++
 	}
@@ -439,23 +401,19 @@ func (q *Queue) RetryAllFailed(ctx context.Context) (int, error) {
 	return count, nil
 }
 
-// Returns the current length of the source list (the number of items in the queue).
+// Returns the number of pending jobs in the queue.
 func (q *Queue) Length(ctx context.Context) (int64, error) {
-	rdbClient := rdb.GetRedisClient()
-
-	length, err := rdbClient.LLen(ctx, q.Key).Result()
+	counts, err := q.backend.Counts(ctx)
 	if err != nil {
 		return 0, err
 	}
 
-	return length, nil
+	return counts.Pending, nil
 }
 
-// IsEmpty checks if the source list (queue) is empty.
+// IsEmpty checks if the queue has no pending jobs.
 func (q *Queue) IsEmpty(ctx context.Context) (bool, error) {
-	rdbClient := rdb.GetRedisClient()
-
-	length, err := rdbClient.LLen(ctx, q.Key).Result()
+	length, err := q.Length(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -463,201 +421,182 @@ func (q *Queue) IsEmpty(ctx context.Context) (bool, error) {
 	return length == 0, nil
 }
 
-// Clear removes all items from the source list (queue).
+// Clear removes all pending and delayed jobs from the queue.
 func (q *Queue) Clear(ctx context.Context) (int64, error) {
-	rdbClient := rdb.GetRedisClient()
-
-	// Get the length of the queue before deleting the key.
-	length, err := rdbClient.LLen(ctx, q.Key).Result()
-	if err != nil {
-		return 0, fmt.Errorf("error getting length of key %s: %w", q.Key, err)
-	}
+	return q.purge(ctx, StatePending, StateDelayed)
+}
 
-	// Remove all items from the source list.
-	_, err = rdbClient.Del(ctx, q.Key).Result()
-	if err != nil {
-		return 0, err
+// purge deletes every job in the given states from the backend and from the jobs table, whatever the backend.
+// The rows go first, a job enqueued meanwhile keeps its row even if the backend purge removes it.
+func (q *Queue) purge(ctx context.Context, states ...string) (int64, error) {
+	// The postgres backend keeps its jobs in the jobs table, its purge deletes the rows
+	if _, ok := q.backend.(*postgresBackend); !ok {
+		for _, state := range states {
+			if _, err := q.repo.Job.DeleteQueueJobs(ctx, q.KeyWithoutPrefix, state); err != nil {
+				logger.Log.Error("Error deleting jobs from postgres", zap.String("queue", q.KeyWithoutPrefix), zap.String("state", state), zap.Error(err))
+				return 0, err
+			}
+		}
 	}
 
-	return length, nil
+	return q.backend.Purge(ctx, states...)
 }
 
-// RemoveJobByID removes the job with the matching job ID from the source list.
+// RemoveJobByID removes the pending job with the matching job ID.
 func (q *Queue) RemoveJobByID(ctx context.Context, jobID uuid.UUID) (bool, error) {
-	rdbClient := rdb.GetRedisClient()
-
-	queues, err := rdbClient.LRange(ctx, q.Key, 0, -1).Result()
+	j, err := q.backend.Remove(ctx, StatePending, jobID)
+	if errors.Is(err, ErrJobNotFound) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
 
-	for _, queueItem := range queues {
-		var job job.Job
-		if err := sonic.Unmarshal([]byte(queueItem), &job); err != nil {
-			return false, err
-		}
-
-		if job.ID == jobID {
-			// Remove the job with the matching ID from the source list.
-			if _, err := rdbClient.LRem(ctx, q.Key, 1, queueItem).Result(); err != nil {
-				return false, err
-			}
-
-			return true, nil
-		}
-	}
+	releaseUniqueLock(ctx, rdb.GetRedisClient(), j)
 
-	return false, nil
+	return true, nil
 }
 
-// RemoveFailedByID removes the failed item with the matching job ID from the failed list.
+// RemoveFailedByID removes the failed job with the matching job ID.
 func (q *Queue) RemoveFailedByID(ctx context.Context, jobID uuid.UUID) error {
-	failedJobsKey := q.Key + "_failed"
-	rdbClient := rdb.GetRedisClient()
-
-	queues, err := rdbClient.LRange(ctx, failedJobsKey, 0, -1).Result()
-	if err != nil {
-		return err
-	}
-
-	found := false
-	for _, failedItem := range queues {
-		var job job.Job
-		if err := sonic.Unmarshal([]byte(failedItem), &job); err != nil {
-			return err
+	if _, err := q.backend.Remove(ctx, StateFailed, jobID); err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return fmt.Errorf("job with ID %s not found in failed list: %w", jobID, ErrJobNotFound)
 		}
-
-		if job.ID == jobID {
-			found = true
-
-			// Remove the job with the matching ID from the failed list.
-			if _, err := rdbClient.LRem(ctx, failedJobsKey, 1, failedItem).Result(); err != nil {
-				return err
-			}
-
-			break
-		}
-	}
-
-	if !found {
-		return fmt.Errorf("job with ID %s not found in temporary list", jobID)
+		return err
 	}
 
 	return nil
 }
 
-// RemoveAllFailed removes all items from the failed list.
+// RemoveAllFailed removes all failed jobs.
 func (q *Queue) RemoveAllFailed(ctx context.Context) (int64, error) {
-	rdbClient := rdb.GetRedisClient()
-
-	// Get the length of the queue before deleting the key.
-	length, err := rdbClient.LLen(ctx, q.Key).Result()
-	if err != nil {
-		return 0, fmt.Errorf("error getting length of key %s: %w", q.Key, err)
-	}
-
-	// Remove all items from the failed list.
-	_, err = rdbClient.Del(ctx, q.Key+"_failed").Result()
-	if err != nil {
-		return 0, err
-	}
-
-	return length, nil
+	return q.purge(ctx, StateFailed)
 }
 
-// Peek returns the first N items in the source list without removing them.
+// Peek returns the first N pending jobs without removing them.
 func (q *Queue) Peek(ctx context.Context, count int64) ([]interface{}, error) {
-	rdbClient := rdb.GetRedisClient()
-
-	rawItems, err := rdbClient.LRange(ctx, q.Key, 0, count-1).Result()
+	jobs, _, err := q.backend.List(ctx, StatePending, 0, count)
 	if err != nil {
 		return nil, err
 	}
 
-	items := make([]interface{}, len(rawItems))
-	for i, rawItem := range rawItems {
-		var item interface{}
-		err = sonic.Unmarshal([]byte(rawItem), &item)
-		if err != nil {
-			return nil, err
-		}
-		items[i] = item
+	items := make([]interface{}, len(jobs))
+	for i, j := range jobs {
+		items[i] = j
 	}
 
 	return items, nil
 }
 
-func (q *Queue) Run(ctx context.Context) error {
-	handlerMap := job.NewHandlerMap()
-	waitingMessagePrinted := false
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Log.Info("Context canceled, stopping the Queue")
-			return ctx.Err()
-		default:
-			err, printed := processJob(ctx, q, handlerMap, waitingMessagePrinted)
-			if err != nil {
-				logger.Log.Error("Error processing job", zap.Error(err))
-			}
-			waitingMessagePrinted = printed
-		}
+// storeResult stores the result payload of a handler that implements job.ResultHandler,
+// encoded with the codec of the job so that it is compressed and encrypted like the payload.
+func (q *Queue) storeResult(ctx context.Context, j *job.Job, handler job.JobHandler) {
+	resultHandler, ok := handler.(job.ResultHandler)
+	if !ok {
+		return
 	}
-}
-
-func processJob(ctx context.Context, q *Queue, handlerMap job.HandlerMap, waitingMessagePrinted bool) (err error, printed bool) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic occurred while processing job: %v", r)
-			printed = waitingMessagePrinted
-			logger.Log.Error("Recovered from panic", zap.Any("panic", r))
-		}
-	}()
 
-	dequeuedJob, err := q.Dequeue(ctx, time.Second*5)
+	result, err := sonic.Marshal(resultHandler.Result())
 	if err != nil {
-		return fmt.Errorf("error dequeueing job: %w", err), waitingMessagePrinted
+		logger.Log.Error("Error marshaling job result", zap.String("ID", j.ID.String()), zap.Error(err))
+		return
 	}
 
-	if dequeuedJob == nil {
-		if !waitingMessagePrinted {
-			logger.Log.Info(fmt.Sprintf("waiting for %s ...", q.KeyWithoutPrefix))
-			waitingMessagePrinted = true
-		}
-		return nil, waitingMessagePrinted
+	result, err = j.EncodeResult(result)
+	if err != nil {
+		logger.Log.Error("Error encoding job result", zap.String("ID", j.ID.String()), zap.Error(err))
+		return
 	}
 
-	waitingMessagePrinted = false
+	if err := q.repo.Job.UpdateJobResult(ctx, j.ID, result); err != nil {
+		logger.Log.Error("Error updating job result in postgres", zap.String("ID", j.ID.String()), zap.Error(err))
+	}
+}
 
+// handle runs the handler of a dequeued job through the middleware and records the outcome.
+// A panic of the handler is turned into an error by the job.Recover middleware.
+func (q *Queue) handle(ctx context.Context, dequeuedJob *job.Job, handlerMap job.HandlerMap, middleware []job.Middleware) error {
 	logger.Log.Info("Starting job", zap.String("ID", dequeuedJob.ID.String()))
 
 	handlerFunc, ok := handlerMap[dequeuedJob.HandlerName]
 	if !ok {
 		err := fmt.Errorf("handler not found: %v", dequeuedJob.HandlerName)
 		q.RemoveProcessed(ctx, dequeuedJob.ID, err)
-		return err, waitingMessagePrinted
+		return err
+	}
+
+	if isCancelled(ctx, dequeuedJob.ID) {
+		logger.Log.Info("Skipping cancelled job", zap.String("ID", dequeuedJob.ID.String()))
+		return q.finishCancelled(ctx, dequeuedJob)
+	}
+
+	if q.isBatchCancelled(ctx, dequeuedJob) {
+		// The job is cancelled, not completed: its chain is dropped and it counts as failed in its batch
+		logger.Log.Info("Skipping job of a cancelled batch", zap.String("ID", dequeuedJob.ID.String()), zap.String("batch_id", dequeuedJob.BatchID.String()))
+		return q.finishCancelled(ctx, dequeuedJob)
+	}
+
+	// A payload that cannot be read fails the job like a handler error, instead of leaving it reserved
+	payload, err := dequeuedJob.DecodePayload()
+	if err != nil {
+		err = fmt.Errorf("error decoding job payload: %w", err)
+		q.RemoveProcessed(ctx, dequeuedJob.ID, err)
+		return err
 	}
 
 	handler := handlerFunc()
-	err = sonic.Unmarshal(dequeuedJob.Payload, handler)
+	err = sonic.Unmarshal(payload, handler)
 	if err != nil {
-		return fmt.Errorf("error unmarshaling job payload: %w", err), waitingMessagePrinted
+		err = fmt.Errorf("error unmarshaling job payload: %w", err)
+		q.RemoveProcessed(ctx, dequeuedJob.ID, err)
+		return err
+	}
+
+	if limit, ok := limiterFor(dequeuedJob.HandlerName, handler); ok {
+		rdbClient := rdb.GetRedisClient()
+		acquired, wait, err := acquireLimits(ctx, rdbClient, limit, dequeuedJob.ID)
+		if err != nil {
+			// The job is put back instead of being left reserved, its attempt is not counted
+			if releaseErr := q.release(ctx, dequeuedJob.ID, limiterErrorReleaseDelay); releaseErr != nil {
+				logger.Log.Error("Error releasing job", zap.String("ID", dequeuedJob.ID.String()), zap.Error(releaseErr))
+			}
+			return fmt.Errorf("error checking job rate limit: %w", err)
+		}
+		if !acquired {
+			logger.Log.Info("Releasing job over its rate limit", zap.String("ID", dequeuedJob.ID.String()), zap.String("handler", dequeuedJob.HandlerName), zap.Duration("delay", wait))
+			return q.release(ctx, dequeuedJob.ID, wait)
+		}
+		defer releaseLimits(ctx, rdbClient, limit, dequeuedJob.ID)
 	}
 
-	logger.Log.Info("Processing job", zap.String("ID", dequeuedJob.ID.String()), zap.String("handler", dequeuedJob.HandlerName))
-	handlerError := handler.Handle()
+	handlerCtx, done := withCancel(ctx, dequeuedJob.ID)
+	defer done()
+
+	handlerCtx = job.WithProgressReporter(handlerCtx, func(ctx context.Context, percent int, message string) error {
+		return q.repo.Job.UpdateJobProgress(ctx, dequeuedJob.ID, percent, message)
+	})
+	q.emit(ctx, EventStarted, dequeuedJob, nil)
+	handlerError := job.RunWithMiddleware(handlerCtx, dequeuedJob, handler, middleware)
 
-	logger.Log.Info("Finished processing job", zap.String("ID", dequeuedJob.ID.String()), zap.String("handler", dequeuedJob.HandlerName), zap.Any("error", handlerError))
+	// A handler that stopped because the job was cancelled is not retried
+	if handlerError != nil && errors.Is(context.Cause(handlerCtx), ErrJobCancelled) {
+		return q.finishCancelled(ctx, dequeuedJob)
+	}
+
+	// An expired job is neither completed nor retried
+	if errors.Is(handlerError, job.ErrJobExpired) {
+		return q.finishExpired(ctx, dequeuedJob)
+	}
 
-	if handlerError != nil {
-		logger.Log.Error("Error handling job: %v", zap.String("ID", dequeuedJob.ID.String()), zap.String("handler", dequeuedJob.HandlerName), zap.Any("error", handlerError))
+	if handlerError == nil {
+		q.storeResult(ctx, dequeuedJob, handler)
 	}
 
 	err = q.RemoveProcessed(ctx, dequeuedJob.ID, handlerError)
 	if err != nil {
-		return fmt.Errorf("error removing processed job: %w", err), waitingMessagePrinted
+		return fmt.Errorf("error removing processed job: %w", err)
 	}
 
-	return nil, waitingMessagePrinted
+	return nil
 }
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"webapi/internal/db/rdb"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+)
+
+// Delete the lock only if it is still held by the given job.
+var releaseUniqueLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func uniqueLockKey(key string) string {
+	return rdb.AddPrefix("job_unique_" + key)
+}
+
+// acquireUniqueLock locks the unique key of the job. It returns the ID of the job
+// already holding the lock, or uuid.Nil when the lock was acquired for j.
+func acquireUniqueLock(ctx context.Context, rdbClient redis.Cmdable, j *job.Job) (uuid.UUID, error) {
+	key := uniqueLockKey(j.UniqueKey)
+	lockFor := time.Duration(j.UniqueFor) * time.Second
+	if lockFor <= 0 {
+		lockFor = job.DefaultUniqueFor
+	}
+
+	// Retry once in case the lock expires between SETNX and GET
+	for i := 0; i < 2; i++ {
+		acquired, err := rdbClient.SetNX(ctx, key, j.ID.String(), lockFor).Result()
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if acquired {
+			return uuid.Nil, nil
+		}
+
+		existing, err := rdbClient.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		return uuid.Parse(existing)
+	}
+
+	return uuid.Nil, nil
+}
+
+// releaseUniqueLock releases the unique key of the job, if it has one and still holds it.
+func releaseUniqueLock(ctx context.Context, rdbClient redis.Cmdable, j *job.Job) {
+	if j.UniqueKey == "" {
+		return
+	}
+
+	err := releaseUniqueLockScript.Run(ctx, rdbClient, []string{uniqueLockKey(j.UniqueKey)}, j.ID.String()).Err()
+	if err != nil && err != redis.Nil {
+		logger.Log.Error("Error releasing unique job lock", zap.String("job_id", j.ID.String()), zap.String("unique_key", j.UniqueKey), zap.Error(err))
+	}
+}
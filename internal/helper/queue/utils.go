@@ -3,12 +3,26 @@ package queue
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
 	"webapi/internal/db/rdb"
 )
 
+// Suffixes of the keys that hold the other states of a queue, next to its source list.
+var queueStateSuffixes = []string{"_attempt", "_failed", "_delayed", "_paused", "_stream", "_reserved", "_bodies", "_attempt_counts"}
+
+// isSourceListKey reports whether the key is the source list of a queue.
+func isSourceListKey(key string) bool {
+	for _, suffix := range queueStateSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
 const (
 	ERROR_SCANNING_REDIS_KEY    = "error scanning Redis keys: %w"
 	ERROR_GETTING_LENGTH_OF_KEY = "error getting length of key %s: %w"
@@ -32,7 +46,7 @@ func ListQueueKeys(ctx context.Context) ([]string, error) {
 		}
 
 		for _, key := range batch {
-			if !strings.HasSuffix(key, "_attempt") && !strings.HasSuffix(key, "_failed") {
+			if isSourceListKey(key) {
 				key = strings.TrimPrefix(key, prefix+"_")
 				keys = append(keys, key)
 			}
@@ -43,15 +57,24 @@ func ListQueueKeys(ctx context.Context) ([]string, error) {
 		}
 	}
 
+	// Queues on other backends have no source list in redis
+	for _, name := range configuredQueues() {
+		if !slices.Contains(keys, name) {
+			keys = append(keys, name)
+		}
+	}
+
 	return keys, nil
 }
 
-// ListQueueKeysAndLengths retrieves all queue keys matching the queue key prefix
-// and the number of items in each queue.
+// ListQueueKeysAndLengths retrieves all queue keys matching the queue key prefix and the queues
+// configured on other backends, with the number of items in each queue and whether it is paused.
+// A paused queue is listed even when it has no items.
 func ListQueueKeysAndLengths(ctx context.Context) ([]QueueInfo, error) {
 	prefix := rdb.GetQueuePrefix()
 	rdbClient := rdb.GetRedisClient()
 	var keys []string
+	paused := make(map[string]bool)
 	var cursor uint64
 	var err error
 
@@ -63,8 +86,10 @@ func ListQueueKeysAndLengths(ctx context.Context) ([]QueueInfo, error) {
 		}
 
 		for _, key := range batch {
-			if !strings.HasSuffix(key, "_attempt") && !strings.HasSuffix(key, "_failed") {
+			if isSourceListKey(key) {
 				keys = append(keys, key)
+			} else if strings.HasSuffix(key, "_paused") {
+				paused[strings.TrimSuffix(key, "_paused")] = true
 			}
 		}
 
@@ -73,10 +98,22 @@ func ListQueueKeysAndLengths(ctx context.Context) ([]QueueInfo, error) {
 		}
 	}
 
+	for key := range paused {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, name := range configuredQueues() {
+		if key := rdb.AddQueuePrefix(name); !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
 	// Retrieve the length of each queue.
 	queueInfos := make([]QueueInfo, 0, len(keys))
 	for _, key := range keys {
-		length, err := rdbClient.LLen(ctx, key).Result()
+		length, err := NewQueue(strings.TrimPrefix(key, prefix+"_")).Length(ctx)
 		if err != nil {
 			return nil, fmt.Errorf(ERROR_GETTING_LENGTH_OF_KEY, key, err)
 		}
@@ -85,6 +122,7 @@ func ListQueueKeysAndLengths(ctx context.Context) ([]QueueInfo, error) {
 			Key:              key,
 			KeyWithoutPrefix: strings.TrimPrefix(key, prefix+"_"),
 			NumberOfItems:    length,
+			Paused:           paused[key],
 		}
 		queueInfos = append(queueInfos, queueInfo)
 	}
@@ -121,61 +159,54 @@ func ListFailedQueueKeys(ctx context.Context) ([]string, error) {
 	return keys, nil
 }
 
-// ClearAll deletes all queue keys matching the queue key prefix and returns the total number of items cleared.
+// ClearAll deletes the pending jobs of all queues and returns the total number of items cleared.
 func ClearAll(ctx context.Context) (int64, error) {
 	queueKeys, err := ListQueueKeys(ctx)
 	if err != nil {
 		return 0, fmt.Errorf(ERROR_LISTING_QUEUE_KEY, err)
 	}
 
-	rdbClient := rdb.GetRedisClient()
 	totalCleared := int64(0)
 	for _, key := range queueKeys {
-		key := rdb.AddQueuePrefix(key)
-
-		// Get the length of the queue before deleting the key.
-		length, err := rdbClient.LLen(ctx, key).Result()
-		if err != nil {
-			return 0, fmt.Errorf(ERROR_GETTING_LENGTH_OF_KEY, key, err)
-		}
-
-		// Delete the key.
-		err = rdbClient.Del(ctx, key).Err()
+		cleared, err := NewQueue(key).Clear(ctx)
 		if err != nil {
 			return 0, fmt.Errorf(ERROR_DELETING_KEY, key, err)
 		}
 
-		// Add the number of items cleared from this key to the total count.
-		totalCleared += length
+		// Add the number of items cleared from this queue to the total count.
+		totalCleared += cleared
 	}
 
 	return totalCleared, nil
 }
 
-// FlushAllFailed deletes all failed queue keys matching the queue key prefix and returns the total number of items cleared.
+// FlushAllFailed deletes the failed jobs of all queues and returns the total number of items cleared.
 func FlushAllFailed(ctx context.Context) (int64, error) {
-	queueKeys, err := ListFailedQueueKeys(ctx)
+	failedKeys, err := ListFailedQueueKeys(ctx)
 	if err != nil {
 		return 0, fmt.Errorf(ERROR_LISTING_QUEUE_KEY, err)
 	}
 
-	rdbClient := rdb.GetRedisClient()
-	totalCleared := int64(0)
-	for _, key := range queueKeys {
-		// Get the length of the queue before deleting the key.
-		length, err := rdbClient.LLen(ctx, key).Result()
-		if err != nil {
-			return 0, fmt.Errorf(ERROR_GETTING_LENGTH_OF_KEY, key, err)
+	prefix := rdb.GetQueuePrefix()
+	var names []string
+	for _, key := range failedKeys {
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(key, prefix+"_"), "_failed"))
+	}
+	for _, name := range configuredQueues() {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
 		}
+	}
 
-		// Delete the key.
-		err = rdbClient.Del(ctx, key).Err()
+	totalCleared := int64(0)
+	for _, name := range names {
+		cleared, err := NewQueue(name).RemoveAllFailed(ctx)
 		if err != nil {
-			return 0, fmt.Errorf(ERROR_DELETING_KEY, key, err)
+			return 0, fmt.Errorf(ERROR_DELETING_KEY, name, err)
 		}
 
-		// Add the number of items cleared from this key to the total count.
-		totalCleared += length
+		// Add the number of items cleared from this queue to the total count.
+		totalCleared += cleared
 	}
 
 	return totalCleared, nil
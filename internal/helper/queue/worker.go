@@ -0,0 +1,302 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"webapi/config"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+	"webapi/internal/metrics"
+	"webapi/internal/repository"
+)
+
+const (
+	StrategyStrict   = "strict"   // StrategyStrict always drains the queues in the order they are listed.
+	StrategyWeighted = "weighted" // StrategyWeighted picks the next queue at random, proportionally to its weight.
+
+	defaultPollInterval = time.Second
+)
+
+// QueueWeight is a queue name with its relative priority, parsed from a spec like "critical:5,default:2,low:1".
+type QueueWeight struct {
+	Name   string
+	Weight int
+}
+
+// ParseQueueWeights parses a comma separated list of queue names with optional weights.
+// A queue without a weight gets a weight of 1.
+func ParseQueueWeights(spec string) ([]QueueWeight, error) {
+	var weights []QueueWeight
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, weightStr, hasWeight := strings.Cut(item, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid queue spec %q: missing queue name", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid queue spec %q: queue %s is listed twice", item, name)
+		}
+		seen[name] = true
+
+		weight := 1
+		if hasWeight {
+			var err error
+			weight, err = strconv.Atoi(strings.TrimSpace(weightStr))
+			if err != nil || weight < 1 {
+				return nil, fmt.Errorf("invalid queue spec %q: weight must be a positive integer", item)
+			}
+		}
+
+		weights = append(weights, QueueWeight{Name: name, Weight: weight})
+	}
+
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("invalid queue spec %q: no queue given", spec)
+	}
+
+	return weights, nil
+}
+
+// WorkerPool pulls jobs from several queues, either strictly in order or weighted.
+// Every call to Run is one worker, all workers of a pool share the per-queue concurrency limits.
+type WorkerPool struct {
+	queues       []*Queue
+	weights      []int
+	strategy     string
+	pollInterval time.Duration
+	limits       map[string]chan struct{}
+	middleware   []job.Middleware
+}
+
+// NewWorkerPool creates a pool for the given queues. Per-queue concurrency limits and backends are read from config,
+// the jobs run through the middleware of job.NewMiddleware.
+func NewWorkerPool(queueWeights []QueueWeight, strategy string) (*WorkerPool, error) {
+	cfg := config.GetConfig().Queue
+
+	if strategy == "" {
+		strategy = cfg.Strategy
+	}
+	if strategy == "" {
+		strategy = StrategyWeighted
+	}
+	if strategy != StrategyStrict && strategy != StrategyWeighted {
+		return nil, fmt.Errorf("unknown queue strategy %q, expected %s or %s", strategy, StrategyStrict, StrategyWeighted)
+	}
+
+	pollInterval := defaultPollInterval
+	if cfg.PollInterval > 0 {
+		pollInterval = time.Duration(cfg.PollInterval) * time.Millisecond
+	}
+
+	concurrency := make(map[string]int)
+	for _, options := range cfg.Queues {
+		if err := validateBackend(options.Backend); err != nil {
+			return nil, fmt.Errorf("queue %s: %w", options.Name, err)
+		}
+		concurrency[options.Name] = options.Concurrency
+	}
+
+	p := &WorkerPool{
+		strategy:     strategy,
+		pollInterval: pollInterval,
+		limits:       make(map[string]chan struct{}),
+		middleware:   job.NewMiddleware(),
+	}
+	for _, qw := range queueWeights {
+		p.queues = append(p.queues, NewQueue(qw.Name))
+		p.weights = append(p.weights, qw.Weight)
+		if limit := concurrency[qw.Name]; limit > 0 {
+			p.limits[qw.Name] = make(chan struct{}, limit)
+		}
+	}
+
+	return p, nil
+}
+
+// Strategy returns the strategy used to pick the next queue.
+func (p *WorkerPool) Strategy() string {
+	return p.strategy
+}
+
+// Use adds middleware that every job handler of the pool runs through, inside the middleware already added.
+// It must be called before the workers start.
+func (p *WorkerPool) Use(middleware ...job.Middleware) {
+	p.middleware = append(p.middleware, middleware...)
+}
+
+// CheckHandlers returns an error when a pending or processing job of the pool's queues references
+// a handler that is not registered, so that the workers fail at startup instead of failing every such job.
+func (p *WorkerPool) CheckHandlers(ctx context.Context) error {
+	names := make([]string, 0, len(p.queues))
+	for _, q := range p.queues {
+		names = append(names, q.KeyWithoutPrefix)
+	}
+
+	handlerNames, err := repository.NewRepository().Job.GetUnfinishedHandlerNames(ctx, names)
+	if err != nil {
+		return fmt.Errorf("error reading the handlers of unfinished jobs: %w", err)
+	}
+
+	var missing []string
+	for _, name := range handlerNames {
+		if !job.IsRegistered(name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", job.ErrHandlerNotRegistered, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Run is a single worker loop. It returns when the context is canceled.
+func (p *WorkerPool) Run(ctx context.Context) error {
+	handlerMap := job.NewHandlerMap()
+	waitingMessagePrinted := false
+
+	metrics.Workers.WithLabelValues(metrics.WorkerIdle).Inc()
+	defer metrics.Workers.WithLabelValues(metrics.WorkerIdle).Dec()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Log.Info("Context canceled, stopping the worker")
+			return ctx.Err()
+		default:
+		}
+
+		processed, err := p.processNext(ctx, handlerMap)
+		if err != nil {
+			logger.Log.Error("Error processing job", zap.Error(err))
+		}
+
+		if processed {
+			waitingMessagePrinted = false
+			continue
+		}
+
+		if !waitingMessagePrinted {
+			logger.Log.Info(fmt.Sprintf("waiting for %s ...", p.queueNames()))
+			waitingMessagePrinted = true
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// processNext handles at most one job from the first queue, in pick order, that has one and a free slot.
+func (p *WorkerPool) processNext(ctx context.Context, handlerMap job.HandlerMap) (bool, error) {
+	for _, q := range p.order() {
+		slot := p.limits[q.KeyWithoutPrefix]
+		if slot != nil {
+			select {
+			case slot <- struct{}{}:
+			default:
+				// The queue is at its concurrency limit, try the next one
+				continue
+			}
+		}
+
+		processed, err := p.processFrom(ctx, q, handlerMap)
+
+		if slot != nil {
+			<-slot
+		}
+
+		if processed || err != nil {
+			return processed, err
+		}
+	}
+
+	return false, nil
+}
+
+func (p *WorkerPool) processFrom(ctx context.Context, q *Queue, handlerMap job.HandlerMap) (bool, error) {
+	paused, err := q.IsPaused(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error checking if %s is paused: %w", q.KeyWithoutPrefix, err)
+	}
+	if paused {
+		return false, nil
+	}
+
+	dequeuedJob, err := q.TryDequeue(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error dequeueing job from %s: %w", q.KeyWithoutPrefix, err)
+	}
+
+	if dequeuedJob == nil {
+		return false, nil
+	}
+
+	metrics.Workers.WithLabelValues(metrics.WorkerIdle).Dec()
+	metrics.Workers.WithLabelValues(metrics.WorkerBusy).Inc()
+	defer func() {
+		metrics.Workers.WithLabelValues(metrics.WorkerBusy).Dec()
+		metrics.Workers.WithLabelValues(metrics.WorkerIdle).Inc()
+	}()
+
+	return true, q.handle(ctx, dequeuedJob, handlerMap, p.middleware)
+}
+
+// order returns the queues in the order they should be polled for the next job.
+func (p *WorkerPool) order() []*Queue {
+	if p.strategy == StrategyStrict || len(p.queues) == 1 {
+		return p.queues
+	}
+
+	return weightedOrder(p.queues, p.weights, rand.Intn)
+}
+
+// weightedOrder draws queues without replacement, each draw proportional to the remaining weights.
+func weightedOrder(queues []*Queue, weights []int, intn func(int) int) []*Queue {
+	remaining := make([]int, len(queues))
+	copy(remaining, weights)
+
+	total := 0
+	for _, w := range remaining {
+		total += w
+	}
+
+	ordered := make([]*Queue, 0, len(queues))
+	for len(ordered) < len(queues) {
+		pick := intn(total)
+		for i, w := range remaining {
+			if pick < w {
+				ordered = append(ordered, queues[i])
+				total -= w
+				remaining[i] = 0
+				break
+			}
+			pick -= w
+		}
+	}
+
+	return ordered
+}
+
+func (p *WorkerPool) queueNames() string {
+	names := make([]string, 0, len(p.queues))
+	for _, q := range p.queues {
+		names = append(names, q.KeyWithoutPrefix)
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueueWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []QueueWeight
+		wantErr bool
+	}{
+		{
+			name: "single queue without weight",
+			spec: "default",
+			want: []QueueWeight{{Name: "default", Weight: 1}},
+		},
+		{
+			name: "weighted queues",
+			spec: "critical:5, default:2,low",
+			want: []QueueWeight{{Name: "critical", Weight: 5}, {Name: "default", Weight: 2}, {Name: "low", Weight: 1}},
+		},
+		{name: "empty spec", spec: " , ", wantErr: true},
+		{name: "missing name", spec: ":3", wantErr: true},
+		{name: "zero weight", spec: "default:0", wantErr: true},
+		{name: "not a number", spec: "default:high", wantErr: true},
+		{name: "duplicated queue", spec: "default:1,default:2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQueueWeights(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWeightedOrder(t *testing.T) {
+	critical := &Queue{KeyWithoutPrefix: "critical"}
+	normal := &Queue{KeyWithoutPrefix: "default"}
+	low := &Queue{KeyWithoutPrefix: "low"}
+	queues := []*Queue{critical, normal, low}
+	weights := []int{5, 2, 1}
+
+	// Always drawing the first slot picks queues in listed order
+	first := func(int) int { return 0 }
+	assert.Equal(t, []*Queue{critical, normal, low}, weightedOrder(queues, weights, first))
+
+	// Always drawing the last slot picks the last remaining queue first
+	last := func(n int) int { return n - 1 }
+	assert.Equal(t, []*Queue{low, normal, critical}, weightedOrder(queues, weights, last))
+
+	// Every queue appears exactly once whatever is drawn
+	middle := func(n int) int { return n / 2 }
+	assert.ElementsMatch(t, queues, weightedOrder(queues, weights, middle))
+}
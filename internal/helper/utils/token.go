@@ -28,3 +28,32 @@ func VerifyToken(tokenString string) (bool, error) {
 
 	return token.Valid, nil
 }
+
+// ParseToken verifies the token and returns its claims.
+func ParseToken(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// HasPermission reports whether the "permissions" claim grants the permission, "*" grants all of them.
+func HasPermission(claims jwt.MapClaims, permission string) bool {
+	permissions, ok := claims["permissions"].([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, p := range permissions {
+		if p == permission || p == "*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,9 +2,11 @@ package queue
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"net/http"
 	"webapi/internal/app/queue"
 	"webapi/internal/http/response"
+	"webapi/pkg/exception"
 )
 
 type QueueHTTPHandler struct {
@@ -27,3 +29,205 @@ func (h *QueueHTTPHandler) GetQueues(c *fiber.Ctx) error {
 		Data:            dtos,
 	})
 }
+
+func (h *QueueHTTPHandler) GetBatchByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return exception.InvalidIDError
+	}
+
+	dto, err := h.app.GetBatchByID(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            dto,
+	})
+}
+
+func (h *QueueHTTPHandler) GetJobByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return exception.InvalidIDError
+	}
+
+	dto, err := h.app.GetJobByID(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            dto,
+	})
+}
+
+func (h *QueueHTTPHandler) CancelJob(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return exception.InvalidIDError
+	}
+
+	dto, err := h.app.CancelJob(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            dto,
+	})
+}
+
+func (h *QueueHTTPHandler) GetQueueByKey(c *fiber.Ctx) error {
+	dto, err := h.app.GetQueueByKey(c.Context(), queue.GetQueueDTI{Key: c.Params("key")})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            dto,
+	})
+}
+
+func (h *QueueHTTPHandler) GetQueueJobs(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 10) // Default to 10 if not provided
+	page := c.QueryInt("page", 1)    // Default to 1 if not provided
+	state := c.Query("state", "pending")
+
+	dto, err := h.app.GetQueueJobs(c.Context(), queue.GetQueueJobsDTI{
+		Key:   c.Params("key"),
+		State: state,
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	lastPage := (dto.Total + limit - 1) / limit
+	return c.JSON(response.PaginationResponse{
+		TotalCount:   dto.Total,
+		TotalPage:    lastPage,
+		CurrentPage:  page,
+		LastPage:     lastPage,
+		PerPage:      limit,
+		NextPage:     page + 1,
+		PreviousPage: page - 1,
+		Data:         dto.Data,
+		Path:         c.Path(),
+	})
+}
+
+func (h *QueueHTTPHandler) RetryFailedJob(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return exception.InvalidIDError
+	}
+
+	if err := h.app.RetryFailedJob(c.Context(), queue.QueueJobDTI{Key: c.Params("key"), ID: id}); err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+	})
+}
+
+func (h *QueueHTTPHandler) RetryAllFailedJobs(c *fiber.Ctx) error {
+	count, err := h.app.RetryAllFailedJobs(c.Context(), queue.GetQueueDTI{Key: c.Params("key")})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            fiber.Map{"retried": count},
+	})
+}
+
+func (h *QueueHTTPHandler) ForgetJob(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return exception.InvalidIDError
+	}
+
+	if err := h.app.ForgetJob(c.Context(), queue.QueueJobDTI{Key: c.Params("key"), ID: id}); err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+	})
+}
+
+func (h *QueueHTTPHandler) ClearQueue(c *fiber.Ctx) error {
+	count, err := h.app.ClearQueue(c.Context(), queue.GetQueueDTI{Key: c.Params("key")})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            fiber.Map{"cleared": count},
+	})
+}
+
+func (h *QueueHTTPHandler) FlushFailedJobs(c *fiber.Ctx) error {
+	count, err := h.app.FlushFailedJobs(c.Context(), queue.GetQueueDTI{Key: c.Params("key")})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            fiber.Map{"flushed": count},
+	})
+}
+
+func (h *QueueHTTPHandler) RestoreQueue(c *fiber.Ctx) error {
+	count, err := h.app.RestoreQueue(c.Context(), queue.GetQueueDTI{Key: c.Params("key")})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            fiber.Map{"restored": count},
+	})
+}
+
+func (h *QueueHTTPHandler) PauseQueue(c *fiber.Ctx) error {
+	if err := h.app.PauseQueue(c.Context(), queue.GetQueueDTI{Key: c.Params("key")}); err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+	})
+}
+
+func (h *QueueHTTPHandler) ResumeQueue(c *fiber.Ctx) error {
+	if err := h.app.ResumeQueue(c.Context(), queue.GetQueueDTI{Key: c.Params("key")}); err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+	})
+}
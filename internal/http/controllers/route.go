@@ -2,14 +2,19 @@ package httpapi
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"webapi/internal/app/queue"
+	"webapi/internal/app/schedule"
 	"webapi/internal/app/user"
+	"webapi/internal/metrics"
 	"webapi/internal/repository"
+	"webapi/internal/router/middleware"
 
 	httpAuth "webapi/internal/http/controllers/auth"
 	httpHealthz "webapi/internal/http/controllers/healthz"
 	httpMiscellaneous "webapi/internal/http/controllers/miscellaneous"
 	httpQueue "webapi/internal/http/controllers/queue"
+	httpSchedule "webapi/internal/http/controllers/schedule"
 	httpUser "webapi/internal/http/controllers/user"
 )
 
@@ -28,6 +33,9 @@ func RegisterRoute(r *fiber.App) {
 	healthHandler := httpHealthz.NewHealthzHTTPHandler()
 	healthAPI.Get("/", healthHandler.Healthz)
 
+	// Prometheus metrics
+	r.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+
 	// User API
 	userAPI := v1.Group("/users")
 	userApp := user.NewUserApp(repo)
@@ -54,7 +62,39 @@ func RegisterRoute(r *fiber.App) {
 	queueApp := queue.NewQueueApp(repo)
 	queueHandler := httpQueue.NewQueueHTTPHandler(queueApp)
 	queueAPI.Get("/", queueHandler.GetQueues)
-	// queueAPI.Get("/:key", queueHandler.GetQueueByKey)
+
+	queueManageAPI := queueAPI.Group("/:key", middleware.RequirePermission("queue:manage"))
+	queueManageAPI.Get("/", queueHandler.GetQueueByKey)
+	queueManageAPI.Delete("/", queueHandler.ClearQueue)
+	queueManageAPI.Get("/jobs", queueHandler.GetQueueJobs)
+	queueManageAPI.Post("/retry", queueHandler.RetryAllFailedJobs)
+	queueManageAPI.Delete("/failed", queueHandler.FlushFailedJobs)
+	queueManageAPI.Post("/restore", queueHandler.RestoreQueue)
+	queueManageAPI.Post("/pause", queueHandler.PauseQueue)
+	queueManageAPI.Post("/resume", queueHandler.ResumeQueue)
+	queueManageAPI.Post("/jobs/:id/retry", queueHandler.RetryFailedJob)
+	queueManageAPI.Delete("/jobs/:id", queueHandler.ForgetJob)
+
+	// Job Batch API
+	batchAPI := v1.Group("/batches", middleware.RequirePermission("queue:manage"))
+	batchAPI.Get("/:id", queueHandler.GetBatchByID)
+
+	// Job API
+	jobAPI := v1.Group("/jobs", middleware.RequirePermission("queue:manage"))
+	jobAPI.Get("/:id", queueHandler.GetJobByID)
+	jobAPI.Post("/:id/cancel", queueHandler.CancelJob)
+
+	// Schedule API
+	scheduleAPI := v1.Group("/schedules", middleware.RequirePermission("schedule:manage"))
+	scheduleApp := schedule.NewScheduleApp(repo)
+	scheduleHandler := httpSchedule.NewScheduleHTTPHandler(scheduleApp)
+	scheduleAPI.Get("/runs", scheduleHandler.GetScheduleRuns)
+	scheduleAPI.Get("/", scheduleHandler.GetSchedules)
+	scheduleAPI.Post("/", scheduleHandler.CreateSchedule)
+	scheduleAPI.Get("/:name", scheduleHandler.GetSchedule)
+	scheduleAPI.Patch("/:name", scheduleHandler.UpdateSchedule)
+	scheduleAPI.Delete("/:name", scheduleHandler.DeleteSchedule)
+	scheduleAPI.Post("/:name/run", scheduleHandler.RunTask)
 
 	// Error Case Handler
 	miscellaneousHandler := httpMiscellaneous.NewMiscellaneousHTTPHandler()
@@ -0,0 +1,167 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"webapi/internal/app/schedule"
+	"webapi/internal/http/requests"
+	"webapi/internal/http/response"
+	"webapi/internal/http/validation"
+	"webapi/pkg/exception"
+)
+
+type ScheduleHTTPHandler struct {
+	app schedule.ScheduleApp
+}
+
+func NewScheduleHTTPHandler(app schedule.ScheduleApp) *ScheduleHTTPHandler {
+	return &ScheduleHTTPHandler{app: app}
+}
+
+func (h *ScheduleHTTPHandler) GetScheduleRuns(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 10) // Default to 10 if not provided
+	page := c.QueryInt("page", 1)    // Default to 1 if not provided
+	if limit < 1 {
+		limit = 10
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	dto, err := h.app.GetScheduleRuns(c.Context(), schedule.GetScheduleRunsDTI{
+		Task:  c.Query("task"),
+		Page:  page,
+		Limit: limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	lastPage := (dto.Total + limit - 1) / limit
+	return c.JSON(response.PaginationResponse{
+		TotalCount:   dto.Total,
+		TotalPage:    lastPage,
+		CurrentPage:  page,
+		LastPage:     lastPage,
+		PerPage:      limit,
+		NextPage:     page + 1,
+		PreviousPage: page - 1,
+		Data:         dto.Data,
+		Path:         c.Path(),
+	})
+}
+
+func (h *ScheduleHTTPHandler) RunTask(c *fiber.Ctx) error {
+	dto, err := h.app.RunTask(c.Context(), c.Params("name"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusAccepted).JSON(response.CommonResponse{
+		ResponseCode:    http.StatusAccepted,
+		ResponseMessage: "Accepted",
+		Data:            dto,
+	})
+}
+
+func (h *ScheduleHTTPHandler) GetSchedules(c *fiber.Ctx) error {
+	schedules, err := h.app.GetSchedules(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            schedules,
+	})
+}
+
+func (h *ScheduleHTTPHandler) GetSchedule(c *fiber.Ctx) error {
+	schedule, err := h.app.GetSchedule(c.Context(), c.Params("name"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            schedule,
+	})
+}
+
+func (h *ScheduleHTTPHandler) CreateSchedule(c *fiber.Ctx) error {
+	var req requests.CreateScheduleRequest
+
+	// Parse the request body
+	if err := c.BodyParser(&req); err != nil {
+		return exception.InvalidRequestBodyError
+	}
+
+	// Validate the request body
+	if err := validate(req); err != nil {
+		return err
+	}
+
+	schedule, err := h.app.CreateSchedule(c.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(response.CommonResponse{
+		ResponseCode:    http.StatusCreated,
+		ResponseMessage: "OK",
+		Data:            schedule,
+	})
+}
+
+func (h *ScheduleHTTPHandler) UpdateSchedule(c *fiber.Ctx) error {
+	var req requests.UpdateScheduleRequest
+
+	// Parse the request body
+	if err := c.BodyParser(&req); err != nil {
+		return exception.InvalidRequestBodyError
+	}
+
+	// Validate the request body
+	if err := validate(req); err != nil {
+		return err
+	}
+
+	schedule, err := h.app.UpdateSchedule(c.Context(), c.Params("name"), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+		Data:            schedule,
+	})
+}
+
+func (h *ScheduleHTTPHandler) DeleteSchedule(c *fiber.Ctx) error {
+	if err := h.app.DeleteSchedule(c.Context(), c.Params("name")); err != nil {
+		return err
+	}
+
+	return c.JSON(response.CommonResponse{
+		ResponseCode:    http.StatusOK,
+		ResponseMessage: "OK",
+	})
+}
+
+func validate(req any) error {
+	v, _ := validation.GetValidator()
+	if err := v.Struct(req); err != nil {
+		var validationErrs validator.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			return exception.NewValidationFailedErrors(validationErrs)
+		}
+	}
+
+	return nil
+}
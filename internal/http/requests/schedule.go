@@ -0,0 +1,27 @@
+package requests
+
+type CreateScheduleRequest struct {
+	Task      string `json:"task" validate:"required,max=255"`
+	Cron      string `json:"cron" validate:"required,max=255"`
+	Timezone  string `json:"timezone" validate:"max=64"`
+	Jitter    int    `json:"jitter" validate:"min=0"`
+	IsEnabled bool   `json:"is_enabled"`
+	Lock      bool   `json:"lock"`
+	LockTTL   int    `json:"lock_ttl" validate:"min=0"`
+
+	MisfirePolicy string `json:"misfire_policy" validate:"omitempty,oneof=skip run_once run_all"`
+	MisfireLimit  int    `json:"misfire_limit" validate:"min=0"`
+}
+
+// UpdateScheduleRequest changes the fields given, the others are left as they are.
+type UpdateScheduleRequest struct {
+	Cron      *string `json:"cron" validate:"omitempty,min=1,max=255"`
+	Timezone  *string `json:"timezone" validate:"omitempty,max=64"`
+	Jitter    *int    `json:"jitter" validate:"omitempty,min=0"`
+	IsEnabled *bool   `json:"is_enabled"`
+	Lock      *bool   `json:"lock"`
+	LockTTL   *int    `json:"lock_ttl" validate:"omitempty,min=0"`
+
+	MisfirePolicy *string `json:"misfire_policy" validate:"omitempty,oneof=skip run_once run_all"`
+	MisfireLimit  *int    `json:"misfire_limit" validate:"omitempty,min=0"`
+}
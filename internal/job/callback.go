@@ -0,0 +1,139 @@
+package job
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"webapi/config"
+	"webapi/pkg/transport"
+)
+
+const (
+	// CallbackHandlerName is the handler of the jobs that deliver job callbacks.
+	CallbackHandlerName = "JobCallback"
+
+	// SignatureHeader holds "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>", keyed with queue.callbacks.secret.
+	SignatureHeader = "X-Job-Signature"
+	// TimestampHeader holds the unix time the delivery was signed at, receivers should reject old deliveries.
+	TimestampHeader = "X-Job-Timestamp"
+
+	defaultCallbackMaxAttempts = 5
+	defaultCallbackRetryDelay  = 30 * time.Second
+	defaultCallbackTimeout     = 10 * time.Second
+)
+
+func init() {
+	Register(CallbackHandlerName, func() JobHandler { return new(JobCallback) })
+}
+
+// WithCallback makes the final state of the job, succeeded, failed or cancelled, be posted to url.
+// NewJob fails when url is not an absolute http or https URL or queue.callbacks.secret is empty.
+func WithCallback(url string) Option {
+	return func(j *Job) {
+		j.CallbackURL = url
+	}
+}
+
+// validateCallback returns an error when rawURL is not an absolute http or https URL, or when there is
+// no queue.callbacks.secret to sign the deliveries with.
+func validateCallback(rawURL string) error {
+	if config.GetConfig().Queue.Callbacks.Secret == "" {
+		return errors.New("queue.callbacks.secret must be set to send job callbacks")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback URL %q: %w", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback URL %q: must be an absolute http or https URL", rawURL)
+	}
+
+	return nil
+}
+
+// JobCallback posts the final event of a job to its callback URL. A delivery that does not get
+// a 2xx response fails, and is retried like any job.
+type JobCallback struct {
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+}
+
+// NewCallback creates the job delivering body to url, on the queue and with the attempts of queue.callbacks.
+func NewCallback(url string, body []byte) (*Job, error) {
+	if err := validateCallback(url); err != nil {
+		return nil, err
+	}
+
+	cfg := config.GetConfig().Queue.Callbacks
+
+	queue := cfg.Queue
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultCallbackMaxAttempts
+	}
+	retryDelay := time.Duration(cfg.RetryDelay) * time.Second
+	if retryDelay <= 0 {
+		retryDelay = defaultCallbackRetryDelay
+	}
+
+	return NewJob(CallbackHandlerName, &JobCallback{URL: url, Body: body}, maxAttempts, int(retryDelay.Seconds()), OnQueue(queue))
+}
+
+func (c *JobCallback) Handle() error {
+	return c.HandleContext(context.Background())
+}
+
+func (c *JobCallback) HandleContext(ctx context.Context) error {
+	cfg := config.GetConfig().Queue.Callbacks
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCallbackTimeout
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	res, err := transport.MakeHTTPRequest(ctx, transport.HttpRequest{
+		HttpClient: &http.Client{Timeout: timeout},
+		Url:        c.URL,
+		Method:     http.MethodPost,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			SignatureHeader: "sha256=" + Sign(cfg.Secret, timestamp, c.Body),
+			TimestampHeader: timestamp,
+		},
+		Body: c.Body,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("callback to %s answered %s", c.URL, res.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret, as sent in SignatureHeader.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"webapi/config"
+	"webapi/internal/logger"
+)
+
+func TestJobCallback(t *testing.T) {
+	config.SetConfig("../../config/config.testing.yaml")
+	logger.Log = zap.NewNop()
+
+	secret := config.GetConfig().Queue.Callbacks.Secret
+	body := []byte(`{"type":"succeeded"}`)
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "delivered", status: http.StatusNoContent},
+		{name: "rejected", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ := io.ReadAll(r.Body)
+				assert.Equal(t, body, received)
+				assert.Equal(t, "sha256="+Sign(secret, r.Header.Get(TimestampHeader), received), r.Header.Get(SignatureHeader))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			callback, err := NewCallback(server.URL, body)
+			require.NoError(t, err)
+			assert.Equal(t, CallbackHandlerName, callback.HandlerName)
+
+			var handler JobCallback
+			require.NoError(t, json.Unmarshal(callback.Payload, &handler))
+
+			err = handler.HandleContext(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateCallback(t *testing.T) {
+	config.SetConfig("../../config/config.testing.yaml")
+
+	tests := []struct {
+		name    string
+		url     string
+		secret  string
+		wantErr bool
+	}{
+		{name: "https", url: "https://example.com/callback", secret: "change-me"},
+		{name: "http", url: "http://127.0.0.1:8080/callback", secret: "change-me"},
+		{name: "relative", url: "/callback", secret: "change-me", wantErr: true},
+		{name: "other scheme", url: "ftp://example.com/callback", secret: "change-me", wantErr: true},
+		{name: "no host", url: "http:///callback", secret: "change-me", wantErr: true},
+		{name: "no secret", url: "https://example.com/callback", secret: "", wantErr: true},
+	}
+
+	callbacks := &config.GetConfig().Queue.Callbacks
+	secret := callbacks.Secret
+	t.Cleanup(func() { callbacks.Secret = secret })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callbacks.Secret = tt.secret
+
+			_, err := NewJob("ProcessExample", ProcessExample{Data: "callback"}, 1, 0, WithCallback(tt.url))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
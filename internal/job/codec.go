@@ -0,0 +1,347 @@
+package job
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"webapi/config"
+)
+
+const (
+	CompressionGzip = "gzip" // CompressionGzip compresses the payload with gzip.
+	CompressionZstd = "zstd" // CompressionZstd compresses the payload with zstd.
+
+	// CodecVersion is the version of the payload envelope, recorded with the steps applied in Job.Codec.
+	CodecVersion = "v1"
+
+	codecEncryption = "aes-gcm"
+)
+
+var (
+	// ErrUnknownKey is returned for a payload encrypted with a key id that is not configured.
+	ErrUnknownKey = errors.New("unknown payload encryption key")
+	// ErrUnknownCodec is returned for a payload stored with a codec this version cannot decode.
+	ErrUnknownCodec = errors.New("unknown payload codec")
+)
+
+// PayloadEncoding is how the payload of a handler is stored, in redis and in postgres.
+type PayloadEncoding struct {
+	Compression string // Compression is CompressionGzip, CompressionZstd or empty for none.
+	Encrypt     bool   // Encrypt encrypts the payload with the current key of queue.encryption.
+}
+
+// EncodedPayloadHandler is implemented by handlers whose payload holds personal data or large documents.
+// Their payload is compressed and/or encrypted when the job is created and decoded right before it runs,
+// anywhere else, such as the queue API, it stays opaque.
+type EncodedPayloadHandler interface {
+	PayloadEncoding() PayloadEncoding
+}
+
+// Keyring holds the payload encryption keys by id and the id of the key new payloads are encrypted with.
+type Keyring struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+// keyring returns the keys of queue.encryption, parsed on first use.
+var keyring = sync.OnceValues(func() (Keyring, error) {
+	cfg := config.GetConfig().Queue.Encryption
+
+	k := Keyring{Current: cfg.CurrentKey, Keys: make(map[string][]byte)}
+	for _, key := range cfg.Keys {
+		secret, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil {
+			return Keyring{}, fmt.Errorf("queue.encryption key %s: %w", key.ID, err)
+		}
+		if len(secret) != 32 {
+			return Keyring{}, fmt.Errorf("queue.encryption key %s: expected 32 bytes, got %d", key.ID, len(secret))
+		}
+		k.Keys[key.ID] = secret
+	}
+
+	return k, nil
+})
+
+// envelope is the stored form of an encoded payload. An encrypted payload is sealed with a random data key,
+// the data key is sealed with the key KeyID, so that rotating keys never requires re-encrypting payloads.
+type envelope struct {
+	KeyID   string `json:"kid,omitempty"`
+	DataKey []byte `json:"dek,omitempty"`
+	Nonce   []byte `json:"nonce,omitempty"`
+	Data    []byte `json:"data"`
+}
+
+// encodePayload compresses and encrypts the payload of the job, in that order, and records the codec.
+func (j *Job) encodePayload(encoding PayloadEncoding) error {
+	payload, steps, err := encode(encoding, j.Payload, j.ID[:])
+	if err != nil || len(steps) == 0 {
+		return err
+	}
+
+	j.Payload = payload
+	j.Codec = CodecVersion + ":" + strings.Join(steps, "+")
+	return nil
+}
+
+// DecodePayload returns the payload of the job as the handler's JSON, decrypting and decompressing it
+// according to its codec.
+func (j *Job) DecodePayload() (json.RawMessage, error) {
+	return decode(j.Codec, j.Payload, j.ID[:])
+}
+
+// EncodeResult encodes the result of the job with its codec, so that a result is kept like its payload.
+func (j *Job) EncodeResult(result json.RawMessage) (json.RawMessage, error) {
+	if j.Codec == "" {
+		return result, nil
+	}
+
+	version, stepList, _ := strings.Cut(j.Codec, ":")
+	if version != CodecVersion {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, j.Codec)
+	}
+
+	var encoding PayloadEncoding
+	for _, step := range strings.Split(stepList, "+") {
+		switch step {
+		case codecEncryption:
+			encoding.Encrypt = true
+		case CompressionGzip, CompressionZstd:
+			encoding.Compression = step
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, j.Codec)
+		}
+	}
+
+	encoded, _, err := encode(encoding, result, j.resultAdditionalData())
+	return encoded, err
+}
+
+// DecodeResult returns a result encoded by EncodeResult as the handler's JSON.
+func (j *Job) DecodeResult(result json.RawMessage) (json.RawMessage, error) {
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	return decode(j.Codec, result, j.resultAdditionalData())
+}
+
+// resultAdditionalData binds an encrypted result to the job, apart from its payload.
+func (j *Job) resultAdditionalData() []byte {
+	return append(j.ID[:], "result"...)
+}
+
+// encode compresses and encrypts data, in that order, into an envelope and returns the steps applied.
+// additionalData is authenticated with the encrypted data, so that it cannot be moved to another job.
+func encode(encoding PayloadEncoding, data []byte, additionalData []byte) ([]byte, []string, error) {
+	var steps []string
+	env := envelope{}
+
+	if encoding.Compression != "" {
+		compressed, err := compress(encoding.Compression, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = compressed
+		steps = append(steps, encoding.Compression)
+	}
+
+	if encoding.Encrypt {
+		k, err := keyring()
+		if err != nil {
+			return nil, nil, err
+		}
+		kek, ok := k.Keys[k.Current]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: current key %q", ErrUnknownKey, k.Current)
+		}
+
+		dek := make([]byte, 32)
+		if _, err := rand.Read(dek); err != nil {
+			return nil, nil, err
+		}
+
+		if env.DataKey, err = seal(kek, nil, dek, []byte(k.Current)); err != nil {
+			return nil, nil, err
+		}
+		env.Nonce = make([]byte, 12)
+		if _, err := rand.Read(env.Nonce); err != nil {
+			return nil, nil, err
+		}
+		if data, err = seal(dek, env.Nonce, data, additionalData); err != nil {
+			return nil, nil, err
+		}
+
+		env.KeyID = k.Current
+		steps = append(steps, codecEncryption)
+	}
+
+	if len(steps) == 0 {
+		return data, nil, nil
+	}
+
+	env.Data = data
+	encoded, err := json.Marshal(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encoded, steps, nil
+}
+
+// decode reverses encode for the steps recorded in codec.
+func decode(codec string, data []byte, additionalData []byte) ([]byte, error) {
+	if codec == "" {
+		return data, nil
+	}
+
+	version, stepList, _ := strings.Cut(codec, ":")
+	if version != CodecVersion {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, codec)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("error reading payload envelope: %w", err)
+	}
+
+	data = env.Data
+	steps := strings.Split(stepList, "+")
+	for i := len(steps) - 1; i >= 0; i-- {
+		var err error
+
+		switch steps[i] {
+		case codecEncryption:
+			data, err = openEnvelope(env, additionalData)
+		case CompressionGzip, CompressionZstd:
+			data, err = decompress(steps[i], data)
+		default:
+			err = fmt.Errorf("%w: %s", ErrUnknownCodec, codec)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func openEnvelope(env envelope, additionalData []byte) ([]byte, error) {
+	k, err := keyring()
+	if err != nil {
+		return nil, err
+	}
+	kek, ok := k.Keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, env.KeyID)
+	}
+
+	dek, err := open(kek, env.DataKey, []byte(env.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting the data key: %w", err)
+	}
+
+	data, err := open(dek, append(env.Nonce, env.Data...), additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting the payload: %w", err)
+	}
+
+	return data, nil
+}
+
+// seal encrypts plaintext with AES-GCM. When nonce is nil a random nonce is generated and prepended to the result.
+func seal(key []byte, nonce []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce != nil {
+		return aead.Seal(nil, nonce, plaintext, additionalData), nil
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext prefixed with its nonce.
+func open(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func compress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown payload compression %q", compression)
+	}
+}
+
+func decompress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(gz)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown payload compression %q", compression)
+	}
+}
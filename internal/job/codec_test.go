@@ -0,0 +1,129 @@
+package job
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encodedTestHandler struct {
+	Secret   string `json:"secret"`
+	encoding PayloadEncoding
+}
+
+func (h *encodedTestHandler) Handle() error {
+	return nil
+}
+
+func (h *encodedTestHandler) PayloadEncoding() PayloadEncoding {
+	return h.encoding
+}
+
+func useTestKeyring(t *testing.T, k Keyring) {
+	previous := keyring
+	keyring = func() (Keyring, error) { return k, nil }
+	t.Cleanup(func() { keyring = previous })
+}
+
+func TestPayloadCodec(t *testing.T) {
+	useTestKeyring(t, Keyring{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}})
+
+	tests := []struct {
+		name      string
+		encoding  PayloadEncoding
+		wantCodec string
+	}{
+		{name: "plain", encoding: PayloadEncoding{}, wantCodec: ""},
+		{name: "gzip", encoding: PayloadEncoding{Compression: CompressionGzip}, wantCodec: "v1:gzip"},
+		{name: "zstd", encoding: PayloadEncoding{Compression: CompressionZstd}, wantCodec: "v1:zstd"},
+		{name: "encrypted", encoding: PayloadEncoding{Encrypt: true}, wantCodec: "v1:aes-gcm"},
+		{name: "compressed and encrypted", encoding: PayloadEncoding{Compression: CompressionZstd, Encrypt: true}, wantCodec: "v1:zstd+aes-gcm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := NewJob("EncodedTest", &encodedTestHandler{Secret: "4111 1111 1111 1111", encoding: tt.encoding}, 1, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCodec, j.Codec)
+
+			if tt.wantCodec != "" {
+				assert.NotContains(t, string(j.Payload), "4111")
+			}
+
+			payload, err := j.DecodePayload()
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"secret":"4111 1111 1111 1111"}`, string(payload))
+		})
+	}
+}
+
+func TestPayloadCodecKeyRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	useTestKeyring(t, Keyring{Current: "old", Keys: map[string][]byte{"old": oldKey}})
+
+	j, err := NewJob("EncodedTest", &encodedTestHandler{Secret: "s", encoding: PayloadEncoding{Encrypt: true}}, 1, 0)
+	require.NoError(t, err)
+
+	// A payload encrypted with a retired key is still decoded while the key is configured
+	useTestKeyring(t, Keyring{Current: "new", Keys: map[string][]byte{"old": oldKey, "new": bytes.Repeat([]byte{2}, 32)}})
+	_, err = j.DecodePayload()
+	require.NoError(t, err)
+
+	useTestKeyring(t, Keyring{Current: "new", Keys: map[string][]byte{"new": bytes.Repeat([]byte{2}, 32)}})
+	_, err = j.DecodePayload()
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestPayloadCodecTampering(t *testing.T) {
+	useTestKeyring(t, Keyring{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}})
+
+	j, err := NewJob("EncodedTest", &encodedTestHandler{Secret: "s", encoding: PayloadEncoding{Encrypt: true}}, 1, 0)
+	require.NoError(t, err)
+
+	// The payload is bound to its job
+	j.ID = uuid.New()
+	_, err = j.DecodePayload()
+	assert.Error(t, err)
+
+	j.Codec = "v2:aes-gcm"
+	_, err = j.DecodePayload()
+	assert.ErrorIs(t, err, ErrUnknownCodec)
+}
+
+func TestResultCodec(t *testing.T) {
+	useTestKeyring(t, Keyring{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}})
+
+	tests := []struct {
+		name     string
+		encoding PayloadEncoding
+	}{
+		{name: "plain", encoding: PayloadEncoding{}},
+		{name: "compressed and encrypted", encoding: PayloadEncoding{Compression: CompressionGzip, Encrypt: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := NewJob("EncodedTest", &encodedTestHandler{Secret: "s", encoding: tt.encoding}, 1, 0)
+			require.NoError(t, err)
+
+			result, err := j.EncodeResult([]byte(`{"card":"4111 1111 1111 1111"}`))
+			require.NoError(t, err)
+			if tt.encoding.Encrypt {
+				assert.NotContains(t, string(result), "4111")
+			}
+
+			decoded, err := j.DecodeResult(result)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"card":"4111 1111 1111 1111"}`, string(decoded))
+
+			if tt.encoding.Encrypt {
+				// The result is not interchangeable with the payload
+				_, err = j.DecodeResult(j.Payload)
+				assert.Error(t, err)
+			}
+		})
+	}
+}
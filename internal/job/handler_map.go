@@ -2,8 +2,15 @@ package job
 
 type HandlerMap map[string]func() JobHandler
 
+// NewHandlerMap returns the handlers registered with Register, by name.
 func NewHandlerMap() HandlerMap {
-	return HandlerMap{
-		"ProcessExample": func() JobHandler { return new(ProcessExample) },
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	handlerMap := make(HandlerMap, len(factories))
+	for name, factory := range factories {
+		handlerMap[name] = factory
 	}
+
+	return handlerMap
 }
@@ -1,7 +1,9 @@
 package job
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,20 +13,98 @@ type JobHandler interface {
 	Handle() error
 }
 
+// ContextHandler is implemented by handlers that need the worker context,
+// for example to report progress. HandleContext is called instead of Handle.
+type ContextHandler interface {
+	HandleContext(ctx context.Context) error
+}
+
+// ResultHandler is implemented by handlers that return a result payload.
+// Result is called once the handler succeeded and is stored on the job record.
+type ResultHandler interface {
+	Result() any
+}
+
+// Run calls HandleContext when the handler implements ContextHandler, otherwise Handle.
+func Run(ctx context.Context, handler JobHandler) error {
+	if h, ok := handler.(ContextHandler); ok {
+		return h.HandleContext(ctx)
+	}
+
+	return handler.Handle()
+}
+
 // Job represents a job in the queue with a unique ID, queue name, payload, and creation timestamp.
 type Job struct {
 	ID          uuid.UUID       `json:"id"`
+	Queue       string          `json:"queue,omitempty"`
 	HandlerName string          `json:"handlerName"`
 	Payload     json.RawMessage `json:"payload"`
+	Codec       string          `json:"codec,omitempty"` // how the payload is encoded, empty for plain JSON
 	CreatedAt   time.Time       `json:"created_at"`
 	MaxAttempts int             `json:"max_attempts"`
 	Attempts    int             `json:"attempts"`
 	Delay       int             `json:"delay"` // in seconds
 	Errors      []string        `json:"errors"`
+	UniqueKey   string          `json:"unique_key,omitempty"`
+	UniqueFor   int             `json:"unique_for,omitempty"` // in seconds
+	UniqueUntil string          `json:"unique_until,omitempty"`
+	TTL         int             `json:"ttl,omitempty"` // in seconds, 0 means the job never expires
+	CallbackURL string          `json:"callback_url,omitempty"`
+	BatchID     *uuid.UUID      `json:"batch_id,omitempty"`
+	Chain       []*Job          `json:"chain,omitempty"`
+}
+
+const (
+	UniqueUntilProcessing = "processing" // UniqueUntilProcessing rejects duplicates while the original job is pending.
+	UniqueUntilCompleted  = "completed"  // UniqueUntilCompleted rejects duplicates while the original job is pending or running.
+
+	DefaultUniqueFor = time.Hour // DefaultUniqueFor is the lock window used when WithUnique is given no duration.
+)
+
+// Option configures optional behaviour of a Job.
+type Option func(*Job)
+
+// OnQueue sets the queue the job is dispatched to when it is part of a chain or a batch callback.
+func OnQueue(queue string) Option {
+	return func(j *Job) {
+		j.Queue = queue
+	}
+}
+
+// WithUnique makes enqueuing a duplicate job with the same key a no-op while the original
+// is pending (UniqueUntilProcessing) or pending and running (UniqueUntilCompleted).
+// The lock expires after lockFor even if the original job never finishes.
+func WithUnique(key string, lockFor time.Duration, until string) Option {
+	return func(j *Job) {
+		if lockFor <= 0 {
+			lockFor = DefaultUniqueFor
+		}
+		if until != UniqueUntilProcessing {
+			until = UniqueUntilCompleted
+		}
+
+		j.UniqueKey = key
+		j.UniqueFor = int(lockFor.Seconds())
+		j.UniqueUntil = until
+	}
+}
+
+// WithTTL makes the job expire ttl after it was created. An expired job is skipped instead of run
+// by the SkipExpired middleware.
+func WithTTL(ttl time.Duration) Option {
+	return func(j *Job) {
+		j.TTL = int(ttl.Seconds())
+	}
+}
+
+// IsExpired reports whether the job has a TTL that elapsed at the given time.
+func (j *Job) IsExpired(now time.Time) bool {
+	return j.TTL > 0 && now.After(j.CreatedAt.Add(time.Duration(j.TTL)*time.Second))
 }
 
 // NewJob creates a new Job with the given queue name and payload.
-func NewJob(handlerName string, payload any, maxAttempts int, delay int) (*Job, error) {
+func NewJob(handlerName string, payload any, maxAttempts int, delay int, opts ...Option) (*Job, error) {
 	jobID := uuid.New()
 	createdAt := time.Now()
 
@@ -33,7 +113,7 @@ func NewJob(handlerName string, payload any, maxAttempts int, delay int) (*Job,
 		return nil, err
 	}
 
-	return &Job{
+	j := &Job{
 		ID:          jobID,
 		HandlerName: handlerName,
 		Payload:     payloadBytes,
@@ -41,5 +121,30 @@ func NewJob(handlerName string, payload any, maxAttempts int, delay int) (*Job,
 		Attempts:    0,
 		Delay:       delay,
 		CreatedAt:   createdAt,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	if j.CallbackURL != "" {
+		if err := validateCallback(j.CallbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if h, ok := payload.(EncodedPayloadHandler); ok {
+		if err := j.encodePayload(h.PayloadEncoding()); err != nil {
+			return nil, fmt.Errorf("error encoding the payload of %s: %w", handlerName, err)
+		}
+	}
+
+	return j, nil
+}
+
+// Chain links jobs so that each one is dispatched only after the previous one succeeded.
+// It returns the first job, which carries the rest of the chain and is the one to enqueue.
+func Chain(first *Job, next ...*Job) *Job {
+	first.Chain = append(first.Chain, next...)
+	return first
 }
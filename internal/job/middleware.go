@@ -0,0 +1,161 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+	"webapi/internal/logger"
+	"webapi/internal/metrics"
+)
+
+// ErrJobExpired is returned by SkipExpired for a job whose TTL elapsed. The job is not retried.
+var ErrJobExpired = errors.New("job expired")
+
+// Next runs the rest of the middleware chain and then the handler.
+type Next func(ctx context.Context) error
+
+// Middleware wraps the execution of a job handler. It calls next to go on with the job,
+// or returns without calling it to stop the job. The error it returns is the outcome of the job.
+type Middleware func(ctx context.Context, j *Job, next Next) error
+
+// RunWithMiddleware runs the handler of a job through the middleware, the first middleware being the outermost.
+func RunWithMiddleware(ctx context.Context, j *Job, handler JobHandler, middleware []Middleware) error {
+	next := func(ctx context.Context) error {
+		return Run(ctx, handler)
+	}
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		mw, inner := middleware[i], next
+		next = func(ctx context.Context) error {
+			return mw(ctx, j, inner)
+		}
+	}
+
+	return next(ctx)
+}
+
+// Logging logs the start and the end of every job with its fields, the duration and the error.
+func Logging() Middleware {
+	return func(ctx context.Context, j *Job, next Next) error {
+		log := logger.Log.With(
+			zap.String("ID", j.ID.String()),
+			zap.String("queue", j.Queue),
+			zap.String("handler", j.HandlerName),
+			zap.Int("attempt", j.Attempts),
+			zap.Int("max_attempts", j.MaxAttempts),
+		)
+
+		log.Info("Processing job")
+		startedAt := time.Now()
+
+		err := next(ctx)
+		if errors.Is(err, ErrJobExpired) {
+			return err
+		}
+		if err != nil {
+			log.Error("Error handling job", zap.Duration("duration", time.Since(startedAt)), zap.Error(err))
+			return err
+		}
+
+		log.Info("Finished processing job", zap.Duration("duration", time.Since(startedAt)))
+		return nil
+	}
+}
+
+// Recover turns a panic of the rest of the chain into an error, so that the job is retried or failed like any job
+// whose handler returned an error instead of being left reserved.
+func Recover() Middleware {
+	return func(ctx context.Context, j *Job, next Next) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic occurred while processing job: %v", r)
+				logger.Log.Error("Recovered from panic", zap.String("ID", j.ID.String()), zap.Any("panic", r), zap.Stack("stack"))
+			}
+		}()
+
+		return next(ctx)
+	}
+}
+
+// Sentry reports the errors and panics of the handler to Sentry, tagged with the job ID, queue and handler.
+// The hub is set on the context so that the handler can add its own breadcrumbs.
+func Sentry() Middleware {
+	return func(ctx context.Context, j *Job, next Next) error {
+		hub := sentry.CurrentHub().Clone()
+		hub.ConfigureScope(func(scope *sentry.Scope) {
+			scope.SetTag("job_id", j.ID.String())
+			scope.SetTag("queue", j.Queue)
+			scope.SetTag("handler", j.HandlerName)
+			scope.SetExtra("attempt", j.Attempts)
+		})
+		ctx = sentry.SetHubOnContext(ctx, hub)
+
+		defer func() {
+			if r := recover(); r != nil {
+				hub.RecoverWithContext(ctx, r)
+				panic(r)
+			}
+		}()
+
+		err := next(ctx)
+		if err != nil && !errors.Is(err, ErrJobExpired) {
+			hub.CaptureException(err)
+		}
+
+		return err
+	}
+}
+
+// Tracing runs the handler in a Sentry transaction, so that spans started by the handler are attached to the job.
+func Tracing() Middleware {
+	return func(ctx context.Context, j *Job, next Next) error {
+		transaction := sentry.StartTransaction(ctx, "queue.process "+j.HandlerName, sentry.WithOpName("queue.process"))
+		transaction.SetTag("job_id", j.ID.String())
+		transaction.SetTag("queue", j.Queue)
+		transaction.SetTag("handler", j.HandlerName)
+		defer transaction.Finish()
+
+		err := next(transaction.Context())
+		if err != nil {
+			transaction.Status = sentry.SpanStatusInternalError
+		} else {
+			transaction.Status = sentry.SpanStatusOK
+		}
+
+		return err
+	}
+}
+
+// Timing records the duration of the handler in the queue_job_duration_seconds histogram.
+func Timing() Middleware {
+	return func(ctx context.Context, j *Job, next Next) error {
+		startedAt := time.Now()
+		err := next(ctx)
+
+		status := StatusCompleted
+		if errors.Is(err, ErrJobExpired) {
+			status = StatusExpired
+		} else if err != nil {
+			status = StatusFailed
+		}
+		metrics.JobDuration.WithLabelValues(j.Queue, j.HandlerName, status).Observe(time.Since(startedAt).Seconds())
+
+		return err
+	}
+}
+
+// SkipExpired stops a job whose TTL elapsed without running its handler, returning ErrJobExpired.
+func SkipExpired() Middleware {
+	return func(ctx context.Context, j *Job, next Next) error {
+		if j.IsExpired(time.Now()) {
+			logger.Log.Info("Skipping expired job", zap.String("ID", j.ID.String()), zap.String("handler", j.HandlerName), zap.Int("ttl", j.TTL))
+			return ErrJobExpired
+		}
+
+		return next(ctx)
+	}
+}
@@ -0,0 +1,14 @@
+package job
+
+// NewMiddleware returns the middleware every job handler runs through, the first one being the outermost.
+// Add your own middleware to the list.
+func NewMiddleware() []Middleware {
+	return []Middleware{
+		Logging(),
+		Recover(),
+		Sentry(),
+		Tracing(),
+		Timing(),
+		SkipExpired(),
+	}
+}
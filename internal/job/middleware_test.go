@@ -0,0 +1,85 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"webapi/internal/logger"
+)
+
+type recordingHandler struct {
+	calls *[]string
+	err   error
+}
+
+func (h recordingHandler) Handle() error {
+	*h.calls = append(*h.calls, "handler")
+	return h.err
+}
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(ctx context.Context, j *Job, next Next) error {
+		*calls = append(*calls, name+" before")
+		err := next(ctx)
+		*calls = append(*calls, name+" after")
+		return err
+	}
+}
+
+func TestRunWithMiddleware(t *testing.T) {
+	var calls []string
+	handlerErr := errors.New("handler failed")
+
+	err := RunWithMiddleware(context.Background(), &Job{}, recordingHandler{calls: &calls, err: handlerErr}, []Middleware{
+		recordingMiddleware("outer", &calls),
+		recordingMiddleware("inner", &calls),
+	})
+
+	assert.ErrorIs(t, err, handlerErr)
+	assert.Equal(t, []string{"outer before", "inner before", "handler", "inner after", "outer after"}, calls)
+}
+
+func TestSkipExpired(t *testing.T) {
+	logger.Log = zap.NewNop()
+
+	tests := []struct {
+		name    string
+		job     Job
+		wantRun bool
+	}{
+		{name: "no ttl", job: Job{CreatedAt: time.Now().Add(-time.Hour)}, wantRun: true},
+		{name: "ttl not elapsed", job: Job{CreatedAt: time.Now(), TTL: 60}, wantRun: true},
+		{name: "ttl elapsed", job: Job{CreatedAt: time.Now().Add(-time.Hour), TTL: 60}, wantRun: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			err := RunWithMiddleware(context.Background(), &tt.job, recordingHandler{calls: &calls}, []Middleware{SkipExpired()})
+
+			if tt.wantRun {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrJobExpired)
+			}
+			assert.Equal(t, tt.wantRun, len(calls) == 1)
+		})
+	}
+}
+
+type panickingHandler struct{}
+
+func (panickingHandler) Handle() error {
+	panic("boom")
+}
+
+func TestRecover(t *testing.T) {
+	logger.Log = zap.NewNop()
+
+	err := RunWithMiddleware(context.Background(), &Job{}, panickingHandler{}, []Middleware{Recover()})
+	assert.ErrorContains(t, err, "boom")
+}
@@ -4,6 +4,10 @@ import (
 	"time"
 )
 
+func init() {
+	Register("ProcessExample", func() JobHandler { return new(ProcessExample) })
+}
+
 type ProcessExample struct {
 	Data string `json:"data"`
 }
@@ -0,0 +1,26 @@
+package job
+
+import "context"
+
+// ProgressReporter stores the progress of the running job.
+type ProgressReporter func(ctx context.Context, percent int, message string) error
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a context that reports the progress of a running job to reporter.
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// ReportProgress reports the progress percentage (0-100) and a message for the running job.
+// It does nothing when the context doesn't belong to a running job.
+func ReportProgress(ctx context.Context, percent int, message string) error {
+	reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok {
+		return nil
+	}
+
+	percent = max(0, min(percent, 100))
+
+	return reporter(ctx, percent, message)
+}
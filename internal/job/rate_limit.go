@@ -0,0 +1,19 @@
+package job
+
+import "time"
+
+// RateLimit holds the limits a handler runs within, shared by every worker.
+// A zero Limit or Concurrency disables that limit.
+type RateLimit struct {
+	Key         string        // Key groups handlers that share a quota, defaults to the handler name.
+	Limit       int           // Limit is the number of jobs allowed to start per Period.
+	Period      time.Duration // Period is the window of the rate limit, defaults to one second.
+	Concurrency int           // Concurrency is the number of jobs allowed to run at once.
+	Lease       time.Duration // Lease frees a concurrency slot of a worker that died, defaults to 15 minutes.
+}
+
+// RateLimitedHandler is implemented by handlers that call rate limited services.
+// A job over its limit is released back to the queue with a short delay instead of failing.
+type RateLimitedHandler interface {
+	RateLimit() RateLimit
+}
@@ -0,0 +1,113 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// DefaultQueue is the queue Dispatch enqueues to when the job is not given one with OnQueue.
+const DefaultQueue = "default"
+
+// ErrHandlerNotRegistered is returned for a job type or handler name that was never registered.
+var ErrHandlerNotRegistered = errors.New("job handler not registered")
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]func() JobHandler)
+	names      = make(map[reflect.Type]string)
+
+	// dispatcher enqueues jobs for Dispatch, it is set by the queue package.
+	dispatcher func(ctx context.Context, queue string, j *Job) error
+)
+
+// Register makes a job handler available to the workers under the given name.
+// It is meant to be called from the init function of the file declaring the handler
+// and panics when the name or the handler type is already registered.
+func Register(name string, factory func() JobHandler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		panic("job: Register called with an empty handler name")
+	}
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("job: handler %s is registered twice", name))
+	}
+
+	handlerType := reflect.TypeOf(factory())
+	if existing, ok := names[handlerType]; ok {
+		panic(fmt.Sprintf("job: handler type %s is already registered as %s", handlerType, existing))
+	}
+
+	factories[name] = factory
+	names[handlerType] = name
+}
+
+// IsRegistered reports whether a handler is registered under the given name.
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := factories[name]
+	return ok
+}
+
+// NameOf returns the name the type of the handler is registered under.
+func NameOf(handler JobHandler) (string, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	name, ok := names[reflect.TypeOf(handler)]
+	if !ok {
+		return "", fmt.Errorf("%w: %T", ErrHandlerNotRegistered, handler)
+	}
+
+	return name, nil
+}
+
+// New creates a job for the registered handler of type T, with the handler as payload.
+func New[T JobHandler](payload T, maxAttempts int, delay int, opts ...Option) (*Job, error) {
+	name, err := NameOf(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJob(name, payload, maxAttempts, delay, opts...)
+}
+
+// Dispatch creates a job for the registered handler of type T and enqueues it,
+// on the queue given with OnQueue or on DefaultQueue.
+func Dispatch[T JobHandler](ctx context.Context, payload T, maxAttempts int, delay int, opts ...Option) (*Job, error) {
+	j, err := New(payload, maxAttempts, delay, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.RLock()
+	dispatch := dispatcher
+	registryMu.RUnlock()
+	if dispatch == nil {
+		return nil, errors.New("job: no dispatcher set, import the queue package")
+	}
+
+	queue := j.Queue
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	if err := dispatch(ctx, queue, j); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// SetDispatcher sets the function Dispatch enqueues jobs with.
+func SetDispatcher(dispatch func(ctx context.Context, queue string, j *Job) error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	dispatcher = dispatch
+}
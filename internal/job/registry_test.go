@@ -0,0 +1,61 @@
+package job
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type registryTestHandler struct {
+	Name string `json:"name"`
+}
+
+func (h *registryTestHandler) Handle() error {
+	return nil
+}
+
+type unregisteredTestHandler struct{}
+
+func (h *unregisteredTestHandler) Handle() error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("RegistryTest", func() JobHandler { return new(registryTestHandler) })
+
+	assert.True(t, IsRegistered("RegistryTest"))
+	assert.Contains(t, NewHandlerMap(), "RegistryTest")
+
+	// The same name or the same handler type cannot be registered twice
+	assert.Panics(t, func() {
+		Register("RegistryTest", func() JobHandler { return new(unregisteredTestHandler) })
+	})
+	assert.Panics(t, func() {
+		Register("RegistryTestAgain", func() JobHandler { return new(registryTestHandler) })
+	})
+
+	j, err := New(&registryTestHandler{Name: "typed"}, 3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "RegistryTest", j.HandlerName)
+	assert.JSONEq(t, `{"name":"typed"}`, string(j.Payload))
+
+	_, err = New(&unregisteredTestHandler{}, 3, 0)
+	assert.ErrorIs(t, err, ErrHandlerNotRegistered)
+
+	var dispatchedTo string
+	SetDispatcher(func(_ context.Context, queue string, _ *Job) error {
+		dispatchedTo = queue
+		return nil
+	})
+	t.Cleanup(func() { SetDispatcher(nil) })
+
+	_, err = Dispatch(context.Background(), &registryTestHandler{}, 3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultQueue, dispatchedTo)
+
+	_, err = Dispatch(context.Background(), &registryTestHandler{}, 3, 0, OnQueue("emails"))
+	require.NoError(t, err)
+	assert.Equal(t, "emails", dispatchedTo)
+}
@@ -5,4 +5,6 @@ const (
 	StatusProcessing = "processing" // StatusProcessing is the status of a job that is currently being processed.
 	StatusCompleted  = "completed"  // StatusCompleted is the status of a job that has been successfully processed.
 	StatusFailed     = "failed"     // StatusFailed is the status of a job that has failed to be processed.
+	StatusCancelled  = "cancelled"  // StatusCancelled is the status of a job that was cancelled before it finished.
+	StatusExpired    = "expired"    // StatusExpired is the status of a job whose TTL elapsed before it was processed.
 )
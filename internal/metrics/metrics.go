@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	WorkerBusy = "busy"
+	WorkerIdle = "idle"
+)
+
+// Queue metrics. The job metrics are labeled by queue and handler name.
+var (
+	JobsEnqueued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_jobs_enqueued_total",
+		Help: "Number of jobs added to a queue.",
+	}, []string{"queue", "handler"})
+
+	JobsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_jobs_processed_total",
+		Help: "Number of jobs handled successfully.",
+	}, []string{"queue", "handler"})
+
+	JobsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_jobs_failed_total",
+		Help: "Number of jobs moved to the failed jobs after their last attempt.",
+	}, []string{"queue", "handler"})
+
+	JobsRetried = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_jobs_retried_total",
+		Help: "Number of jobs put back to the queue after a failed attempt or retried from the failed jobs.",
+	}, []string{"queue", "handler"})
+
+	JobsCancelled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_jobs_cancelled_total",
+		Help: "Number of jobs cancelled before they finished.",
+	}, []string{"queue", "handler"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "queue_job_duration_seconds",
+		Help:    "Time spent running job handlers, by outcome.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"queue", "handler", "status"})
+
+	Workers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queue_workers",
+		Help: "Number of queue workers of this process, busy running a job or idle.",
+	}, []string{"state"})
+)
+
+// Handler serves the metrics of the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve serves the metrics on /metrics of the given port until the context is canceled.
+func Serve(ctx context.Context, port int) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -14,11 +14,36 @@ type JobRepository interface {
 	AddJob(ctx context.Context, job model.Job) (jobID uuid.UUID, err error)
 	AddFailedJob(ctx context.Context, job model.FailedJob) (failedJobID int, err error)
 	UpdateJobStatus(ctx context.Context, jobID uuid.UUID, status string) error
+	MarkJobProcessing(ctx context.Context, jobID uuid.UUID, attempts int) error
+	ReleaseJob(ctx context.Context, jobID uuid.UUID, attempts int) error
+	CancelJob(ctx context.Context, jobID uuid.UUID, fromStatuses ...string) (bool, error)
+	UpdateJobErrors(ctx context.Context, jobID uuid.UUID, errors []string) error
+	UpdateJobProgress(ctx context.Context, jobID uuid.UUID, progress int, message string) error
+	UpdateJobResult(ctx context.Context, jobID uuid.UUID, result []byte) error
+	GetJobByID(ctx context.Context, jobID uuid.UUID) (model.Job, error)
 	ResetProcessingJobsToPending(ctx context.Context) error
 	GetJobs(ctx context.Context) ([]model.Job, error)
 	GetUnfinishedJobs(ctx context.Context) ([]model.Job, error)
+	GetUnfinishedJobsByQueue(ctx context.Context, queue string) ([]model.Job, error)
+	GetUnfinishedHandlerNames(ctx context.Context, queues []string) ([]string, error)
 	GetFailedJobs(ctx context.Context) ([]model.FailedJob, error)
 	RemoveFailedJob(ctx context.Context, jobID uuid.UUID) error
+
+	ReserveNextJob(ctx context.Context, queue string, staleBefore time.Time) (model.Job, error)
+	FinishReservedJob(ctx context.Context, jobID uuid.UUID, status string) error
+	RequeueJob(ctx context.Context, jobID uuid.UUID, attempts int, availableAt time.Time) error
+	RequeueFailedJob(ctx context.Context, queue string, jobID uuid.UUID) (model.Job, error)
+	GetQueueJob(ctx context.Context, queue string, state string, jobID uuid.UUID) (model.Job, error)
+	GetQueueJobs(ctx context.Context, queue string, state string, offset int64, limit int64) ([]model.Job, int64, error)
+	CountQueueJobs(ctx context.Context, queue string) (map[string]int64, error)
+	DeleteQueueJob(ctx context.Context, queue string, state string, jobID uuid.UUID) (model.Job, error)
+	DeleteQueueJobs(ctx context.Context, queue string, state string) (int64, error)
+
+	GetQueueActivity(ctx context.Context, since time.Time) (map[string]model.QueueActivity, error)
+	GetLatestFailedJobs(ctx context.Context, queues []string, limit int) ([]model.FailedJob, error)
+
+	GetPrunableJobs(ctx context.Context, statuses []string, before time.Time, limit int) ([]model.Job, error)
+	PruneJobs(ctx context.Context, ids []uuid.UUID, statuses []string, before time.Time) (int64, error)
 }
 
 type JobRepositoryImpl struct {
@@ -31,6 +56,8 @@ func NewJobRepository(pgxPool *pgxpool.Pool) JobRepository {
 	}
 }
 
+const jobColumns = `id, queue, handler_name, payload, max_attempts, delay, status, batch_id, chain, attempts, errors, progress, progress_message, result, unique_key, unique_for, unique_until, ttl, codec, callback_url, created_at, updated_at`
+
 func (j *JobRepositoryImpl) AddJob(ctx context.Context, job model.Job) (jobID uuid.UUID, err error) {
 	tx, err := j.pgxPool.Begin(ctx)
 	if err != nil {
@@ -39,10 +66,11 @@ func (j *JobRepositoryImpl) AddJob(ctx context.Context, job model.Job) (jobID uu
 	defer tx.Rollback(ctx)
 
 	err = tx.QueryRow(ctx, `
-		INSERT INTO jobs (id, queue, handler_name, payload, max_attempts, delay, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO jobs (id, queue, handler_name, payload, max_attempts, delay, status, batch_id, chain, unique_key, unique_for, unique_until, ttl, codec, callback_url, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		RETURNING id
-	`, job.ID, job.Queue, job.HandlerName, job.Payload, job.MaxAttempts, job.Delay, job.Status, job.CreatedAt, job.UpdatedAt).Scan(&jobID)
+	`, job.ID, job.Queue, job.HandlerName, job.Payload, job.MaxAttempts, job.Delay, job.Status, job.BatchID, job.Chain,
+		job.UniqueKey, job.UniqueFor, job.UniqueUntil, job.TTL, job.Codec, job.CallbackURL, job.CreatedAt, job.UpdatedAt).Scan(&jobID)
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -101,6 +129,59 @@ func (j *JobRepositoryImpl) UpdateJobStatus(ctx context.Context, jobID uuid.UUID
 	return nil
 }
 
+func (j *JobRepositoryImpl) MarkJobProcessing(ctx context.Context, jobID uuid.UUID, attempts int) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET status = 'processing', attempts = $1, updated_at = $2 WHERE id = $3 AND status <> 'cancelled'
+	`, attempts, time.Now(), jobID)
+
+	return err
+}
+
+// ReleaseJob sets a claimed job back to pending without counting the attempt.
+func (j *JobRepositoryImpl) ReleaseJob(ctx context.Context, jobID uuid.UUID, attempts int) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET status = 'pending', attempts = $1, updated_at = $2 WHERE id = $3
+	`, attempts, time.Now(), jobID)
+
+	return err
+}
+
+// CancelJob sets the job to cancelled when it is in one of the given statuses and reports whether it did.
+func (j *JobRepositoryImpl) CancelJob(ctx context.Context, jobID uuid.UUID, fromStatuses ...string) (bool, error) {
+	tag, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET status = 'cancelled', reserved_at = NULL, updated_at = $1 WHERE id = $2 AND status = ANY($3)
+	`, time.Now(), jobID, fromStatuses)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (j *JobRepositoryImpl) UpdateJobErrors(ctx context.Context, jobID uuid.UUID, errors []string) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET errors = $1, updated_at = $2 WHERE id = $3
+	`, errors, time.Now(), jobID)
+
+	return err
+}
+
+func (j *JobRepositoryImpl) UpdateJobProgress(ctx context.Context, jobID uuid.UUID, progress int, message string) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET progress = $1, progress_message = $2, updated_at = $3 WHERE id = $4
+	`, progress, message, time.Now(), jobID)
+
+	return err
+}
+
+func (j *JobRepositoryImpl) UpdateJobResult(ctx context.Context, jobID uuid.UUID, result []byte) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET result = $1, updated_at = $2 WHERE id = $3
+	`, result, time.Now(), jobID)
+
+	return err
+}
+
 func (j *JobRepositoryImpl) ResetProcessingJobsToPending(ctx context.Context) error {
 	tx, err := j.pgxPool.Begin(ctx)
 	if err != nil {
@@ -127,7 +208,7 @@ func handleSelectJob(rows pgx.Rows) ([]model.Job, error) {
 	var jobs []model.Job
 	for rows.Next() {
 		var job model.Job
-		err := rows.Scan(&job.ID, &job.Queue, &job.HandlerName, &job.Payload, &job.MaxAttempts, &job.Delay, &job.Status, &job.CreatedAt, &job.UpdatedAt)
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -137,9 +218,23 @@ func handleSelectJob(rows pgx.Rows) ([]model.Job, error) {
 	return jobs, rows.Err()
 }
 
+func scanJob(row pgx.Row) (model.Job, error) {
+	var job model.Job
+	err := row.Scan(&job.ID, &job.Queue, &job.HandlerName, &job.Payload, &job.MaxAttempts, &job.Delay, &job.Status, &job.BatchID, &job.Chain,
+		&job.Attempts, &job.Errors, &job.Progress, &job.ProgressMessage, &job.Result, &job.UniqueKey, &job.UniqueFor, &job.UniqueUntil, &job.TTL, &job.Codec, &job.CallbackURL, &job.CreatedAt, &job.UpdatedAt)
+
+	return job, err
+}
+
+func (j *JobRepositoryImpl) GetJobByID(ctx context.Context, jobID uuid.UUID) (model.Job, error) {
+	row := j.pgxPool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, jobID)
+
+	return scanJob(row)
+}
+
 func (j *JobRepositoryImpl) GetJobs(ctx context.Context) ([]model.Job, error) {
 	rows, err := j.pgxPool.Query(ctx, `
-		SELECT id, queue, handler_name, payload, max_attempts, delay, status, created_at, updated_at FROM jobs
+		SELECT `+jobColumns+` FROM jobs
 	`)
 	if err != nil {
 		return nil, err
@@ -153,7 +248,7 @@ func (j *JobRepositoryImpl) GetJobs(ctx context.Context) ([]model.Job, error) {
 
 func (j *JobRepositoryImpl) GetUnfinishedJobs(ctx context.Context) ([]model.Job, error) {
 	rows, err := j.pgxPool.Query(ctx, `
-		SELECT id, queue, handler_name, payload, max_attempts, delay, status, created_at, updated_at FROM jobs WHERE status != 'completed' ORDER BY created_at ASC
+		SELECT `+jobColumns+` FROM jobs WHERE status != 'completed' ORDER BY created_at ASC
 	`)
 	if err != nil {
 		return nil, err
@@ -165,6 +260,42 @@ func (j *JobRepositoryImpl) GetUnfinishedJobs(ctx context.Context) ([]model.Job,
 	return jobs, err
 }
 
+func (j *JobRepositoryImpl) GetUnfinishedJobsByQueue(ctx context.Context, queue string) ([]model.Job, error) {
+	rows, err := j.pgxPool.Query(ctx, `
+		SELECT `+jobColumns+` FROM jobs WHERE queue = $1 AND status IN ('pending', 'processing', 'failed') ORDER BY created_at ASC
+	`, queue)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs, err := handleSelectJob(rows)
+
+	return jobs, err
+}
+
+// GetUnfinishedHandlerNames returns the distinct handler names of the pending and processing jobs of the given queues.
+func (j *JobRepositoryImpl) GetUnfinishedHandlerNames(ctx context.Context, queues []string) ([]string, error) {
+	rows, err := j.pgxPool.Query(ctx, `
+		SELECT DISTINCT handler_name FROM jobs WHERE queue = ANY($1) AND status IN ('pending', 'processing') ORDER BY handler_name
+	`, queues)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
 func (j *JobRepositoryImpl) GetFailedJobs(ctx context.Context) ([]model.FailedJob, error) {
 	rows, err := j.pgxPool.Query(ctx, `
 		SELECT id, job_id, queue, payload, error, failed_at FROM failed_jobs ORDER BY failed_at ASC
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"webapi/internal/db/model"
+)
+
+type JobBatchRepository interface {
+	AddBatch(ctx context.Context, batch model.JobBatch) (batchID uuid.UUID, err error)
+	GetBatchByID(ctx context.Context, batchID uuid.UUID) (model.JobBatch, error)
+	RecordJobSucceeded(ctx context.Context, batchID uuid.UUID) (model.JobBatch, error)
+	RecordJobFailed(ctx context.Context, batchID uuid.UUID) (model.JobBatch, error)
+	RemoveBatchJobs(ctx context.Context, batchID uuid.UUID, count int) (model.JobBatch, error)
+}
+
+type JobBatchRepositoryImpl struct {
+	pgxPool *pgxpool.Pool
+}
+
+func NewJobBatchRepository(pgxPool *pgxpool.Pool) JobBatchRepository {
+	return &JobBatchRepositoryImpl{
+		pgxPool: pgxPool,
+	}
+}
+
+const jobBatchColumns = `id, name, queue, total_jobs, pending_jobs, failed_jobs, allow_failures, then_job, catch_job, finally_job, created_at, cancelled_at, finished_at`
+
+func scanJobBatch(row interface{ Scan(dest ...any) error }) (model.JobBatch, error) {
+	var batch model.JobBatch
+	err := row.Scan(&batch.ID, &batch.Name, &batch.Queue, &batch.TotalJobs, &batch.PendingJobs, &batch.FailedJobs, &batch.AllowFailures,
+		&batch.ThenJob, &batch.CatchJob, &batch.FinallyJob, &batch.CreatedAt, &batch.CancelledAt, &batch.FinishedAt)
+
+	return batch, err
+}
+
+func (j *JobBatchRepositoryImpl) AddBatch(ctx context.Context, batch model.JobBatch) (batchID uuid.UUID, err error) {
+	err = j.pgxPool.QueryRow(ctx, `
+		INSERT INTO job_batches (id, name, queue, total_jobs, pending_jobs, failed_jobs, allow_failures, then_job, catch_job, finally_job, created_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, batch.ID, batch.Name, batch.Queue, batch.TotalJobs, batch.TotalJobs, batch.AllowFailures, batch.ThenJob, batch.CatchJob, batch.FinallyJob, batch.CreatedAt).Scan(&batchID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return batchID, nil
+}
+
+func (j *JobBatchRepositoryImpl) GetBatchByID(ctx context.Context, batchID uuid.UUID) (model.JobBatch, error) {
+	row := j.pgxPool.QueryRow(ctx, `SELECT `+jobBatchColumns+` FROM job_batches WHERE id = $1`, batchID)
+
+	return scanJobBatch(row)
+}
+
+// RecordJobSucceeded decrements the pending jobs of the batch and marks it finished when none are left.
+// The update is atomic, so exactly one caller sees pending_jobs reach zero.
+func (j *JobBatchRepositoryImpl) RecordJobSucceeded(ctx context.Context, batchID uuid.UUID) (model.JobBatch, error) {
+	row := j.pgxPool.QueryRow(ctx, `
+		UPDATE job_batches SET
+			pending_jobs = pending_jobs - 1,
+			finished_at = CASE WHEN pending_jobs - 1 <= 0 THEN NOW() ELSE finished_at END
+		WHERE id = $1
+		RETURNING `+jobBatchColumns, batchID)
+
+	return scanJobBatch(row)
+}
+
+// RecordJobFailed decrements the pending jobs and increments the failed jobs of the batch.
+// A batch that does not allow failures is cancelled on its first failure.
+func (j *JobBatchRepositoryImpl) RecordJobFailed(ctx context.Context, batchID uuid.UUID) (model.JobBatch, error) {
+	row := j.pgxPool.QueryRow(ctx, `
+		UPDATE job_batches SET
+			pending_jobs = pending_jobs - 1,
+			failed_jobs = failed_jobs + 1,
+			cancelled_at = CASE WHEN NOT allow_failures AND cancelled_at IS NULL THEN NOW() ELSE cancelled_at END,
+			finished_at = CASE WHEN pending_jobs - 1 <= 0 THEN NOW() ELSE finished_at END
+		WHERE id = $1
+		RETURNING `+jobBatchColumns, batchID)
+
+	return scanJobBatch(row)
+}
+
+// RemoveBatchJobs removes jobs that could not be dispatched from the total and pending jobs of the batch,
+// and marks it finished when none are left.
+func (j *JobBatchRepositoryImpl) RemoveBatchJobs(ctx context.Context, batchID uuid.UUID, count int) (model.JobBatch, error) {
+	row := j.pgxPool.QueryRow(ctx, `
+		UPDATE job_batches SET
+			total_jobs = total_jobs - $2,
+			pending_jobs = pending_jobs - $2,
+			finished_at = CASE WHEN pending_jobs - $2 <= 0 THEN NOW() ELSE finished_at END
+		WHERE id = $1
+		RETURNING `+jobBatchColumns, batchID, count)
+
+	return scanJobBatch(row)
+}
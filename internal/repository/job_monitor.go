@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"webapi/internal/db/model"
+)
+
+// GetQueueActivity returns, for each queue, the number of jobs enqueued, completed and failed since the given time.
+func (j *JobRepositoryImpl) GetQueueActivity(ctx context.Context, since time.Time) (map[string]model.QueueActivity, error) {
+	rows, err := j.pgxPool.Query(ctx, `
+		SELECT queue,
+			COUNT(*) FILTER (WHERE created_at >= $1),
+			COUNT(*) FILTER (WHERE status = 'completed' AND updated_at >= $1),
+			COUNT(*) FILTER (WHERE status = 'failed' AND updated_at >= $1)
+		FROM jobs
+		WHERE created_at >= $1 OR updated_at >= $1
+		GROUP BY queue
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := make(map[string]model.QueueActivity)
+	for rows.Next() {
+		var a model.QueueActivity
+		if err := rows.Scan(&a.Queue, &a.Enqueued, &a.Completed, &a.Failed); err != nil {
+			return nil, err
+		}
+		activity[a.Queue] = a
+	}
+
+	return activity, rows.Err()
+}
+
+// GetLatestFailedJobs returns the most recent failed jobs of the given queues, newest first.
+// An empty list of queues returns the failed jobs of every queue.
+func (j *JobRepositoryImpl) GetLatestFailedJobs(ctx context.Context, queues []string, limit int) ([]model.FailedJob, error) {
+	if queues == nil {
+		// A nil slice is sent as NULL, which matches nothing
+		queues = []string{}
+	}
+
+	rows, err := j.pgxPool.Query(ctx, `
+		SELECT id, job_id, queue, payload, error, failed_at FROM failed_jobs
+		WHERE cardinality($1::text[]) = 0 OR queue = ANY($1)
+		ORDER BY failed_at DESC
+		LIMIT $2
+	`, queues, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []model.FailedJob
+	for rows.Next() {
+		var job model.FailedJob
+		if err := rows.Scan(&job.ID, &job.JobID, &job.Queue, &job.Payload, &job.Error, &job.FailedAt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"webapi/internal/db/model"
+)
+
+// JobOutboxChannel is the postgres channel notified when jobs are added to the outbox, once their transaction commits.
+const JobOutboxChannel = "job_outbox"
+
+type JobOutboxRepository interface {
+	AddOutboxJobs(ctx context.Context, tx pgx.Tx, jobs ...model.OutboxJob) error
+	RelayOutboxJobs(ctx context.Context, limit int, maxAttempts int, publish func(model.OutboxJob) error) (int, error)
+	CountOutboxJobs(ctx context.Context) (int64, error)
+	WaitForOutboxJobs(ctx context.Context) error
+}
+
+type JobOutboxRepositoryImpl struct {
+	pgxPool *pgxpool.Pool
+}
+
+func NewJobOutboxRepository(pgxPool *pgxpool.Pool) JobOutboxRepository {
+	return &JobOutboxRepositoryImpl{
+		pgxPool: pgxPool,
+	}
+}
+
+// AddOutboxJobs adds jobs to the outbox within the caller's transaction, they are published only if it commits.
+func (j *JobOutboxRepositoryImpl) AddOutboxJobs(ctx context.Context, tx pgx.Tx, jobs ...model.OutboxJob) error {
+	for _, job := range jobs {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO job_outbox (job_id, queue, job, created_at) VALUES ($1, $2, $3, $4)
+		`, job.JobID, job.Queue, job.Job, job.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(ctx, `SELECT pg_notify($1, '')`, JobOutboxChannel)
+	return err
+}
+
+/*
+RelayOutboxJobs calls publish for up to limit of the oldest jobs in the outbox that are due and deletes the ones published.
+A job that fails to publish stays in the outbox and is tried again after a backoff that doubles with every failed attempt,
+from a second up to an hour. After maxAttempts failed attempts it is moved to the dead letters, where it is kept but not
+published anymore. The jobs are locked until they are published, rows locked by another relay are skipped.
+It returns the number of jobs published.
+*/
+func (j *JobOutboxRepositoryImpl) RelayOutboxJobs(ctx context.Context, limit int, maxAttempts int, publish func(model.OutboxJob) error) (int, error) {
+	tx, err := j.pgxPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, job_id, queue, job, attempts, created_at FROM job_outbox
+		WHERE dead_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var jobs []model.OutboxJob
+	for rows.Next() {
+		var job model.OutboxJob
+		if err := rows.Scan(&job.ID, &job.JobID, &job.Queue, &job.Job, &job.Attempts, &job.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for _, job := range jobs {
+		if err := publish(job); err != nil {
+			publishErr = errors.Join(publishErr, err)
+
+			dead := job.Attempts+1 >= maxAttempts
+			if dead {
+				publishErr = errors.Join(publishErr, fmt.Errorf("outbox job %d moved to the dead letters after %d attempts", job.ID, job.Attempts+1))
+			}
+			if _, err := tx.Exec(ctx, `
+				UPDATE job_outbox SET
+					attempts = attempts + 1,
+					last_error = $2,
+					next_attempt_at = NOW() + LEAST(POWER(2, attempts), 3600) * INTERVAL '1 second',
+					dead_at = CASE WHEN $3 THEN NOW() END
+				WHERE id = $1
+			`, job.ID, err.Error(), dead); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM job_outbox WHERE id = $1`, job.ID); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return published, publishErr
+}
+
+// CountOutboxJobs returns the number of jobs waiting in the outbox, without the dead letters.
+func (j *JobOutboxRepositoryImpl) CountOutboxJobs(ctx context.Context) (int64, error) {
+	var count int64
+	err := j.pgxPool.QueryRow(ctx, `SELECT COUNT(*) FROM job_outbox WHERE dead_at IS NULL`).Scan(&count)
+
+	return count, err
+}
+
+// WaitForOutboxJobs blocks until jobs are added to the outbox or the context is done.
+func (j *JobOutboxRepositoryImpl) WaitForOutboxJobs(ctx context.Context) error {
+	conn, err := j.pgxPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `LISTEN `+JobOutboxChannel); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `UNLISTEN `+JobOutboxChannel)
+
+	_, err = conn.Conn().WaitForNotification(ctx)
+	return err
+}
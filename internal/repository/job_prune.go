@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"webapi/internal/db/model"
+)
+
+// GetPrunableJobs returns up to limit jobs with one of the statuses that were last updated before the given time,
+// the least recently updated first.
+func (j *JobRepositoryImpl) GetPrunableJobs(ctx context.Context, statuses []string, before time.Time, limit int) ([]model.Job, error) {
+	rows, err := j.pgxPool.Query(ctx, `
+		SELECT `+jobColumns+` FROM jobs
+		WHERE status = ANY($1) AND updated_at < $2
+		ORDER BY updated_at, id
+		LIMIT $3
+	`, statuses, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []model.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// PruneJobs deletes the jobs with the given IDs that still have one of the statuses and were last updated before
+// the given time, with their failed_jobs records, and returns how many were deleted. A job retried since it was
+// selected is kept.
+func (j *JobRepositoryImpl) PruneJobs(ctx context.Context, ids []uuid.UUID, statuses []string, before time.Time) (int64, error) {
+	tx, err := j.pgxPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM jobs WHERE id = ANY($1) AND status = ANY($2) AND updated_at < $3
+		RETURNING id
+	`, ids, statuses, before)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		deleted = append(deleted, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM failed_jobs WHERE job_id = ANY($1)`, deleted); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return int64(len(deleted)), nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"webapi/internal/db/model"
+)
+
+// Queries of the postgres queue backend, which keeps the jobs of a queue in the jobs table.
+
+// queueStateConditions maps the states of a queue to the jobs that are in them.
+var queueStateConditions = map[string]string{
+	"pending":    "status = 'pending' AND available_at <= NOW()",
+	"delayed":    "status = 'pending' AND available_at > NOW()",
+	"processing": "status = 'processing'",
+	"failed":     "status = 'failed'",
+}
+
+func queueStateCondition(state string) (string, error) {
+	condition, ok := queueStateConditions[state]
+	if !ok {
+		return "", fmt.Errorf("unknown job state %q", state)
+	}
+
+	return condition, nil
+}
+
+// ReserveNextJob marks the next available job of the queue as processing and increments its attempts.
+// A job reserved before staleBefore is taken over, its worker is assumed dead.
+// SKIP LOCKED lets concurrent workers reserve different jobs. It returns pgx.ErrNoRows when there is none.
+func (j *JobRepositoryImpl) ReserveNextJob(ctx context.Context, queue string, staleBefore time.Time) (model.Job, error) {
+	row := j.pgxPool.QueryRow(ctx, `
+		UPDATE jobs SET status = 'processing', attempts = attempts + 1, reserved_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE queue = $1 AND (
+				(status = 'pending' AND available_at <= NOW()) OR
+				(status = 'processing' AND reserved_at < $2)
+			)
+			ORDER BY available_at ASC, created_at ASC
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns, queue, staleBefore)
+
+	return scanJob(row)
+}
+
+// FinishReservedJob sets a reserved job to the given status. A job that is no longer processing,
+// for example because it was cancelled meanwhile, is left as it is.
+func (j *JobRepositoryImpl) FinishReservedJob(ctx context.Context, jobID uuid.UUID, status string) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET status = $1, reserved_at = NULL, updated_at = NOW() WHERE id = $2 AND status = 'processing'
+	`, status, jobID)
+
+	return err
+}
+
+// RequeueJob sets a job back to pending. It can be reserved again from availableAt.
+func (j *JobRepositoryImpl) RequeueJob(ctx context.Context, jobID uuid.UUID, attempts int, availableAt time.Time) error {
+	_, err := j.pgxPool.Exec(ctx, `
+		UPDATE jobs SET status = 'pending', attempts = $1, available_at = $2, reserved_at = NULL, updated_at = NOW() WHERE id = $3
+	`, attempts, availableAt, jobID)
+
+	return err
+}
+
+// RequeueFailedJob sets a failed job of the queue back to pending with its attempts reset.
+// It returns pgx.ErrNoRows when the job is not a failed job of the queue.
+func (j *JobRepositoryImpl) RequeueFailedJob(ctx context.Context, queue string, jobID uuid.UUID) (model.Job, error) {
+	row := j.pgxPool.QueryRow(ctx, `
+		UPDATE jobs SET status = 'pending', attempts = 0, available_at = NOW(), reserved_at = NULL, updated_at = NOW()
+		WHERE queue = $1 AND id = $2 AND status = 'failed'
+		RETURNING `+jobColumns, queue, jobID)
+
+	return scanJob(row)
+}
+
+// GetQueueJob returns a job of the queue in the given state. It returns pgx.ErrNoRows when there is none.
+func (j *JobRepositoryImpl) GetQueueJob(ctx context.Context, queue string, state string, jobID uuid.UUID) (model.Job, error) {
+	condition, err := queueStateCondition(state)
+	if err != nil {
+		return model.Job{}, err
+	}
+
+	row := j.pgxPool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE queue = $1 AND id = $2 AND `+condition, queue, jobID)
+
+	return scanJob(row)
+}
+
+// GetQueueJobs returns a page of the jobs of the queue in the given state and the total number of jobs in that state.
+// A limit of 0 returns every job from the offset.
+func (j *JobRepositoryImpl) GetQueueJobs(ctx context.Context, queue string, state string, offset int64, limit int64) ([]model.Job, int64, error) {
+	condition, err := queueStateCondition(state)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	err = j.pgxPool.QueryRow(ctx, `SELECT COUNT(*) FROM jobs WHERE queue = $1 AND `+condition, queue).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pageLimit any
+	if limit > 0 {
+		pageLimit = limit
+	}
+
+	rows, err := j.pgxPool.Query(ctx, `
+		SELECT `+jobColumns+` FROM jobs WHERE queue = $1 AND `+condition+`
+		ORDER BY available_at ASC, created_at ASC
+		OFFSET $2 LIMIT $3
+	`, queue, offset, pageLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs, err := handleSelectJob(rows)
+
+	return jobs, total, err
+}
+
+// CountQueueJobs returns the number of jobs of the queue in each state.
+func (j *JobRepositoryImpl) CountQueueJobs(ctx context.Context, queue string) (map[string]int64, error) {
+	var pending, processing, failed, delayed int64
+	err := j.pgxPool.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE `+queueStateConditions["pending"]+`),
+			COUNT(*) FILTER (WHERE `+queueStateConditions["processing"]+`),
+			COUNT(*) FILTER (WHERE `+queueStateConditions["failed"]+`),
+			COUNT(*) FILTER (WHERE `+queueStateConditions["delayed"]+`)
+		FROM jobs WHERE queue = $1
+	`, queue).Scan(&pending, &processing, &failed, &delayed)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]int64{
+		"pending":    pending,
+		"processing": processing,
+		"failed":     failed,
+		"delayed":    delayed,
+	}, nil
+}
+
+// DeleteQueueJob deletes a job of the queue in the given state and returns it.
+// It returns pgx.ErrNoRows when there is none.
+func (j *JobRepositoryImpl) DeleteQueueJob(ctx context.Context, queue string, state string, jobID uuid.UUID) (model.Job, error) {
+	condition, err := queueStateCondition(state)
+	if err != nil {
+		return model.Job{}, err
+	}
+
+	row := j.pgxPool.QueryRow(ctx, `DELETE FROM jobs WHERE queue = $1 AND id = $2 AND `+condition+` RETURNING `+jobColumns, queue, jobID)
+
+	return scanJob(row)
+}
+
+// DeleteQueueJobs deletes every job of the queue in the given state and returns how many were deleted.
+func (j *JobRepositoryImpl) DeleteQueueJobs(ctx context.Context, queue string, state string) (int64, error) {
+	condition, err := queueStateCondition(state)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := j.pgxPool.Exec(ctx, `DELETE FROM jobs WHERE queue = $1 AND `+condition, queue)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
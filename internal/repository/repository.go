@@ -6,10 +6,14 @@ import (
 )
 
 type Repository struct {
-	User    UserRepository
-	Job     JobRepository
-	Media   MediaRepository
-	Setting SettingRepository
+	User        UserRepository
+	Job         JobRepository
+	JobBatch    JobBatchRepository
+	JobOutbox   JobOutboxRepository
+	Media       MediaRepository
+	Setting     SettingRepository
+	Schedule    ScheduleRepository
+	ScheduleRun ScheduleRunRepository
 }
 
 func NewRepository() *Repository {
@@ -17,9 +21,13 @@ func NewRepository() *Repository {
 	redisClient := rdb.GetRedisClient()
 
 	return &Repository{
-		User:    NewUserRepository(pgxPool, redisClient),
-		Job:     NewJobRepository(pgxPool),
-		Media:   NewMediaRepository(pgxPool, redisClient),
-		Setting: NewSettingRepository(pgxPool, redisClient),
+		User:        NewUserRepository(pgxPool, redisClient),
+		Job:         NewJobRepository(pgxPool),
+		JobBatch:    NewJobBatchRepository(pgxPool),
+		JobOutbox:   NewJobOutboxRepository(pgxPool),
+		Media:       NewMediaRepository(pgxPool, redisClient),
+		Setting:     NewSettingRepository(pgxPool, redisClient),
+		Schedule:    NewScheduleRepository(pgxPool),
+		ScheduleRun: NewScheduleRunRepository(pgxPool),
 	}
 }
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"webapi/internal/db/model"
+)
+
+const scheduleColumns = `id, task, cron, timezone, jitter, is_enabled, lock, lock_ttl, misfire_policy, misfire_limit, created_at, updated_at`
+
+type ScheduleRepository interface {
+	SeedSchedules(ctx context.Context, schedules []model.Schedule) (int64, error)
+	GetSchedules(ctx context.Context) ([]model.Schedule, error)
+	GetScheduleByTask(ctx context.Context, task string) (model.Schedule, error)
+	AddSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error)
+	DeleteSchedule(ctx context.Context, task string) error
+}
+
+type ScheduleRepositoryImpl struct {
+	pgxPool *pgxpool.Pool
+}
+
+func NewScheduleRepository(pgxPool *pgxpool.Pool) ScheduleRepository {
+	return &ScheduleRepositoryImpl{
+		pgxPool: pgxPool,
+	}
+}
+
+// SeedSchedules adds the schedules whose task has no schedule yet and returns how many were added.
+// The schedules already in the database are left as they are, they may have been edited.
+func (s *ScheduleRepositoryImpl) SeedSchedules(ctx context.Context, schedules []model.Schedule) (int64, error) {
+	var seeded int64
+	for _, schedule := range schedules {
+		tag, err := s.pgxPool.Exec(ctx, `
+			INSERT INTO schedules (task, cron, timezone, jitter, is_enabled, lock, lock_ttl, misfire_policy, misfire_limit)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (task) DO NOTHING
+		`, schedule.Task, schedule.Cron, schedule.Timezone, schedule.Jitter, schedule.IsEnabled, schedule.Lock, schedule.LockTTL,
+			schedule.MisfirePolicy, schedule.MisfireLimit)
+		if err != nil {
+			return seeded, err
+		}
+		seeded += tag.RowsAffected()
+	}
+
+	return seeded, nil
+}
+
+func (s *ScheduleRepositoryImpl) GetSchedules(ctx context.Context) ([]model.Schedule, error) {
+	rows, err := s.pgxPool.Query(ctx, `SELECT `+scheduleColumns+` FROM schedules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []model.Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// GetScheduleByTask returns the schedule of the task. It returns pgx.ErrNoRows when there is none.
+func (s *ScheduleRepositoryImpl) GetScheduleByTask(ctx context.Context, task string) (model.Schedule, error) {
+	row := s.pgxPool.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE task = $1`, task)
+
+	return scanSchedule(row)
+}
+
+func (s *ScheduleRepositoryImpl) AddSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+	row := s.pgxPool.QueryRow(ctx, `
+		INSERT INTO schedules (task, cron, timezone, jitter, is_enabled, lock, lock_ttl, misfire_policy, misfire_limit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+scheduleColumns,
+		schedule.Task, schedule.Cron, schedule.Timezone, schedule.Jitter, schedule.IsEnabled, schedule.Lock, schedule.LockTTL,
+		schedule.MisfirePolicy, schedule.MisfireLimit)
+
+	return scanSchedule(row)
+}
+
+// UpdateSchedule updates the schedule of the task. It returns pgx.ErrNoRows when there is none.
+func (s *ScheduleRepositoryImpl) UpdateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+	row := s.pgxPool.QueryRow(ctx, `
+		UPDATE schedules SET cron = $1, timezone = $2, jitter = $3, is_enabled = $4, lock = $5, lock_ttl = $6,
+			misfire_policy = $7, misfire_limit = $8, updated_at = NOW()
+		WHERE task = $9
+		RETURNING `+scheduleColumns,
+		schedule.Cron, schedule.Timezone, schedule.Jitter, schedule.IsEnabled, schedule.Lock, schedule.LockTTL,
+		schedule.MisfirePolicy, schedule.MisfireLimit, schedule.Task)
+
+	return scanSchedule(row)
+}
+
+// DeleteSchedule deletes the schedule of the task. It returns pgx.ErrNoRows when there is none.
+func (s *ScheduleRepositoryImpl) DeleteSchedule(ctx context.Context, task string) error {
+	tag, err := s.pgxPool.Exec(ctx, `DELETE FROM schedules WHERE task = $1`, task)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanSchedule(row pgx.Row) (model.Schedule, error) {
+	var schedule model.Schedule
+	err := row.Scan(&schedule.ID, &schedule.Task, &schedule.Cron, &schedule.Timezone, &schedule.Jitter, &schedule.IsEnabled,
+		&schedule.Lock, &schedule.LockTTL, &schedule.MisfirePolicy, &schedule.MisfireLimit, &schedule.CreatedAt, &schedule.UpdatedAt)
+
+	return schedule, err
+}
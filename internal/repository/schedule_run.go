@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"webapi/internal/db/model"
+)
+
+const scheduleRunColumns = `id, task, status, error, host, started_at, finished_at, duration_ms`
+
+type ScheduleRunRepository interface {
+	AddScheduleRun(ctx context.Context, run model.ScheduleRun) (int64, error)
+	FinishScheduleRun(ctx context.Context, run model.ScheduleRun) error
+	GetScheduleRuns(ctx context.Context, task string, offset int64, limit int64) ([]model.ScheduleRun, int64, error)
+	GetLastScheduleRun(ctx context.Context, task string, status string) (model.ScheduleRun, error)
+}
+
+type ScheduleRunRepositoryImpl struct {
+	pgxPool *pgxpool.Pool
+}
+
+func NewScheduleRunRepository(pgxPool *pgxpool.Pool) ScheduleRunRepository {
+	return &ScheduleRunRepositoryImpl{
+		pgxPool: pgxPool,
+	}
+}
+
+// AddScheduleRun records a run and returns its id.
+func (s *ScheduleRunRepositoryImpl) AddScheduleRun(ctx context.Context, run model.ScheduleRun) (int64, error) {
+	var id int64
+	err := s.pgxPool.QueryRow(ctx, `
+		INSERT INTO schedule_runs (task, status, error, host, started_at, finished_at, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, run.Task, run.Status, run.Error, run.Host, run.StartedAt, run.FinishedAt, run.DurationMs).Scan(&id)
+
+	return id, err
+}
+
+// FinishScheduleRun records the outcome of a run added with AddScheduleRun.
+func (s *ScheduleRunRepositoryImpl) FinishScheduleRun(ctx context.Context, run model.ScheduleRun) error {
+	_, err := s.pgxPool.Exec(ctx, `
+		UPDATE schedule_runs SET status = $1, error = $2, finished_at = $3, duration_ms = $4 WHERE id = $5
+	`, run.Status, run.Error, run.FinishedAt, run.DurationMs, run.ID)
+
+	return err
+}
+
+// GetScheduleRuns returns a page of the runs of the task, or of every task when task is empty, latest first,
+// and the total number of runs.
+func (s *ScheduleRunRepositoryImpl) GetScheduleRuns(ctx context.Context, task string, offset int64, limit int64) ([]model.ScheduleRun, int64, error) {
+	var total int64
+	err := s.pgxPool.QueryRow(ctx, `SELECT COUNT(*) FROM schedule_runs WHERE $1 = '' OR task = $1`, task).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.pgxPool.Query(ctx, `
+		SELECT `+scheduleRunColumns+` FROM schedule_runs WHERE $1 = '' OR task = $1
+		ORDER BY started_at DESC, id DESC
+		OFFSET $2 LIMIT $3
+	`, task, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs, err := handleSelectScheduleRun(rows)
+
+	return runs, total, err
+}
+
+// GetLastScheduleRun returns the latest run of the task with the given status. It returns pgx.ErrNoRows when there is none.
+func (s *ScheduleRunRepositoryImpl) GetLastScheduleRun(ctx context.Context, task string, status string) (model.ScheduleRun, error) {
+	var run model.ScheduleRun
+	err := s.pgxPool.QueryRow(ctx, `
+		SELECT `+scheduleRunColumns+` FROM schedule_runs WHERE task = $1 AND status = $2
+		ORDER BY started_at DESC, id DESC
+		LIMIT 1
+	`, task, status).Scan(&run.ID, &run.Task, &run.Status, &run.Error, &run.Host, &run.StartedAt, &run.FinishedAt, &run.DurationMs)
+
+	return run, err
+}
+
+func handleSelectScheduleRun(rows pgx.Rows) ([]model.ScheduleRun, error) {
+	runs := []model.ScheduleRun{}
+	for rows.Next() {
+		var run model.ScheduleRun
+		err := rows.Scan(&run.ID, &run.Task, &run.Status, &run.Error, &run.Host, &run.StartedAt, &run.FinishedAt, &run.DurationMs)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"strings"
+
 	"github.com/bytedance/sonic"
 	"github.com/gofiber/contrib/fibersentry"
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +17,26 @@ import (
 	httpError "webapi/internal/http/controllers/error"
 )
 
+// Responses of these paths change while jobs run or schedules are edited and are polled by clients, so they are never cached.
+// The cache keys on the path alone and runs before the permission checks of the routes, so no protected
+// path may be cached either.
+var uncachedPathPrefixes = []string{
+	"/api/v1/jobs",
+	"/api/v1/batches",
+	"/api/v1/queues",
+	"/api/v1/schedules",
+	"/metrics",
+}
+
+func isUncachedPath(c *fiber.Ctx) bool {
+	for _, prefix := range uncachedPathPrefixes {
+		if strings.HasPrefix(c.Path(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func NewFiberRouter() *fiber.App {
 	r := fiber.New(fiber.Config{
 		JSONEncoder:           sonic.Marshal,
@@ -29,7 +51,9 @@ func NewFiberRouter() *fiber.App {
 	r.Use(requestid.New())
 	r.Use(recover.New())
 	r.Use(idempotency.New())
-	r.Use(cache.New())
+	r.Use(cache.New(cache.Config{
+		Next: isUncachedPath,
+	}))
 	r.Use(middleware.Logger())
 	r.Use(fibersentry.New(fibersentry.Config{
 		Repanic:         true,
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"webapi/internal/helper/utils"
+	"webapi/pkg/exception"
+)
+
+// RequirePermission only lets through requests with a valid bearer token whose
+// "permissions" claim grants the given permission.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !found || tokenString == "" {
+			return exception.UnauthorizedError
+		}
+
+		claims, err := utils.ParseToken(tokenString)
+		if err != nil {
+			return exception.UnauthorizedError
+		}
+
+		if !utils.HasPermission(claims, permission) {
+			return exception.ForbiddenError
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"webapi/config"
+	"webapi/internal/db/model"
+	"webapi/internal/helper/queue"
+	"webapi/internal/job"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
+)
+
+const (
+	RunRunning   = "running"   // RunRunning is the status of a run that did not finish yet, or whose instance died.
+	RunSucceeded = "succeeded" // RunSucceeded is the status of a run whose task returned no error.
+	RunFailed    = "failed"    // RunFailed is the status of a run whose task returned an error or panicked.
+	RunSkipped   = "skipped"   // RunSkipped is the status of a run skipped because the task held its lock elsewhere.
+
+	// FailureEvent is the type of the body posted to scheduler.failureWebhook.
+	FailureEvent = "schedule.failed"
+)
+
+// FailureHook is called with the run of a task that failed, after it was recorded.
+type FailureHook func(ctx context.Context, run model.ScheduleRun) error
+
+var (
+	hooksMu      sync.RWMutex
+	failureHooks []FailureHook
+
+	hostname = func() string {
+		name, err := os.Hostname()
+		if err != nil {
+			return instanceID
+		}
+		return name
+	}()
+)
+
+func init() {
+	OnFailure(notifyFailureWebhook)
+}
+
+// OnFailure adds a hook called every time a task fails. It is meant to be called from an init function.
+func OnFailure(hook FailureHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	failureHooks = append(failureHooks, hook)
+}
+
+// startRun records the start of a run. A run that cannot be recorded is still run, it is recorded when it finishes.
+func startRun(name string, startedAt time.Time) model.ScheduleRun {
+	run := model.ScheduleRun{Task: name, Status: RunRunning, Host: hostname, StartedAt: startedAt}
+
+	id, err := repository.NewRepository().ScheduleRun.AddScheduleRun(context.Background(), run)
+	if err != nil {
+		logger.Log.Error("Error recording the task run", zap.String("task", name), zap.Error(err))
+	}
+	run.ID = id
+
+	return run
+}
+
+// finishRun records the outcome of a run and calls the failure hooks when it failed.
+func finishRun(run model.ScheduleRun, status string, err error) {
+	ctx := context.Background()
+	finishedAt := time.Now()
+	duration := finishedAt.Sub(run.StartedAt).Milliseconds()
+
+	run.Status = status
+	run.FinishedAt = &finishedAt
+	run.DurationMs = &duration
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	repo := repository.NewRepository().ScheduleRun
+	if run.ID != 0 {
+		err = repo.FinishScheduleRun(ctx, run)
+	} else {
+		run.ID, err = repo.AddScheduleRun(ctx, run)
+	}
+	if err != nil {
+		logger.Log.Error("Error recording the task run", zap.String("task", run.Task), zap.Error(err))
+	}
+
+	if status != RunFailed {
+		return
+	}
+
+	hooksMu.RLock()
+	hooks := failureHooks
+	hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook(ctx, run); err != nil {
+			logger.Log.Error("Error running the task failure hook", zap.String("task", run.Task), zap.Error(err))
+		}
+	}
+}
+
+// notifyFailureWebhook enqueues the delivery of the failed run to scheduler.failureWebhook, when it is set.
+// The delivery is a job.JobCallback, signed and retried like the callbacks of jobs.
+func notifyFailureWebhook(ctx context.Context, run model.ScheduleRun) error {
+	url := config.GetConfig().Scheduler.FailureWebhook
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"type": FailureEvent,
+		"run":  run,
+	})
+	if err != nil {
+		return err
+	}
+
+	j, err := job.NewCallback(url, body)
+	if err != nil {
+		return err
+	}
+
+	return queue.NewQueue(j.Queue).Enqueue(ctx, j)
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"webapi/internal/db/rdb"
+)
+
+// Only the acquisition holding a lease may renew or release it
+var (
+	renewScript = redis.NewScript(`
+		if redis.call('GET', KEYS[1]) == ARGV[1] then
+			return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		end
+		return 0
+	`)
+	releaseScript = redis.NewScript(`
+		if redis.call('GET', KEYS[1]) == ARGV[1] then
+			return redis.call('DEL', KEYS[1])
+		end
+		return 0
+	`)
+)
+
+// instanceID identifies this scheduler process in the leases it holds.
+var instanceID = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}()
+
+/*
+lease is a redis key held by one acquisition at a time, it expires unless renewed.
+The key holds a token made of the instance ID and a random part, new for every acquisition,
+so that two acquisitions of the same process, such as two runs of a task, never share the lease.
+*/
+type lease struct {
+	key   string
+	ttl   time.Duration
+	token string // of the current acquisition, empty when the lease is not held
+}
+
+// acquire takes the lease when nobody holds it, including an earlier acquisition of the same instance.
+func (l *lease) acquire(ctx context.Context) (bool, error) {
+	token := instanceID + ":" + uuid.NewString()
+	acquired, err := rdb.GetRedisClient().SetNX(ctx, l.key, token, l.ttl).Result()
+	if err != nil || !acquired {
+		return false, err
+	}
+
+	l.token = token
+	return true, nil
+}
+
+// renew extends the lease, it returns false when the lease expired or another acquisition holds it.
+func (l *lease) renew(ctx context.Context) (bool, error) {
+	if l.token == "" {
+		return false, nil
+	}
+
+	renewed, err := renewScript.Run(ctx, rdb.GetRedisClient(), []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+// release deletes the lease when this acquisition still holds it.
+func (l *lease) release(ctx context.Context) error {
+	if l.token == "" {
+		return nil
+	}
+
+	err := releaseScript.Run(ctx, rdb.GetRedisClient(), []string{l.key}, l.token).Err()
+	l.token = ""
+	return err
+}
+
+// holder returns the instance holding the lease, or an empty string when nobody holds it.
+func (l *lease) holder(ctx context.Context) (string, error) {
+	holder, err := rdb.GetRedisClient().Get(ctx, l.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+
+	instance, _, _ := strings.Cut(holder, ":")
+	return instance, err
+}
+
+func leaderLease(ttl time.Duration) *lease {
+	return &lease{key: rdb.AddPrefix("scheduler_leader"), ttl: ttl}
+}
+
+func taskLock(name string, ttl time.Duration) *lease {
+	return &lease{key: rdb.AddPrefix("scheduler_task_lock:" + name), ttl: ttl}
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"webapi/config"
+	"webapi/internal/db/model"
+	"webapi/internal/db/rdb"
+)
+
+// requireRedis connects to the redis of the testing config, the test is skipped when it is not reachable.
+func requireRedis(t *testing.T) {
+	t.Helper()
+
+	config.SetConfig("../../config/config.testing.yaml")
+	if err := rdb.InitRedisClient(config.GetConfig().Redis); err != nil {
+		t.Skipf("redis is not reachable: %v", err)
+	}
+}
+
+// testLease returns a lease on a key of its own, deleted after the test.
+func testLease(t *testing.T, ttl time.Duration) *lease {
+	l := &lease{key: rdb.AddPrefix("test_lease:" + uuid.NewString()), ttl: ttl}
+	t.Cleanup(func() { rdb.GetRedisClient().Del(context.Background(), l.key) })
+
+	return l
+}
+
+func TestLease(t *testing.T) {
+	requireRedis(t)
+	ctx := context.Background()
+
+	first := testLease(t, time.Minute)
+	acquired, err := first.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	holder, err := first.holder(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, instanceID, holder)
+
+	// A second acquisition of the same process does not share the lease
+	second := &lease{key: first.key, ttl: first.ttl}
+	acquired, err = second.acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	renewed, err := second.renew(ctx)
+	require.NoError(t, err)
+	assert.False(t, renewed)
+
+	require.NoError(t, second.release(ctx))
+	holder, err = first.holder(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, instanceID, holder, "releasing a lease not held keeps it")
+
+	renewed, err = first.renew(ctx)
+	require.NoError(t, err)
+	assert.True(t, renewed)
+
+	require.NoError(t, first.release(ctx))
+	holder, err = first.holder(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, holder)
+
+	renewed, err = first.renew(ctx)
+	require.NoError(t, err)
+	assert.False(t, renewed, "a released lease cannot be renewed")
+
+	acquired, err = second.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestLeaseExpires(t *testing.T) {
+	requireRedis(t)
+	ctx := context.Background()
+
+	first := testLease(t, 50*time.Millisecond)
+	acquired, err := first.acquire(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	time.Sleep(100 * time.Millisecond)
+
+	second := &lease{key: first.key, ttl: time.Minute}
+	acquired, err = second.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	renewed, err := first.renew(ctx)
+	require.NoError(t, err)
+	assert.False(t, renewed)
+
+	// The expired acquisition does not release the lease taken over
+	require.NoError(t, first.release(ctx))
+	holder, err := second.holder(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, instanceID, holder)
+}
+
+func TestLockTask(t *testing.T) {
+	requireRedis(t)
+	ctx := context.Background()
+
+	task := "TestLockTask-" + uuid.NewString()
+	t.Cleanup(func() { rdb.GetRedisClient().Del(context.Background(), taskLock(task, time.Minute).key) })
+
+	release, err := lockTask(ctx, model.Schedule{Task: task, Lock: true, LockTTL: 60})
+	require.NoError(t, err)
+
+	// A second run of the task in the same process does not get the lock
+	second := taskLock(task, time.Minute)
+	acquired, err := second.acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	release()
+	acquired, err = second.acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	require.NoError(t, second.release(ctx))
+
+	// An unlocked schedule never takes the lock
+	release, err = lockTask(ctx, model.Schedule{Task: task})
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"webapi/internal/db/model"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
+)
+
+// RunTask runs a registered task once, right now, and returns its error. The run is locked, recorded and logged
+// as a run of its schedule is, a task without a schedule runs without a lock.
+func RunTask(ctx context.Context, name string) error {
+	schedule, task, err := manualRun(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return execute(ctx, schedule, task)
+}
+
+// StartTask starts a registered task once in the background, as RunTask does, and returns once its lock is held.
+// It returns ErrTaskLocked without running the task when the task is running on another instance.
+func StartTask(ctx context.Context, name string) error {
+	schedule, task, err := manualRun(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	release, err := lockTask(ctx, schedule)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer release()
+		_ = run(context.Background(), name, task)
+	}()
+
+	return nil
+}
+
+// manualRun returns the schedule of the task, or a schedule without lock when it has none, and the task.
+func manualRun(ctx context.Context, name string) (model.Schedule, Task, error) {
+	task, err := lookup(name)
+	if err != nil {
+		return model.Schedule{}, nil, err
+	}
+
+	schedule, err := repository.NewRepository().Schedule.GetScheduleByTask(ctx, name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		schedule = model.Schedule{Task: name}
+	} else if err != nil {
+		return model.Schedule{}, nil, err
+	}
+
+	logger.Log.Info("Task triggered manually", zap.String("task", name))
+	return schedule, task, nil
+}
@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"webapi/internal/db/model"
+	"webapi/internal/logger"
+	"webapi/internal/repository"
+)
+
+const (
+	MisfireSkip    = "skip"     // MisfireSkip drops the runs missed while no scheduler ran, the default.
+	MisfireRunOnce = "run_once" // MisfireRunOnce runs the task once when runs were missed.
+	MisfireRunAll  = "run_all"  // MisfireRunAll runs the task once for every missed run, up to the misfire limit.
+
+	defaultMisfireLimit = 10
+)
+
+// validMisfirePolicy reports whether policy is a misfire policy, empty is MisfireSkip.
+func validMisfirePolicy(policy string) bool {
+	switch policy {
+	case "", MisfireSkip, MisfireRunOnce, MisfireRunAll:
+		return true
+	default:
+		return false
+	}
+}
+
+// Misfires returns the number of times a schedule fired after last and before now, counting no more than limit.
+func Misfires(schedule cron.Schedule, last time.Time, now time.Time, limit int) int {
+	missed := 0
+	for next := schedule.Next(last); missed < limit && !next.IsZero() && next.Before(now); next = schedule.Next(next) {
+		missed++
+	}
+
+	return missed
+}
+
+// catchUpRuns returns how many runs of the schedule to catch up, by its misfire policy, after the runs missed since
+// its last successful run.
+func catchUpRuns(schedule model.Schedule, lastSuccess time.Time, now time.Time) (int, error) {
+	cronSchedule, err := ParseCron(cronSpec(schedule))
+	if err != nil {
+		return 0, err
+	}
+
+	switch schedule.MisfirePolicy {
+	case "", MisfireSkip:
+		return 0, nil
+	case MisfireRunOnce:
+		return Misfires(cronSchedule, inScheduleTimezone(schedule, lastSuccess), now, 1), nil
+	case MisfireRunAll:
+		limit := schedule.MisfireLimit
+		if limit <= 0 {
+			limit = defaultMisfireLimit
+		}
+		return Misfires(cronSchedule, inScheduleTimezone(schedule, lastSuccess), now, limit), nil
+	default:
+		return 0, fmt.Errorf("unknown misfire policy %q", schedule.MisfirePolicy)
+	}
+}
+
+// catchUp applies the misfire policy of every enabled schedule, comparing its cron expression with its last
+// successful run. A task that never succeeded has nothing to catch up. The runs caught up are run one after
+// the other, through execute as any run. The task counts as running until they are done, so its regular runs
+// are skipped meanwhile, and the runs stop with the leadership term, which waits for them.
+func (r *runner) catchUp(schedules []model.Schedule) {
+	ctx := r.ctx
+	now := time.Now()
+	repo := repository.NewRepository().ScheduleRun
+
+	for _, schedule := range schedules {
+		if !schedule.IsEnabled || schedule.MisfirePolicy == "" || schedule.MisfirePolicy == MisfireSkip {
+			continue
+		}
+
+		last, err := repo.GetLastScheduleRun(ctx, schedule.Task, RunSucceeded)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			logger.Log.Error("Error getting the last successful run", zap.String("task", schedule.Task), zap.Error(err))
+			continue
+		}
+
+		runs, err := catchUpRuns(schedule, last.StartedAt, now)
+		if err != nil {
+			logger.Log.Error("Invalid schedule, not catching up", zap.String("task", schedule.Task), zap.Error(err))
+			continue
+		}
+		if runs == 0 {
+			continue
+		}
+
+		task, err := lookup(schedule.Task)
+		if err != nil {
+			logger.Log.Error("Invalid schedule, not catching up", zap.String("task", schedule.Task), zap.Error(err))
+			continue
+		}
+
+		logger.Log.Info("Catching up missed runs", zap.String("task", schedule.Task), zap.String("policy", schedule.MisfirePolicy),
+			zap.Time("last_success", last.StartedAt), zap.Int("runs", runs))
+
+		if !r.start(schedule.Task) {
+			continue
+		}
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer r.done(schedule.Task)
+
+			for i := 0; i < runs && ctx.Err() == nil; i++ {
+				_ = execute(ctx, schedule, task)
+			}
+		}()
+	}
+}
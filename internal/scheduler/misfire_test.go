@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"webapi/internal/db/model"
+)
+
+func TestCatchUpRuns(t *testing.T) {
+	// Hourly, the last success 5 runs ago
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+	lastSuccess := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		schedule    model.Schedule
+		lastSuccess time.Time
+		want        int
+	}{
+		{name: "skip by default", schedule: model.Schedule{Cron: "0 0 * * * *"}, lastSuccess: lastSuccess, want: 0},
+		{name: "skip", schedule: model.Schedule{Cron: "0 0 * * * *", MisfirePolicy: MisfireSkip}, lastSuccess: lastSuccess, want: 0},
+		{name: "run once", schedule: model.Schedule{Cron: "0 0 * * * *", MisfirePolicy: MisfireRunOnce}, lastSuccess: lastSuccess, want: 1},
+		{name: "run all", schedule: model.Schedule{Cron: "0 0 * * * *", MisfirePolicy: MisfireRunAll}, lastSuccess: lastSuccess, want: 5},
+		{name: "run all up to the limit", schedule: model.Schedule{Cron: "0 0 * * * *", MisfirePolicy: MisfireRunAll, MisfireLimit: 3}, lastSuccess: lastSuccess, want: 3},
+		{name: "run all up to the default limit", schedule: model.Schedule{Cron: "* * * * * *", MisfirePolicy: MisfireRunAll}, lastSuccess: lastSuccess, want: defaultMisfireLimit},
+		{name: "nothing missed", schedule: model.Schedule{Cron: "0 0 * * * *", MisfirePolicy: MisfireRunAll}, lastSuccess: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), want: 0},
+		{name: "own timezone", schedule: model.Schedule{Cron: "0 0 14 * * *", Timezone: "Asia/Jakarta", MisfirePolicy: MisfireRunAll}, lastSuccess: lastSuccess.Add(-24 * time.Hour), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := catchUpRuns(tt.schedule, tt.lastSuccess, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := catchUpRuns(model.Schedule{Cron: "0 0 * * * *", MisfirePolicy: "sometimes"}, lastSuccess, now)
+	assert.Error(t, err)
+}
+
+func TestCatchUpRunsInSchedulerTimezone(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+	setTimezone(t, jakarta)
+
+	// Daily at 14:00 in Jakarta, 07:00 UTC. The last success was at 06:00 UTC, 13:00 in Jakarta
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+	lastSuccess := time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC)
+
+	got, err := catchUpRuns(model.Schedule{Cron: "0 0 14 * * *", MisfirePolicy: MisfireRunAll}, lastSuccess, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got)
+}
+
+func TestRunnerStart(t *testing.T) {
+	r := newRunner(context.Background())
+
+	assert.True(t, r.start("PruneJobs"))
+	assert.False(t, r.start("PruneJobs"), "a running task is not started again")
+	assert.True(t, r.start("Other"))
+
+	r.done("PruneJobs")
+	assert.True(t, r.start("PruneJobs"))
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"webapi/internal/db/model"
+)
+
+const (
+	// MinLintInterval is the shortest interval between two runs Lint accepts.
+	MinLintInterval = time.Minute
+
+	// lintSamples is the number of runs Lint looks at to find the shortest interval.
+	lintSamples = 100
+)
+
+// NextRuns returns the next n times the schedule fires after from, in its own timezone or in Timezone when it has none.
+// It returns fewer times when the schedule stops firing, none when it never fires.
+func NextRuns(schedule model.Schedule, from time.Time, n int) ([]time.Time, error) {
+	cronSchedule, err := ParseCron(cronSpec(schedule))
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]time.Time, 0, n)
+	for next := cronSchedule.Next(inScheduleTimezone(schedule, from)); len(runs) < n && !next.IsZero(); next = cronSchedule.Next(next) {
+		runs = append(runs, next)
+	}
+
+	return runs, nil
+}
+
+// Lint returns the problems of the schedule: the errors of ValidateSchedule, a cron expression that never fires,
+// such as the 30th of February, or one that fires more often than every MinLintInterval.
+func Lint(schedule model.Schedule, from time.Time) []string {
+	if err := ValidateSchedule(schedule); err != nil {
+		return []string{err.Error()}
+	}
+
+	runs, _ := NextRuns(schedule, from, lintSamples)
+	if len(runs) == 0 {
+		return []string{"never fires"}
+	}
+
+	var shortest time.Duration
+	for i := 1; i < len(runs); i++ {
+		if interval := runs[i].Sub(runs[i-1]); shortest == 0 || interval < shortest {
+			shortest = interval
+		}
+	}
+	if len(runs) > 1 && shortest < MinLintInterval {
+		return []string{fmt.Sprintf("fires every %s, more often than every %s", shortest, MinLintInterval)}
+	}
+
+	return nil
+}
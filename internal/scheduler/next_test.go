@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"webapi/internal/db/model"
+)
+
+func TestNextRuns(t *testing.T) {
+	setTimezone(t, time.UTC)
+	from := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+
+	runs, err := NextRuns(model.Schedule{Task: "PruneJobs", Cron: "0 0 * * * *"}, from, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC),
+	}, runs)
+
+	runs, err = NextRuns(model.Schedule{Task: "PruneJobs", Cron: "0 0 2 * * *", Timezone: "Asia/Jakarta"}, from, 1)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].Equal(time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)))
+
+	runs, err = NextRuns(model.Schedule{Task: "PruneJobs", Cron: "0 0 0 30 2 *"}, from, 3)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestNextRunsInSchedulerTimezone(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	require.NoError(t, err)
+	setTimezone(t, jakarta)
+
+	// 02:00 in Jakarta is 19:00 UTC of the day before
+	from := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+	runs, err := NextRuns(model.Schedule{Task: "PruneJobs", Cron: "0 0 2 * * *"}, from, 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.True(t, runs[0].Equal(time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)))
+	assert.True(t, runs[1].Equal(time.Date(2026, 10, 20, 19, 0, 0, 0, time.UTC)))
+}
+
+// setTimezone sets the scheduler timezone for the test.
+func setTimezone(t *testing.T, location *time.Location) {
+	timezone := Timezone
+	Timezone = location
+	t.Cleanup(func() { Timezone = timezone })
+}
+
+func TestLint(t *testing.T) {
+	from := time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		schedule     model.Schedule
+		wantProblems bool
+	}{
+		{name: "daily", schedule: model.Schedule{Task: "PruneJobs", Cron: "0 30 2 * * *"}},
+		{name: "every minute", schedule: model.Schedule{Task: "PruneJobs", Cron: "0 * * * * *"}},
+		{name: "every second", schedule: model.Schedule{Task: "PruneJobs", Cron: "* * * * * *"}, wantProblems: true},
+		{name: "twice within a minute", schedule: model.Schedule{Task: "PruneJobs", Cron: "0,30 0 * * * *"}, wantProblems: true},
+		{name: "never fires", schedule: model.Schedule{Task: "PruneJobs", Cron: "0 0 0 30 2 *"}, wantProblems: true},
+		{name: "invalid", schedule: model.Schedule{Task: "PruneJobs", Cron: "every day"}, wantProblems: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Lint(tt.schedule, from)
+			if tt.wantProblems {
+				assert.NotEmpty(t, problems)
+			} else {
+				assert.Empty(t, problems)
+			}
+		})
+	}
+}
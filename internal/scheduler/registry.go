@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"webapi/internal/db/model"
+	"webapi/internal/helper/queue"
+	"webapi/internal/job"
+)
+
+// ErrTaskNotRegistered is returned for a schedule whose task was never registered.
+var ErrTaskNotRegistered = errors.New("schedule task not registered")
+
+// Task is the function a schedule runs.
+type Task func(ctx context.Context) error
+
+var (
+	registryMu sync.RWMutex
+	tasks      = make(map[string]Task)
+
+	// cronParser parses the six field cron expressions of the schedules, seconds first, as gocron does.
+	cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
+
+// Register makes a task available to the schedules under the given name.
+// It is meant to be called from an init function and panics when the name is already registered.
+func Register(name string, task Task) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		panic("scheduler: Register called with an empty task name")
+	}
+	if _, ok := tasks[name]; ok {
+		panic(fmt.Sprintf("scheduler: task %s is registered twice", name))
+	}
+
+	tasks[name] = task
+}
+
+// RegisterJob registers a task that enqueues the job returned by newJob, on the queue given with job.OnQueue
+// or on job.DefaultQueue, instead of running in the scheduler.
+func RegisterJob(name string, newJob func() (*job.Job, error)) {
+	Register(name, func(ctx context.Context) error {
+		j, err := newJob()
+		if err != nil {
+			return err
+		}
+
+		queueName := j.Queue
+		if queueName == "" {
+			queueName = job.DefaultQueue
+		}
+
+		return queue.NewQueue(queueName).Enqueue(ctx, j)
+	})
+}
+
+// IsRegistered reports whether a task is registered under the given name.
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := tasks[name]
+	return ok
+}
+
+// TaskNames returns the names of the registered tasks, sorted.
+func TaskNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func lookup(name string) (Task, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	task, ok := tasks[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotRegistered, name)
+	}
+
+	return task, nil
+}
+
+// ParseCron parses a cron expression with seconds, such as "0 30 2 * * *", or a descriptor such as "@daily".
+func ParseCron(expr string) (cron.Schedule, error) {
+	return cronParser.Parse(expr)
+}
+
+// Validate returns an error listing every schedule that is invalid, see ValidateSchedule.
+func Validate(schedules []model.Schedule) error {
+	var errs []error
+	for i, schedule := range schedules {
+		if err := ValidateSchedule(schedule); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %d: %w", i+1, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateSchedule returns an error listing every problem of the schedule: a task that is not registered,
+// an invalid cron expression, timezone or misfire policy, or a negative jitter, lock TTL or misfire limit.
+func ValidateSchedule(schedule model.Schedule) error {
+	var errs []error
+	if !IsRegistered(schedule.Task) {
+		errs = append(errs, fmt.Errorf("%w: %q", ErrTaskNotRegistered, schedule.Task))
+	}
+	if _, err := ParseCron(schedule.Cron); err != nil {
+		errs = append(errs, fmt.Errorf("%s: invalid cron expression %q: %w", schedule.Task, schedule.Cron, err))
+	}
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid timezone %q: %w", schedule.Task, schedule.Timezone, err))
+		}
+	}
+	if schedule.Jitter < 0 {
+		errs = append(errs, fmt.Errorf("%s: jitter must not be negative", schedule.Task))
+	}
+	if schedule.LockTTL < 0 {
+		errs = append(errs, fmt.Errorf("%s: lock TTL must not be negative", schedule.Task))
+	}
+	if !validMisfirePolicy(schedule.MisfirePolicy) {
+		errs = append(errs, fmt.Errorf("%s: unknown misfire policy %q", schedule.Task, schedule.MisfirePolicy))
+	}
+	if schedule.MisfireLimit < 0 {
+		errs = append(errs, fmt.Errorf("%s: misfire limit must not be negative", schedule.Task))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"webapi/internal/db/model"
+)
+
+func TestRegister(t *testing.T) {
+	Register("RegistryTest", func(ctx context.Context) error { return nil })
+
+	assert.True(t, IsRegistered("RegistryTest"))
+	assert.Contains(t, TaskNames(), "RegistryTest")
+	assert.Panics(t, func() {
+		Register("RegistryTest", func(ctx context.Context) error { return nil })
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedules []model.Schedule
+		wantErr   bool
+	}{
+		{name: "registered task", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "0 30 2 * * *"}}},
+		{name: "descriptor", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "@daily"}}},
+		{name: "unknown task", schedules: []model.Schedule{{Task: "Unknown", Cron: "0 30 2 * * *"}}, wantErr: true},
+		{name: "cron without seconds", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "30 2 * * *"}}, wantErr: true},
+		{name: "invalid cron", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "every day"}}, wantErr: true},
+		{name: "own timezone", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "0 30 2 * * *", Timezone: "Europe/Paris", Jitter: 60}}},
+		{name: "invalid timezone", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "0 30 2 * * *", Timezone: "Mars/Olympus"}}, wantErr: true},
+		{name: "negative jitter", schedules: []model.Schedule{{Task: "PruneJobs", Cron: "0 30 2 * * *", Jitter: -1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.schedules)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
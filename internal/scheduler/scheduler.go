@@ -1,57 +1,372 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"sync"
 	"time"
 	_ "time/tzdata"
 
 	"github.com/go-co-op/gocron"
+	"go.uber.org/zap"
 	"webapi/config"
+	"webapi/internal/db/model"
+	"webapi/internal/logger"
 )
 
+const (
+	defaultLeaseTTL    = 15 * time.Second
+	defaultTaskLockTTL = time.Hour
+)
+
+// ErrTaskLocked is returned for a run skipped because the task is running on another instance.
+var ErrTaskLocked = errors.New("task is running on another instance")
+
 var Timezone = time.Now().Location()
 
-func Start() {
-	if config.GetConfig().Scheduler.Timezone != "" {
-		Timezone, _ = time.LoadLocation(config.GetConfig().Scheduler.Timezone)
+// LoadTimezone sets Timezone to scheduler.timezone of the config, when it is set.
+func LoadTimezone() error {
+	name := config.GetConfig().Scheduler.Timezone
+	if name == "" {
+		return nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("invalid scheduler timezone %q: %w", name, err)
+	}
+	Timezone = location
+
+	return nil
+}
+
+/*
+Start runs the enabled schedules of the database until the context is canceled.
+Every instance started competes for a lease in redis, only the instance holding it runs the schedules
+and the others stand by. When the leader dies its lease expires after scheduler.leaseTTL and another instance takes over.
+The leader reloads the schedules every scheduler.reloadInterval and whenever NotifyChanged is called.
+When it is elected, the leader catches up the runs missed since the last successful run of each task
+by the misfire policy of its schedule.
+Start returns an error without running anything when a schedule has an unknown task or an invalid cron expression.
+*/
+func Start(ctx context.Context) error {
+	if err := LoadTimezone(); err != nil {
+		return err
+	}
+
+	schedules, err := Schedules(ctx)
+	if err != nil {
+		return err
+	}
+	if err := Validate(schedules); err != nil {
+		return err
+	}
+
+	ttl := time.Duration(config.GetConfig().Scheduler.LeaseTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	l := leaderLease(ttl)
+
+	fmt.Printf("Timezone: %s\n", Timezone.String())
+	fmt.Println("Starting scheduler... (press Ctrl+C to quit)")
+
+	standbyLogged := false
+	for {
+		acquired, err := l.acquire(ctx)
+		if err != nil {
+			logger.Log.Error("Error acquiring the scheduler lease", zap.Error(err))
+		}
+
+		if acquired {
+			logger.Log.Info("Elected scheduler leader", zap.String("instance", instanceID))
+			lead(ctx, l)
+			standbyLogged = false
+		} else if err == nil && !standbyLogged {
+			holder, _ := l.holder(ctx)
+			logger.Log.Info("Standing by, another instance runs the schedules", zap.String("leader", holder))
+			standbyLogged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(ttl / 3):
+		}
+	}
+}
+
+// lead runs the schedules for as long as the lease is renewed and the context is not canceled.
+// The tasks still running when the lease is lost are canceled.
+func lead(ctx context.Context, l *lease) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := newRunner(leaderCtx)
+	schedules, err := Schedules(leaderCtx)
+	if err != nil {
+		logger.Log.Error("Error loading the schedules", zap.Error(err))
+	}
+	r.apply(schedules)
+	r.catchUp(schedules)
+	r.s.StartAsync()
+	fmt.Printf("Total jobs: %d jobs scheduled to run\n", len(r.s.Jobs()))
+
+	reloadInterval := time.Duration(config.GetConfig().Scheduler.ReloadInterval) * time.Second
+	if reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
+	reloadTicker := time.NewTicker(reloadInterval)
+	defer reloadTicker.Stop()
+
+	reload := make(chan struct{}, 1)
+	go watchChanges(leaderCtx, reload)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for leading := true; leading; {
+		select {
+		case <-ctx.Done():
+			leading = false
+		case <-reloadTicker.C:
+			r.reload()
+		case <-reload:
+			r.reload()
+		case <-ticker.C:
+			renewed, err := l.renew(ctx)
+			if err != nil || !renewed {
+				logger.Log.Warn("Lost the scheduler lease, standing by", zap.Error(err))
+				leading = false
+			}
+		}
+	}
+
+	r.s.Stop()
+	cancel()
+	r.wg.Wait()
+
+	if err := l.release(context.Background()); err != nil {
+		logger.Log.Error("Error releasing the scheduler lease", zap.Error(err))
 	}
+}
+
+// runner keeps a gocron scheduler in sync with the schedules of the database.
+type runner struct {
+	ctx       context.Context
+	s         *gocron.Scheduler
+	scheduled map[string]model.Schedule
+
+	mu      sync.Mutex
+	running map[string]bool // tasks running in this term, for the runs outside gocron
+	wg      sync.WaitGroup  // runs outside gocron, such as the runs caught up
+}
 
+// newRunner creates a runner whose tasks run with the given context.
+func newRunner(ctx context.Context) *runner {
 	s := gocron.NewScheduler(Timezone)
 	s.SingletonModeAll()
 
-	for _, schedule := range config.GetConfig().Schedules {
-		if schedule.IsEnabled {
-			switch schedule.Job {
-			case "DoSomeThing":
-				task, err := s.CronWithSeconds(schedule.Cron).Do(func() {
-					// j := job.NewJobContext()
-					// j.DoSomeThing()
-				})
-
-				if err != nil {
-					fmt.Printf("Failed to schedule SyncAll job: %v", err)
-					continue
-				}
-
-				// Set up event listeners
-				task.SetEventListeners(func() {
-					fmt.Println("DoSomeThing Job started -- round: ", task.RunCount())
-				}, func() {
-					time.Sleep(1 * time.Second)
-
-					// Print next run time in both utc and asia/jakarta timezone
-					asiaBangkok, _ := time.LoadLocation("Asia/Jakarta")
-					fmt.Printf("\nNext run: %s / %s\n", task.NextRun().UTC().String(), task.NextRun().In(asiaBangkok).String())
-
-				})
-			}
+	return &runner{ctx: ctx, s: s, scheduled: make(map[string]model.Schedule), running: make(map[string]bool)}
+}
+
+// start marks a task running and reports whether it was not running yet. Like the singleton mode of gocron,
+// a run of a task that is still running is skipped.
+func (r *runner) start(task string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running[task] {
+		return false
+	}
+	r.running[task] = true
+
+	return true
+}
+
+// done marks a task started with start as no longer running.
+func (r *runner) done(task string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.running, task)
+}
+
+// reload loads the schedules of the database and applies them. On error the current schedules are kept.
+func (r *runner) reload() {
+	schedules, err := Schedules(r.ctx)
+	if err != nil {
+		logger.Log.Error("Error loading the schedules", zap.Error(err))
+		return
+	}
+
+	r.apply(schedules)
+}
+
+// apply reschedules the schedules added, changed or deleted since the last call. An invalid schedule is logged
+// and not run until it is changed.
+func (r *runner) apply(schedules []model.Schedule) {
+	seen := make(map[string]bool, len(schedules))
+	for _, schedule := range schedules {
+		seen[schedule.Task] = true
+
+		current, ok := r.scheduled[schedule.Task]
+		if ok && current.ID == schedule.ID && current.UpdatedAt.Equal(schedule.UpdatedAt) {
+			continue
+		}
+		if ok {
+			_ = r.s.RemoveByTag(schedule.Task)
+			logger.Log.Info("Schedule changed", zap.String("task", schedule.Task))
+		}
+		r.scheduled[schedule.Task] = schedule
+
+		if !schedule.IsEnabled {
+			continue
+		}
+		if err := r.add(schedule); err != nil {
+			logger.Log.Error("Invalid schedule, not running it", zap.String("task", schedule.Task), zap.Error(err))
 		}
 	}
 
-	fmt.Printf("Total jobs: %d jobs scheduled to run\n", len(s.Jobs()))
-	fmt.Printf("Timezone: %s\n", s.Location().String())
-	fmt.Println("Starting scheduler... (press Ctrl+C to quit)")
+	for task := range r.scheduled {
+		if !seen[task] {
+			_ = r.s.RemoveByTag(task)
+			delete(r.scheduled, task)
+			logger.Log.Info("Schedule deleted", zap.String("task", task))
+		}
+	}
+}
+
+func (r *runner) add(schedule model.Schedule) error {
+	if err := ValidateSchedule(schedule); err != nil {
+		return err
+	}
+
+	task, err := lookup(schedule.Task)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.s.CronWithSeconds(cronSpec(schedule)).Tag(schedule.Task).Do(func() {
+		if !r.start(schedule.Task) {
+			logger.Log.Info("Skipping task, its missed runs are still being caught up", zap.String("task", schedule.Task))
+			return
+		}
+		defer r.done(schedule.Task)
+
+		if !sleepJitter(r.ctx, schedule.Jitter) {
+			return
+		}
+
+		if err := execute(r.ctx, schedule, task); errors.Is(err, ErrTaskLocked) {
+			logger.Log.Info("Skipping task, it is running on another instance", zap.String("task", schedule.Task))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error scheduling %s: %w", schedule.Task, err)
+	}
+
+	cronSchedule, _ := ParseCron(cronSpec(schedule))
+	logger.Log.Info("Scheduled task", zap.String("task", schedule.Task), zap.String("cron", schedule.Cron), zap.Time("next_run", cronSchedule.Next(inScheduleTimezone(schedule, time.Now()))))
+	return nil
+}
+
+// cronSpec returns the cron expression of the schedule, prefixed with its timezone when it has its own.
+func cronSpec(schedule model.Schedule) string {
+	if schedule.Timezone == "" {
+		return schedule.Cron
+	}
+
+	return "CRON_TZ=" + schedule.Timezone + " " + schedule.Cron
+}
+
+// inScheduleTimezone returns t in Timezone when the schedule has no timezone of its own. The cron expression of
+// such a schedule is computed in the timezone of the time it starts from, not in the one the scheduler runs it in.
+func inScheduleTimezone(schedule model.Schedule, t time.Time) time.Time {
+	if schedule.Timezone != "" {
+		return t
+	}
+
+	return t.In(Timezone)
+}
+
+// sleepJitter waits a random duration up to jitter seconds. It returns false when the context was canceled meanwhile.
+func sleepJitter(ctx context.Context, jitter int) bool {
+	if jitter <= 0 {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(rand.N(time.Duration(jitter) * time.Second)):
+		return true
+	}
+}
+
+// execute runs the task of a schedule once, holding its lock when the schedule is locked.
+func execute(ctx context.Context, schedule model.Schedule, task Task) error {
+	release, err := lockTask(ctx, schedule)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return run(ctx, schedule.Task, task)
+}
+
+// lockTask acquires the lock of a locked schedule and returns the function releasing it. When another instance
+// holds the lock the run is recorded as skipped and ErrTaskLocked is returned.
+func lockTask(ctx context.Context, schedule model.Schedule) (func(), error) {
+	if !schedule.Lock {
+		return func() {}, nil
+	}
+
+	ttl := time.Duration(schedule.LockTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTaskLockTTL
+	}
+
+	lock := taskLock(schedule.Task, ttl)
+	acquired, err := lock.acquire(ctx)
+	if err != nil {
+		logger.Log.Error("Error acquiring the task lock", zap.String("task", schedule.Task), zap.Error(err))
+		return nil, err
+	}
+	if !acquired {
+		finishRun(model.ScheduleRun{Task: schedule.Task, Host: hostname, StartedAt: time.Now()}, RunSkipped, ErrTaskLocked)
+		return nil, ErrTaskLocked
+	}
+
+	return func() {
+		if err := lock.release(context.Background()); err != nil {
+			logger.Log.Error("Error releasing the task lock", zap.String("task", schedule.Task), zap.Error(err))
+		}
+	}, nil
+}
+
+// run runs a task once, recording the run and logging how long it took and its error.
+// A panic of the task is logged, not propagated.
+func run(ctx context.Context, name string, task Task) (err error) {
+	start := time.Now()
+	logger.Log.Info("Task started", zap.String("task", name))
+	r := startRun(name, start)
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("task %s panicked: %v", name, rec)
+		}
+
+		if err != nil {
+			logger.Log.Error("Task failed", zap.String("task", name), zap.Duration("duration", time.Since(start)), zap.Error(err))
+			finishRun(r, RunFailed, err)
+			return
+		}
+		logger.Log.Info("Task finished", zap.String("task", name), zap.Duration("duration", time.Since(start)))
+		finishRun(r, RunSucceeded, nil)
+	}()
 
-	s.StartImmediately()
-	s.StartBlocking()
+	return task(ctx)
 }
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"webapi/config"
+	"webapi/internal/db/model"
+	"webapi/internal/db/rdb"
+	"webapi/internal/repository"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// reloadChannel is the redis channel the leader listens on to reload the schedules right away.
+func reloadChannel() string {
+	return rdb.AddPrefix("scheduler_reload")
+}
+
+// FromConfig returns the schedules of the config as database schedules.
+func FromConfig(schedules []config.Schedule) []model.Schedule {
+	result := make([]model.Schedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		result = append(result, model.Schedule{
+			Task:      schedule.Job,
+			Cron:      schedule.Cron,
+			Timezone:  schedule.Timezone,
+			Jitter:    schedule.Jitter,
+			IsEnabled: schedule.IsEnabled,
+			Lock:      schedule.Lock,
+			LockTTL:   schedule.LockTTL,
+
+			MisfirePolicy: schedule.MisfirePolicy,
+			MisfireLimit:  schedule.MisfireLimit,
+		})
+	}
+
+	return result
+}
+
+// Seed adds the schedules of the config whose task has no schedule in the database yet, and returns how many were added.
+// A schedule already in the database is never overwritten by the config.
+func Seed(ctx context.Context) (int64, error) {
+	return repository.NewRepository().Schedule.SeedSchedules(ctx, FromConfig(config.GetConfig().Schedules))
+}
+
+// Schedules returns the schedules of the database.
+func Schedules(ctx context.Context) ([]model.Schedule, error) {
+	return repository.NewRepository().Schedule.GetSchedules(ctx)
+}
+
+// NotifyChanged makes the leader reload the schedules now instead of at its next scheduler.reloadInterval.
+func NotifyChanged(ctx context.Context) error {
+	return rdb.GetRedisClient().Publish(ctx, reloadChannel(), instanceID).Err()
+}
+
+// watchChanges signals reload every time the schedules are changed, until the context is canceled.
+func watchChanges(ctx context.Context, reload chan<- struct{}) {
+	pubsub := rdb.Subscribe(ctx, reloadChannel())
+
+	// The channel of the subscription is only closed by Close, a lost connection is reconnected
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+
+	for range pubsub.Channel() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+}
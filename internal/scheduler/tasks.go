@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"webapi/internal/helper/queue"
+	"webapi/internal/logger"
+)
+
+func init() {
+	Register("PruneJobs", pruneJobs)
+}
+
+// pruneJobs deletes old finished jobs with the options of queue.prune.
+func pruneJobs(ctx context.Context) error {
+	opts, err := queue.PruneOptionsFromConfig()
+	if err != nil {
+		return err
+	}
+
+	result, err := queue.Prune(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Info("Pruned jobs", zap.Int64("completed", result.Completed), zap.Int64("failed", result.Failed), zap.Strings("archives", result.Archives))
+	return nil
+}
@@ -69,6 +69,12 @@ var (
 		SUBCODE_UNAUTHORIZED,
 		"permission is not granted",
 	)
+	ForbiddenError *ExceptionErrors = createFixedExceptionErrors(
+		http.StatusForbidden,
+		ERROR_TYPE_FORBIDDEN,
+		SUBCODE_FORBIDDEN,
+		"you don't have permission to access this resource",
+	)
 
 	// ValidationError
 	ValidationFailedError *ExceptionErrors = createFixedExceptionErrors(
@@ -97,6 +103,24 @@ var (
 		SUBCODE_BACKGROUND_JOB_FAILED,
 		"background job failed",
 	)
+	JobAlreadyFinishedError *ExceptionErrors = createFixedExceptionErrors(
+		http.StatusConflict,
+		ERROR_TYPE_JOB_ERROR,
+		SUBCODE_JOB_ALREADY_FINISHED,
+		"job already finished",
+	)
+	TaskAlreadyRunningError *ExceptionErrors = createFixedExceptionErrors(
+		http.StatusConflict,
+		ERROR_TYPE_JOB_ERROR,
+		SUBCODE_TASK_ALREADY_RUNNING,
+		"task already running",
+	)
+	ScheduleAlreadyExistsError *ExceptionErrors = createFixedExceptionErrors(
+		http.StatusConflict,
+		ERROR_TYPE_JOB_ERROR,
+		SUBCODE_SCHEDULE_ALREADY_EXISTS,
+		"schedule already exists",
+	)
 	CannotRunBatchDailyError *ExceptionErrors = createFixedExceptionErrors(
 		http.StatusInternalServerError,
 		ERROR_TYPE_JOB_ERROR,
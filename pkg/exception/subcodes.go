@@ -14,8 +14,12 @@ var (
 	SUBCODE_INVALID_REQUEST_BODY           errorSubcode = newErrorSubcode(798)
 	SUBCODE_INVALID_ID                     errorSubcode = newErrorSubcode(799)
 	SUBCODE_UNAUTHORIZED                   errorSubcode = newErrorSubcode(701)
+	SUBCODE_FORBIDDEN                      errorSubcode = newErrorSubcode(703)
 	SUBCODE_DATA_NOT_FOUND                 errorSubcode = newErrorSubcode(704)
 	SUBCODE_API_NOTE_FOUND                 errorSubcode = newErrorSubcode(705)
+	SUBCODE_JOB_ALREADY_FINISHED           errorSubcode = newErrorSubcode(706)
+	SUBCODE_TASK_ALREADY_RUNNING           errorSubcode = newErrorSubcode(707)
+	SUBCODE_SCHEDULE_ALREADY_EXISTS        errorSubcode = newErrorSubcode(708)
 	SUBCODE_VALIDATION_FAILED              errorSubcode = newErrorSubcode(760)
 	SUBCODE_USER_EMAIL_ALREADY_TAKEN       errorSubcode = newErrorSubcode(761)
 	SUBCODE_USER_PHONE_ALREADY_TAKEN       errorSubcode = newErrorSubcode(761)
@@ -7,6 +7,7 @@ const (
 	ERROR_TYPE_BAD_REQUEST            errorType = "BadRequest"
 	ERROR_TYPE_NOT_FOUND              errorType = "NotFound"
 	ERROR_TYPE_UNAUTHORIZED           errorType = "Unauthorized"
+	ERROR_TYPE_FORBIDDEN              errorType = "Forbidden"
 	ERROR_TYPE_VALIDATION_ERROR       errorType = "ValidationError"
 	ERROR_TYPE_JOB_ERROR              errorType = "JobError"
 	ERROR_TYPE_EXTERNAL_SERVICE_ERROR errorType = "ExternalServiceError"
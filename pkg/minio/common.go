@@ -49,6 +49,28 @@ func UploadFile(bucketName, objectName, filePath, contentType string) (*minio.Up
 	return &info, nil
 }
 
+func UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*minio.UploadInfo, error) {
+	exists, err := MinioClient.BucketExists(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		err = MinioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{Region: "us-east-1"})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := MinioClient.PutObject(ctx, bucketName, objectName, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
 func DownloadFile(ctx context.Context, bucketName, objectName string) (io.Reader, int64, string, error) {
 	object, err := MinioClient.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
 	if err != nil {
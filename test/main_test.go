@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gavv/httpexpect/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 	"webapi/config"
 	"webapi/internal/db/pgx"
@@ -110,3 +113,15 @@ func readJSONToString(t *testing.T, filePath string) string {
 
 	return string(jsonBytes)
 }
+
+// signToken returns a bearer token granting permissions.
+func signToken(t *testing.T, permissions ...string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"permissions": permissions,
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	require.NoError(t, err)
+
+	return signed
+}
@@ -0,0 +1,32 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"webapi/internal/helper/queue"
+	"webapi/internal/job"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	queue.RegisterMetrics()
+
+	q := queue.NewQueue("testing_metrics")
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	j, _ := job.NewJob("ProcessExample", &job.ProcessExample{Data: "metrics"}, 3, 0)
+	require.NoError(t, q.Enqueue(ctx, j))
+
+	e := fastHTTPTester(t, r.Handler())
+
+	resp := e.GET("/metrics").Expect()
+
+	resp.Status(http.StatusOK)
+	resp.Body().Contains(`queue_jobs_enqueued_total{handler="ProcessExample",queue="testing_metrics"}`)
+	resp.Body().Contains(`queue_depth{queue="testing_metrics",state="pending"} 1`)
+}
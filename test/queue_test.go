@@ -4,15 +4,18 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"webapi/internal/db/model"
+	"webapi/internal/db/pgx"
 	"webapi/internal/db/rdb"
 	"webapi/internal/helper/queue"
 	"webapi/internal/job"
@@ -225,3 +228,464 @@ func TestGetQueues(t *testing.T) {
 		})
 	}
 }
+
+func TestUniqueJob(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewQueue("testing_unique")
+	uniqueKey := "rebuild_thumbnails_" + uuid.NewString()
+
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	original, _ := job.NewJob("ProcessExample", &job.ProcessExample{
+		Data: "original",
+	}, 1, 0, job.WithUnique(uniqueKey, time.Minute, job.UniqueUntilCompleted))
+	err := q.Enqueue(ctx, original)
+	require.NoError(t, err)
+
+	// Enqueuing a duplicate while the original is pending is a no-op returning the existing id
+	duplicate, _ := job.NewJob("ProcessExample", &job.ProcessExample{
+		Data: "duplicate",
+	}, 1, 0, job.WithUnique(uniqueKey, time.Minute, job.UniqueUntilCompleted))
+	err = q.Enqueue(ctx, duplicate)
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, duplicate.ID, "duplicate job should get the id of the original job")
+
+	length, err := q.Length(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), length, "duplicate job should not be enqueued")
+
+	// The lock is still held while the original is running
+	dequeued, err := q.Dequeue(ctx, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, dequeued)
+
+	running, _ := job.NewJob("ProcessExample", &job.ProcessExample{
+		Data: "while running",
+	}, 1, 0, job.WithUnique(uniqueKey, time.Minute, job.UniqueUntilCompleted))
+	err = q.Enqueue(ctx, running)
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, running.ID)
+
+	// The lock is released once the original completes
+	err = q.RemoveProcessed(ctx, dequeued.ID, nil)
+	require.NoError(t, err)
+
+	next, _ := job.NewJob("ProcessExample", &job.ProcessExample{
+		Data: "after completion",
+	}, 1, 0, job.WithUnique(uniqueKey, time.Minute, job.UniqueUntilCompleted))
+	nextID := next.ID
+	err = q.Enqueue(ctx, next)
+	require.NoError(t, err)
+	assert.Equal(t, nextID, next.ID, "job should be enqueued once the original completed")
+}
+
+func TestGetBatchByID(t *testing.T) {
+	ctx := context.Background()
+
+	job1, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "variant 1"}, 1, 0)
+	job2, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "variant 2"}, 1, 0)
+	notify, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "notify"}, 1, 0)
+
+	batchID, err := queue.NewBatch(job1, job2).
+		Name("generate variants").
+		OnQueue("test_batch").
+		Finally(notify).
+		Dispatch(ctx)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		queue.NewQueue("test_batch").Clear(ctx)
+	})
+
+	tests := []struct {
+		name               string
+		batchID            string
+		token              string
+		expectedStatusCode int
+		expectedSchema     string
+	}{
+		{
+			name:               "test get batch without token",
+			batchID:            batchID.String(),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "test get batch without permission",
+			batchID:            batchID.String(),
+			token:              signToken(t, "users:read"),
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "test get batch by id",
+			batchID:            batchID.String(),
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusOK,
+			expectedSchema:     readJSONToString(t, "json_response_schema/get_batch.json"),
+		},
+		{
+			name:               "test get unknown batch",
+			batchID:            uuid.NewString(),
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "test get batch with invalid id",
+			batchID:            "invalid",
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fastHTTPTester(t, r.Handler())
+
+			req := e.GET("/api/v1/batches/" + tt.batchID)
+			if tt.token != "" {
+				req = req.WithHeader("Authorization", "Bearer "+tt.token)
+			}
+
+			resp := req.Expect()
+
+			resp.Status(tt.expectedStatusCode)
+			if tt.expectedSchema != "" {
+				resp.JSON().Schema(tt.expectedSchema)
+				resp.JSON().Object().Value("data").Object().Value("total_jobs").IsEqual(2)
+				resp.JSON().Object().Value("data").Object().Value("pending_jobs").IsEqual(2)
+			}
+		})
+	}
+}
+
+func TestGetJobByID(t *testing.T) {
+	ctx := context.Background()
+
+	q := queue.NewQueue("test_get_job")
+	pendingJob, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "poll me"}, 1, 0)
+	err := q.Enqueue(ctx, pendingJob)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	tests := []struct {
+		name               string
+		jobID              string
+		token              string
+		expectedStatusCode int
+		expectedSchema     string
+	}{
+		{
+			name:               "test get job without token",
+			jobID:              pendingJob.ID.String(),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "test get job without permission",
+			jobID:              pendingJob.ID.String(),
+			token:              signToken(t, "users:read"),
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "test get job by id",
+			jobID:              pendingJob.ID.String(),
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusOK,
+			expectedSchema:     readJSONToString(t, "json_response_schema/get_job.json"),
+		},
+		{
+			name:               "test get unknown job",
+			jobID:              uuid.NewString(),
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "test get job with invalid id",
+			jobID:              "invalid",
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fastHTTPTester(t, r.Handler())
+
+			req := e.GET("/api/v1/jobs/" + tt.jobID)
+			if tt.token != "" {
+				req = req.WithHeader("Authorization", "Bearer "+tt.token)
+			}
+
+			resp := req.Expect()
+
+			resp.Status(tt.expectedStatusCode)
+			if tt.expectedSchema != "" {
+				resp.JSON().Schema(tt.expectedSchema)
+				resp.JSON().Object().Value("data").Object().Value("status").IsEqual(job.StatusPending)
+			}
+		})
+	}
+}
+
+func TestQueueManagement(t *testing.T) {
+	ctx := context.Background()
+
+	q := queue.NewQueue("test_manage_queue")
+	pendingJob, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "manage me"}, 1, 0)
+	err := q.Enqueue(ctx, pendingJob)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	tests := []struct {
+		name               string
+		path               string
+		token              string
+		expectedStatusCode int
+	}{
+		{
+			name:               "test get queue without token",
+			path:               "/api/v1/queues/test_manage_queue",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "test get queue without permission",
+			path:               "/api/v1/queues/test_manage_queue",
+			token:              signToken(t, "users:read"),
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "test get queue",
+			path:               "/api/v1/queues/test_manage_queue",
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "test get queue jobs",
+			path:               "/api/v1/queues/test_manage_queue/jobs?state=pending",
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "test get queue jobs with unknown state",
+			path:               "/api/v1/queues/test_manage_queue/jobs?state=unknown",
+			token:              signToken(t, "queue:manage"),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fastHTTPTester(t, r.Handler())
+
+			req := e.GET(tt.path)
+			if tt.token != "" {
+				req = req.WithHeader("Authorization", "Bearer "+tt.token)
+			}
+			resp := req.Expect()
+
+			resp.Status(tt.expectedStatusCode)
+		})
+	}
+
+	// Forgetting an unknown job returns not found.
+	e := fastHTTPTester(t, r.Handler())
+	e.DELETE("/api/v1/queues/test_manage_queue/jobs/"+uuid.NewString()).
+		WithHeader("Authorization", "Bearer "+signToken(t, "queue:manage")).
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestPauseQueue(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewQueue("test_pause_queue")
+
+	t.Cleanup(func() {
+		q.Resume(ctx)
+		q.Clear(ctx)
+	})
+
+	err := q.Pause(ctx)
+	require.NoError(t, err)
+
+	paused, err := q.IsPaused(ctx)
+	require.NoError(t, err)
+	assert.True(t, paused, "IsPaused should return true after Pause")
+
+	// A paused queue is listed even when it has no items.
+	infos, err := queue.ListQueueKeysAndLengths(ctx)
+	require.NoError(t, err)
+
+	found := false
+	for _, info := range infos {
+		if info.KeyWithoutPrefix == "test_pause_queue" {
+			found = true
+			assert.True(t, info.Paused)
+		}
+	}
+	assert.True(t, found, "ListQueueKeysAndLengths should list the paused queue")
+
+	err = q.Resume(ctx)
+	require.NoError(t, err)
+
+	paused, err = q.IsPaused(ctx)
+	require.NoError(t, err)
+	assert.False(t, paused, "IsPaused should return false after Resume")
+}
+
+func TestCancelJob(t *testing.T) {
+	ctx := context.Background()
+
+	q := queue.NewQueue("test_cancel_job")
+	pendingJob, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "cancel me"}, 1, 0)
+	err := q.Enqueue(ctx, pendingJob)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"permissions": []string{"queue:manage"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		jobID              string
+		expectedStatusCode int
+		expectedStatus     string
+	}{
+		{
+			name:               "test cancel pending job",
+			jobID:              pendingJob.ID.String(),
+			expectedStatusCode: http.StatusOK,
+			expectedStatus:     job.StatusCancelled,
+		},
+		{
+			name:               "test cancel job already cancelled",
+			jobID:              pendingJob.ID.String(),
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "test cancel unknown job",
+			jobID:              uuid.NewString(),
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "test cancel job with invalid id",
+			jobID:              "invalid",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fastHTTPTester(t, r.Handler())
+
+			resp := e.POST("/api/v1/jobs/"+tt.jobID+"/cancel").
+				WithHeader("Authorization", "Bearer "+signed).
+				Expect()
+
+			resp.Status(tt.expectedStatusCode)
+			if tt.expectedStatus != "" {
+				resp.JSON().Object().Value("data").Object().Value("status").IsEqual(tt.expectedStatus)
+			}
+		})
+	}
+
+	// The cancelled job is no longer pending.
+	length, err := q.Length(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+}
+
+func TestEnqueueTx(t *testing.T) {
+	ctx := context.Background()
+
+	q := queue.NewQueue("test_outbox")
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	enqueueTx := func(commit bool) *job.Job {
+		j, err := job.NewJob("ProcessExample", job.ProcessExample{Data: "outbox"}, 1, 0)
+		require.NoError(t, err)
+
+		tx, err := pgx.GetPgxPool().Begin(ctx)
+		require.NoError(t, err)
+		require.NoError(t, q.EnqueueTx(ctx, tx, j))
+
+		if commit {
+			require.NoError(t, tx.Commit(ctx))
+		} else {
+			require.NoError(t, tx.Rollback(ctx))
+		}
+
+		return j
+	}
+
+	committed := enqueueTx(true)
+	rolledBack := enqueueTx(false)
+
+	// Nothing is enqueued before the relay runs
+	length, err := q.Length(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+
+	_, err = queue.RelayOnce(ctx, 100)
+	require.NoError(t, err)
+
+	jobs, _, err := q.ListJobs(ctx, queue.StatePending, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, committed.ID, jobs[0].ID)
+	assert.NotEqual(t, rolledBack.ID, jobs[0].ID)
+
+	// Relaying again does not enqueue the job twice
+	_, err = queue.RelayOnce(ctx, 100)
+	require.NoError(t, err)
+
+	length, err = q.Length(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+}
+
+func TestJobEvents(t *testing.T) {
+	ctx := context.Background()
+
+	q := queue.NewQueue("test_job_events")
+	t.Cleanup(func() {
+		q.Clear(ctx)
+	})
+
+	events := make(chan queue.Event, 10)
+	unsubscribe := queue.SubscribeEvents(func(e queue.Event) {
+		events <- e
+	})
+	defer unsubscribe()
+
+	j, _ := job.NewJob("ProcessExample", job.ProcessExample{Data: "events"}, 1, 0, job.WithCallback("http://127.0.0.1:1/callback"))
+	require.NoError(t, q.Enqueue(ctx, j))
+
+	select {
+	case e := <-events:
+		assert.Equal(t, queue.EventEnqueued, e.Type)
+		assert.Equal(t, j.ID, e.JobID)
+		assert.Equal(t, "test_job_events", e.Queue)
+	case <-time.After(time.Second):
+		t.Fatal("no enqueued event received")
+	}
+
+	// The callback URL is kept with the job
+	stored, err := repo.Job.GetJobByID(ctx, j.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:1/callback", stored.CallbackURL)
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"webapi/internal/db/model"
+)
+
+func TestGetScheduleRuns(t *testing.T) {
+	ctx := context.Background()
+
+	task := "TestTask" + uuid.NewString()[:8]
+	finishedAt := time.Now()
+	duration := int64(1500)
+	_, err := repo.ScheduleRun.AddScheduleRun(ctx, model.ScheduleRun{
+		Task:       task,
+		Status:     "failed",
+		Error:      "something went wrong",
+		Host:       "test-host",
+		StartedAt:  finishedAt.Add(-1500 * time.Millisecond),
+		FinishedAt: &finishedAt,
+		DurationMs: &duration,
+	})
+	require.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"permissions": []string{"schedule:manage"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	require.NoError(t, err)
+
+	e := fastHTTPTester(t, r.Handler())
+
+	resp := e.GET("/api/v1/schedules/runs").
+		WithQuery("task", task).
+		WithHeader("Authorization", "Bearer "+signed).
+		Expect().
+		Status(http.StatusOK)
+
+	// The response of an authorized request is never served to another caller
+	e.GET("/api/v1/schedules/runs").
+		WithQuery("task", task).
+		Expect().
+		Status(http.StatusUnauthorized)
+	e.GET("/api/v1/schedules/runs").
+		WithQuery("task", "Unknown"+task).
+		WithHeader("Authorization", "Bearer "+signed).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("total_count").IsEqual(0)
+
+	resp.JSON().Object().Value("total_count").IsEqual(1)
+	run := resp.JSON().Object().Value("data").Array().Value(0).Object()
+	run.Value("task").IsEqual(task)
+	run.Value("status").IsEqual("failed")
+	run.Value("error").IsEqual("something went wrong")
+	run.Value("host").IsEqual("test-host")
+	run.Value("duration_ms").IsEqual(duration)
+}
+
+func TestRunTask(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"permissions": []string{"schedule:manage"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		task               string
+		token              string
+		expectedStatusCode int
+	}{
+		{
+			name:               "test run task without token",
+			task:               "PruneJobs",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "test run unknown task",
+			task:               "UnknownTask",
+			token:              signed,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fastHTTPTester(t, r.Handler())
+
+			req := e.POST("/api/v1/schedules/" + tt.task + "/run")
+			if tt.token != "" {
+				req = req.WithHeader("Authorization", "Bearer "+tt.token)
+			}
+
+			req.Expect().Status(tt.expectedStatusCode)
+		})
+	}
+}
+
+func TestManageSchedules(t *testing.T) {
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		repo.Schedule.DeleteSchedule(ctx, "PruneJobs")
+	})
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"permissions": []string{"schedule:manage"},
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	require.NoError(t, err)
+
+	e := fastHTTPTester(t, r.Handler())
+	auth := "Bearer " + signed
+
+	e.POST("/api/v1/schedules").
+		WithHeader("Authorization", auth).
+		WithJSON(map[string]any{"task": "UnknownTask", "cron": "0 30 2 * * *"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	e.POST("/api/v1/schedules").
+		WithHeader("Authorization", auth).
+		WithJSON(map[string]any{"task": "PruneJobs", "cron": "30 2 * * *"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	created := e.POST("/api/v1/schedules").
+		WithHeader("Authorization", auth).
+		WithJSON(map[string]any{"task": "PruneJobs", "cron": "0 30 2 * * *"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Value("data").Object()
+	created.Value("task").IsEqual("PruneJobs")
+	created.Value("is_enabled").IsEqual(false)
+
+	e.POST("/api/v1/schedules").
+		WithHeader("Authorization", auth).
+		WithJSON(map[string]any{"task": "PruneJobs", "cron": "0 30 2 * * *"}).
+		Expect().
+		Status(http.StatusConflict)
+
+	e.GET("/api/v1/schedules/PruneJobs").
+		WithHeader("Authorization", auth).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("data").Object().Value("is_enabled").IsEqual(false)
+	e.GET("/api/v1/schedules").
+		WithHeader("Authorization", auth).
+		Expect().
+		Status(http.StatusOK)
+
+	// A schedule loaded by an admin is not served to callers without permission
+	e.GET("/api/v1/schedules/PruneJobs").
+		Expect().
+		Status(http.StatusUnauthorized)
+	e.GET("/api/v1/schedules").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	updated := e.PATCH("/api/v1/schedules/PruneJobs").
+		WithHeader("Authorization", auth).
+		WithJSON(map[string]any{"is_enabled": true, "timezone": "Europe/Paris", "jitter": 30}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("data").Object()
+	updated.Value("cron").IsEqual("0 30 2 * * *")
+	updated.Value("is_enabled").IsEqual(true)
+	updated.Value("timezone").IsEqual("Europe/Paris")
+	updated.Value("jitter").IsEqual(30)
+
+	// Reads after an update see it right away
+	e.GET("/api/v1/schedules/PruneJobs").
+		WithHeader("Authorization", auth).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("data").Object().Value("is_enabled").IsEqual(true)
+
+	e.PATCH("/api/v1/schedules/PruneJobs").
+		WithHeader("Authorization", auth).
+		WithJSON(map[string]any{"timezone": "Mars/Olympus"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	e.DELETE("/api/v1/schedules/PruneJobs").
+		WithHeader("Authorization", auth).
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/api/v1/schedules/PruneJobs").
+		WithHeader("Authorization", auth).
+		Expect().
+		Status(http.StatusNotFound)
+}